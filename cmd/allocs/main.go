@@ -10,6 +10,7 @@ import (
 
 func main() {
 	runPageIdea()
+	recordAllocations()
 }
 
 // 64 KB object
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/cagnosolutions/pager/pkg/pager"
+)
+
+// lookups is the number of record lookups measured on each path
+const lookups = 100000
+
+// recordAllocations measures the allocations made by the record lookup
+// path, once reading copies of the records and once borrowing them
+func recordAllocations() {
+	pg := pager.NewPage(1)
+	var rids []*pager.RecordID
+	for i := 0; i < 64; i++ {
+		rid, err := pg.AddRecord([]byte(fmt.Sprintf("this-is-record-%.6d", i)))
+		if err != nil {
+			panic(err)
+		}
+		rids = append(rids, rid)
+	}
+	measure := func(name string, get func(rid *pager.RecordID) ([]byte, error)) {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		var n int
+		for i := 0; i < lookups; i++ {
+			rec, err := get(rids[i%len(rids)])
+			if err != nil {
+				panic(err)
+			}
+			n += len(rec)
+		}
+		runtime.ReadMemStats(&after)
+		fmt.Printf("%s: %d lookups (%d bytes), %d allocs, %d bytes allocated\n",
+			name, lookups, n, after.Mallocs-before.Mallocs, after.TotalAlloc-before.TotalAlloc)
+	}
+	measure("GetRecord", pg.GetRecord)
+	measure("BorrowRecord", pg.BorrowRecord)
+}
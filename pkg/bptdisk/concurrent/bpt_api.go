@@ -0,0 +1,103 @@
+package concurrent
+
+// NewBPTree initializes a new tree
+func NewBPTree() (*BPTree, error) {
+	return NewBPTreeWithComparator(DefaultComparator)
+}
+
+// NewBPTreeWithComparator initializes a new tree that orders
+// its keys using the comparator provided
+func NewBPTreeWithComparator(cmp Comparator) (*BPTree, error) {
+	if cmp == nil {
+		cmp = DefaultComparator
+	}
+	bpt := &BPTree{
+		root:  &node{isLeaf: true},
+		cmp:   cmp,
+		order: defaultOrder,
+	}
+	return bpt, nil
+}
+
+// Has returns a boolean indicating weather or not
+// the provided key and associated record exists.
+func (t *BPTree) Has(k []byte) bool {
+	leaf := t.findLeafShared(k)
+	defer leaf.latch.RUnlock()
+	_, found := leaf.search(k, t.cmp)
+	return found
+}
+
+// Add inserts a new record using the provided key. It only
+// inserts an record if the key does not already exist. It
+// returns true if the record was added.
+func (t *BPTree) Add(k, v []byte) bool {
+	return !t.insert(clone(k), clone(v), true)
+}
+
+// Put is mainly used when you wish to upsert as it assumes the
+// data to already be contained the tree. It will overwrite
+// duplicate keys, as it does not check to see if the key exists.
+// It returns true if an existing record was updated.
+func (t *BPTree) Put(k, v []byte) bool {
+	return t.insert(clone(k), clone(v), false)
+}
+
+// Get returns the record for a given key if it exists
+func (t *BPTree) Get(k []byte) ([]byte, []byte) {
+	leaf := t.findLeafShared(k)
+	defer leaf.latch.RUnlock()
+	i, found := leaf.search(k, t.cmp)
+	if !found {
+		return nil, nil
+	}
+	return leaf.keys[i], leaf.vals[i]
+}
+
+// Del removes the record for the supplied key and attempts
+// to return the previous key and value
+func (t *BPTree) Del(k []byte) ([]byte, []byte) {
+	v, found := t.delete(k)
+	if !found {
+		return nil, nil
+	}
+	return k, v
+}
+
+// Range provides a simple iteration function for the tree. The
+// leaf being iterated over is latched (shared) while iter is
+// called, so iter must not modify the tree.
+func (t *BPTree) Range(iter func(k, v []byte) bool) {
+	c := t.findFirstLeaf()
+	for c != nil {
+		for i := 0; i < c.numKeys(); i++ {
+			if !iter(c.keys[i], c.vals[i]) {
+				c.latch.RUnlock()
+				return
+			}
+		}
+		c = c.nextLeaf()
+	}
+}
+
+// Len returns the a count of the number of items in the tree
+func (t *BPTree) Len() int {
+	var count int
+	for c := t.findFirstLeaf(); c != nil; c = c.nextLeaf() {
+		count += c.numKeys()
+	}
+	return count
+}
+
+// Close closes the tree
+func (t *BPTree) Close() {
+	t.latch.Lock()
+	t.root = &node{isLeaf: true}
+	t.latch.Unlock()
+}
+
+// clone returns a copy of b, the tree keeps its own copies of the
+// keys and values so callers are free to reuse their buffers
+func clone(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
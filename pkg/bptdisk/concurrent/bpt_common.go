@@ -0,0 +1,82 @@
+package concurrent
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Comparator compares two keys, and returns an integer comparing them. The
+// result will be 0 if a == b, -1 if a < b, and +1 if a > b.
+type Comparator func(a, b []byte) int
+
+// DefaultComparator orders keys lexicographically
+var DefaultComparator Comparator = bytes.Compare
+
+// defaultOrder is the tree's order
+const defaultOrder = 128
+
+// node represents a node of the BPTree. Internal nodes hold numKeys+1
+// child pointers (ptrs) and leaf nodes hold numKeys values (vals). Leaves
+// are linked together (to the right) using next. Every node has its own
+// latch, which must be held while the node is read or modified.
+type node struct {
+	latch  sync.RWMutex
+	isLeaf bool
+	keys   [][]byte
+	vals   [][]byte
+	ptrs   []*node
+	next   *node
+}
+
+// numKeys returns the number of keys in the node
+func (n *node) numKeys() int {
+	return len(n.keys)
+}
+
+// BPTree represents a b+tree that is safe for concurrent use. Each node
+// is protected by its own read/write latch, and the tree is traversed
+// using latch coupling (crabbing), so readers and writers working in
+// disjoint parts of the tree can proceed in parallel.
+//
+// Writers first descend optimistically, holding shared latches on the
+// internal nodes and an exclusive latch on the leaf only. If the leaf
+// would have to be split, the writer starts over and descends holding
+// exclusive latches, releasing the ancestors of any node that is safe
+// (one that will not be split by the insert).
+//
+// Nodes are never merged when keys are deleted. Leaves that become empty
+// are left in the tree, and are filled again by inserts into their key
+// range. This keeps deletes from ever having to latch sibling nodes.
+type BPTree struct {
+	latch sync.RWMutex // latch protects the root pointer
+	root  *node
+	cmp   Comparator
+	order int
+}
+
+// cut finds the appropriate place to split a node that is
+// too big. it is used during insertion
+func cut(length int) int {
+	if length%2 == 0 {
+		return length / 2
+	}
+	return length/2 + 1
+}
+
+// isSafe reports whether one more key can be inserted into the
+// node without it having to be split
+func (t *BPTree) isSafe(n *node) bool {
+	return n.numKeys() < t.order-1
+}
+
+// isFull reports whether the node has grown too large, and needs to be split
+func (t *BPTree) isFull(n *node) bool {
+	return n.numKeys() > t.order-1
+}
+
+// pathEntry is a single step taken on the path from the root to a leaf,
+// it records the node along with the index of the child pointer taken
+type pathEntry struct {
+	n *node
+	i int
+}
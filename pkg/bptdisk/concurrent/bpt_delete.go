@@ -0,0 +1,29 @@
+package concurrent
+
+// delete is the "master" deletion function. it removes the key and the
+// associated value from the tree, and returns the removed value (or nil
+// if the key could not be found).
+//
+// nodes are never merged or removed, so a delete only ever changes a
+// single leaf, and only the leaf needs to be latched exclusively.
+func (t *BPTree) delete(k []byte) ([]byte, bool) {
+	leaf := t.findLeafExclusive(k)
+	defer leaf.latch.Unlock()
+	i, found := leaf.search(k, t.cmp)
+	if !found {
+		return nil, false
+	}
+	v := leaf.vals[i]
+	removeFromLeaf(leaf, i)
+	return v, true
+}
+
+// removeFromLeaf removes the key and value at index i from the leaf
+func removeFromLeaf(leaf *node, i int) {
+	copy(leaf.keys[i:], leaf.keys[i+1:])
+	leaf.keys[len(leaf.keys)-1] = nil
+	leaf.keys = leaf.keys[:len(leaf.keys)-1]
+	copy(leaf.vals[i:], leaf.vals[i+1:])
+	leaf.vals[len(leaf.vals)-1] = nil
+	leaf.vals = leaf.vals[:len(leaf.vals)-1]
+}
@@ -0,0 +1,104 @@
+package concurrent
+
+import (
+	"sort"
+)
+
+// childIndex returns the index of the child pointer to follow for the
+// provided key in an internal node
+func (n *node) childIndex(k []byte, cmp Comparator) int {
+	i := 0
+	for i < n.numKeys() {
+		if cmp(k, n.keys[i]) >= 0 {
+			i++
+		} else {
+			break
+		}
+	}
+	return i
+}
+
+// search returns the index of the key in the node, or where the key
+// would be inserted, along with a boolean reporting if it was found
+func (n *node) search(k []byte, cmp Comparator) (int, bool) {
+	i := sort.Search(n.numKeys(), func(i int) bool {
+		return cmp(n.keys[i], k) >= 0
+	})
+	return i, i < n.numKeys() && cmp(n.keys[i], k) == 0
+}
+
+// findLeafShared traces the path from the root to the leaf containing
+// the given key, using shared latch coupling. The leaf is returned with
+// its shared latch held, the caller must release it.
+func (t *BPTree) findLeafShared(k []byte) *node {
+	t.latch.RLock()
+	c := t.root
+	c.latch.RLock()
+	t.latch.RUnlock()
+	for !c.isLeaf {
+		// latch the child before letting go of the parent
+		child := c.ptrs[c.childIndex(k, t.cmp)]
+		child.latch.RLock()
+		c.latch.RUnlock()
+		c = child
+	}
+	return c
+}
+
+// findLeafExclusive traces the path from the root to the leaf containing
+// the given key, using shared latch coupling for the internal nodes. The
+// leaf is returned with its exclusive latch held, the caller must release
+// it. Because only the leaf is latched, the leaf must not be split.
+func (t *BPTree) findLeafExclusive(k []byte) *node {
+	t.latch.RLock()
+	c := t.root
+	if c.isLeaf {
+		c.latch.Lock()
+		t.latch.RUnlock()
+		return c
+	}
+	c.latch.RLock()
+	t.latch.RUnlock()
+	for {
+		// latch the child before letting go of the parent
+		child := c.ptrs[c.childIndex(k, t.cmp)]
+		if child.isLeaf {
+			child.latch.Lock()
+			c.latch.RUnlock()
+			return child
+		}
+		child.latch.RLock()
+		c.latch.RUnlock()
+		c = child
+	}
+}
+
+// findFirstLeaf traces the path from the root to the leftmost leaf in
+// the tree. The leaf is returned with its shared latch held, the caller
+// must release it.
+func (t *BPTree) findFirstLeaf() *node {
+	t.latch.RLock()
+	c := t.root
+	c.latch.RLock()
+	t.latch.RUnlock()
+	for !c.isLeaf {
+		child := c.ptrs[0]
+		child.latch.RLock()
+		c.latch.RUnlock()
+		c = child
+	}
+	return c
+}
+
+// nextLeaf returns the next leaf in the chain (to the right) of the current
+// leaf, or nil if it is the last leaf. The shared latch on the next leaf is
+// taken before the one on the current leaf is released, so leaves are always
+// latched from left to right.
+func (n *node) nextLeaf() *node {
+	next := n.next
+	if next != nil {
+		next.latch.RLock()
+	}
+	n.latch.RUnlock()
+	return next
+}
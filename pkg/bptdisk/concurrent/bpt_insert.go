@@ -0,0 +1,157 @@
+package concurrent
+
+// insert is the "master" insertion function. it inserts a key and an
+// associated value into the tree causing the tree to be adjusted however
+// necessary to maintain the tree's properties. if unique is set, then an
+// existing key is left alone. it returns a boolean reporting if the key
+// already existed.
+func (t *BPTree) insert(k, v []byte, unique bool) bool {
+	// first, try the optimistic approach which only latches the leaf
+	// exclusively, this works as long as the leaf does not need a split
+	leaf := t.findLeafExclusive(k)
+	i, found := leaf.search(k, t.cmp)
+	if found || t.isSafe(leaf) {
+		defer leaf.latch.Unlock()
+		if found {
+			if !unique {
+				leaf.vals[i] = v
+			}
+			return true
+		}
+		insertIntoLeaf(leaf, i, k, v)
+		return false
+	}
+	leaf.latch.Unlock()
+	// otherwise, the leaf is (probably) going to be split, so we start
+	// over and latch every node that may be changed by the split
+	return t.insertPessimistic(k, v, unique)
+}
+
+// insertPessimistic inserts the key and value into the tree, holding
+// exclusive latches on every node that the insert may need to split
+func (t *BPTree) insertPessimistic(k, v []byte, unique bool) bool {
+	t.latch.Lock()
+	rootHeld := true
+	var path []pathEntry
+	// release unlocks every latch held above the current node
+	release := func() {
+		for _, e := range path {
+			e.n.latch.Unlock()
+		}
+		path = path[:0]
+		if rootHeld {
+			t.latch.Unlock()
+			rootHeld = false
+		}
+	}
+	c := t.root
+	c.latch.Lock()
+	for !c.isLeaf {
+		// if the node will not be split, nothing above it will
+		// change, so all the latches above it can be released
+		if t.isSafe(c) {
+			release()
+		}
+		i := c.childIndex(k, t.cmp)
+		path = append(path, pathEntry{c, i})
+		c = c.ptrs[i]
+		c.latch.Lock()
+	}
+	if t.isSafe(c) {
+		release()
+	}
+	defer c.latch.Unlock()
+	defer release()
+	// c is now the leaf, check if the key already exists
+	i, found := c.search(k, t.cmp)
+	if found {
+		if !unique {
+			c.vals[i] = v
+		}
+		return true
+	}
+	insertIntoLeaf(c, i, k, v)
+	if t.isFull(c) {
+		t.splitLeaf(c, path)
+	}
+	return false
+}
+
+// insertIntoLeaf inserts the key and value into the leaf at index i
+func insertIntoLeaf(leaf *node, i int, k, v []byte) {
+	leaf.keys = append(leaf.keys, nil)
+	copy(leaf.keys[i+1:], leaf.keys[i:])
+	leaf.keys[i] = k
+	leaf.vals = append(leaf.vals, nil)
+	copy(leaf.vals[i+1:], leaf.vals[i:])
+	leaf.vals[i] = v
+}
+
+// insertIntoNode inserts the key and right child pointer into the
+// internal node, to the right of the child pointer at index i
+func insertIntoNode(n *node, i int, k []byte, right *node) {
+	n.keys = append(n.keys, nil)
+	copy(n.keys[i+1:], n.keys[i:])
+	n.keys[i] = k
+	n.ptrs = append(n.ptrs, nil)
+	copy(n.ptrs[i+2:], n.ptrs[i+1:])
+	n.ptrs[i+1] = right
+}
+
+// splitLeaf splits the leaf into two, moving the upper half of the keys
+// into a new leaf to the right, and inserts the new leaf into the parent.
+// the new leaf is not reachable by any other goroutine until it has been
+// linked in, so it does not need to be latched.
+func (t *BPTree) splitLeaf(leaf *node, path []pathEntry) {
+	split := cut(leaf.numKeys())
+	// create the new leaf, and move the upper half into it
+	right := &node{isLeaf: true}
+	right.keys = append(right.keys, leaf.keys[split:]...)
+	right.vals = append(right.vals, leaf.vals[split:]...)
+	leaf.keys = append([][]byte(nil), leaf.keys[:split]...)
+	leaf.vals = append([][]byte(nil), leaf.vals[:split]...)
+	// link the new leaf into the leaf chain
+	right.next = leaf.next
+	leaf.next = right
+	// and insert the new leaf into the parent
+	t.insertIntoParent(path, leaf, right.keys[0], right)
+}
+
+// insertIntoParent inserts a new node (right) into the tree, to the right
+// of the left node, splitting the parent if need be. the exclusive latches
+// for every node in the path must be held.
+func (t *BPTree) insertIntoParent(path []pathEntry, left *node, k []byte, right *node) {
+	// if there is no parent, then we need a new root (and the
+	// latch protecting the root pointer is still being held)
+	if len(path) == 0 {
+		t.root = &node{
+			keys: [][]byte{k},
+			ptrs: []*node{left, right},
+		}
+		return
+	}
+	// otherwise, insert into the parent
+	parent, i := path[len(path)-1].n, path[len(path)-1].i
+	insertIntoNode(parent, i, k, right)
+	if !t.isFull(parent) {
+		return
+	}
+	// the parent is too large and needs to be split
+	t.splitNode(parent, path[:len(path)-1])
+}
+
+// splitNode splits the internal node into two, moving the upper half of
+// the keys into a new node to the right and pushing the middle key up
+// into the parent
+func (t *BPTree) splitNode(n *node, path []pathEntry) {
+	split := cut(n.numKeys()) - 1
+	k := n.keys[split]
+	// create the new node, and move the upper half into it
+	right := &node{}
+	right.keys = append(right.keys, n.keys[split+1:]...)
+	right.ptrs = append(right.ptrs, n.ptrs[split+1:]...)
+	n.keys = append([][]byte(nil), n.keys[:split]...)
+	n.ptrs = append([]*node(nil), n.ptrs[:split+1]...)
+	// and insert the new node into the parent
+	t.insertIntoParent(path, n, k, right)
+}
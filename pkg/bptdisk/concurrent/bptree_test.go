@@ -0,0 +1,152 @@
+package concurrent
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+const (
+	workers = 8
+	count   = 2000
+)
+
+func makeKey(i int) []byte {
+	return []byte(fmt.Sprintf("key-%.6d", i))
+}
+
+func makeVal(i int) []byte {
+	return []byte(fmt.Sprintf("{\"id\":%.6d,\"key\":\"key-%.6d\",\"value\":\"val-%.6d\"}", i, i, i))
+}
+
+// newTree returns a tree with a small order, so the tests
+// end up splitting plenty of nodes
+func newTree(t *testing.T) *BPTree {
+	tree, err := NewBPTree()
+	if err != nil {
+		t.Fatalf("new tree: %s", err)
+	}
+	tree.order = 4
+	return tree
+}
+
+func TestBPTree_PutGetDel(t *testing.T) {
+	tree := newTree(t)
+	defer tree.Close()
+	for i := 0; i < count; i++ {
+		if tree.Put(makeKey(i), makeVal(i)) {
+			t.Fatalf("put: key %d already existed", i)
+		}
+	}
+	if tree.Add(makeKey(0), []byte("other")) {
+		t.Errorf("add: expected existing key to be left alone")
+	}
+	for i := 0; i < count; i++ {
+		k, v := tree.Get(makeKey(i))
+		if !bytes.Equal(k, makeKey(i)) || !bytes.Equal(v, makeVal(i)) {
+			t.Fatalf("get %d: got %s, %q", i, k, v)
+		}
+	}
+	var last []byte
+	seen := 0
+	tree.Range(func(k, v []byte) bool {
+		if seen > 0 && bytes.Compare(k, last) <= 0 {
+			t.Errorf("range: keys out of order %s <= %s", k, last)
+		}
+		last = k
+		seen++
+		return true
+	})
+	if seen != count {
+		t.Fatalf("range: expected %d, saw %d", count, seen)
+	}
+	for i := 0; i < count; i++ {
+		_, v := tree.Del(makeKey(i))
+		if !bytes.Equal(v, makeVal(i)) {
+			t.Fatalf("del %d: got %q", i, v)
+		}
+	}
+	if n := tree.Len(); n != 0 {
+		t.Fatalf("len: expected 0, got %d", n)
+	}
+	// the empty leaves left behind are filled again
+	for i := 0; i < count; i++ {
+		tree.Put(makeKey(i), makeVal(i))
+	}
+	if n := tree.Len(); n != count {
+		t.Fatalf("len: expected %d, got %d", count, n)
+	}
+}
+
+func TestBPTree_ConcurrentWriters(t *testing.T) {
+	tree := newTree(t)
+	defer tree.Close()
+	// each writer inserts an interleaved set of keys, so they all
+	// end up splitting the same nodes
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < count; i += workers {
+				tree.Put(makeKey(i), makeVal(i))
+			}
+		}(w)
+	}
+	wg.Wait()
+	if n := tree.Len(); n != count {
+		t.Fatalf("len: expected %d, got %d", count, n)
+	}
+	for i := 0; i < count; i++ {
+		_, v := tree.Get(makeKey(i))
+		if !bytes.Equal(v, makeVal(i)) {
+			t.Fatalf("get %d: got %q", i, v)
+		}
+	}
+}
+
+func TestBPTree_ConcurrentReadersAndWriters(t *testing.T) {
+	tree := newTree(t)
+	defer tree.Close()
+	// the even keys are stable, and the odd keys are being
+	// inserted and deleted while the readers are running
+	for i := 0; i < count; i += 2 {
+		tree.Put(makeKey(i), makeVal(i))
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 2*w + 1; i < count; i += 2 * workers {
+				tree.Put(makeKey(i), makeVal(i))
+			}
+			for i := 2*w + 1; i < count; i += 2 * workers {
+				if _, v := tree.Del(makeKey(i)); !bytes.Equal(v, makeVal(i)) {
+					t.Errorf("del %d: got %q", i, v)
+				}
+			}
+		}(w)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < count; i += 2 {
+				if _, v := tree.Get(makeKey(i)); !bytes.Equal(v, makeVal(i)) {
+					t.Errorf("get %d: got %q", i, v)
+				}
+			}
+			var last []byte
+			tree.Range(func(k, v []byte) bool {
+				if last != nil && bytes.Compare(k, last) <= 0 {
+					t.Errorf("range: keys out of order %s <= %s", k, last)
+				}
+				last = k
+				return true
+			})
+		}(w)
+	}
+	wg.Wait()
+	if n := tree.Len(); n != count/2 {
+		t.Fatalf("len: expected %d, got %d", count/2, n)
+	}
+}
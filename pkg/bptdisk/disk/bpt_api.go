@@ -0,0 +1,248 @@
+package disk
+
+import (
+	"github.com/cagnosolutions/pager/pkg/pager"
+)
+
+// Open opens (or creates) the tree stored in the file at the
+// path provided
+func Open(path string) (*BPTree, error) {
+	return OpenWithComparator(path, DefaultComparator)
+}
+
+// OpenWithComparator opens (or creates) the tree stored in the file
+// at the path provided, ordering keys using the provided Comparator
+func OpenWithComparator(path string, cmp Comparator) (*BPTree, error) {
+	pm, err := pager.OpenPageManager(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := NewBPTreeWithComparator(pm, cmp)
+	if err != nil {
+		_ = pm.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// NewBPTree initializes a tree stored in the provided PageManager. If
+// the PageManager is empty, a new tree is created in it.
+func NewBPTree(pm *pager.PageManager) (*BPTree, error) {
+	return NewBPTreeWithComparator(pm, DefaultComparator)
+}
+
+// NewBPTreeWithComparator initializes a tree stored in the provided
+// PageManager that orders keys using the provided Comparator. The
+// comparator is not stored in the file, so a tree must always be
+// opened with the same comparator it was created with.
+func NewBPTreeWithComparator(pm *pager.PageManager, cmp Comparator) (*BPTree, error) {
+	if cmp == nil {
+		cmp = DefaultComparator
+	}
+	t := &BPTree{
+		pm:       pm,
+		order:    maxOrder(pm.MaxRecordSize()),
+		capacity: pm.MaxRecordSize(),
+		cmp:      cmp,
+	}
+	t.begin()
+	// if this is a new tree, we need a meta page and an empty root
+	if pm.PageCount() == 0 {
+		meta := pm.AllocatePage()
+		if meta.PageID() != metaPageID {
+			return nil, ErrBadMetaPage
+		}
+		t.stage(newLeaf(t.alloc()))
+		for pid := range t.dirty {
+			t.setRoot(pid)
+		}
+		err := t.commit()
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+	// otherwise, load the root from the meta page
+	p, err := pm.ReadPage(metaPageID)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := p.GetRecord(&pager.RecordID{PageID: metaPageID, SlotID: 0})
+	if err != nil {
+		return nil, ErrBadMetaPage
+	}
+	t.root, err = decodeMeta(rec)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Has returns a boolean indicating weather or not
+// the provided key and associated record exists.
+func (t *BPTree) Has(k []byte) (bool, error) {
+	leaf, err := t.findLeaf(k)
+	if err != nil {
+		return false, err
+	}
+	_, found := leaf.search(k, t.cmp)
+	return found, nil
+}
+
+// Add inserts a new record using the provided key. It
+// only inserts an record if the key does not already exist.
+func (t *BPTree) Add(k []byte, v []byte) error {
+	if len(k) > maxKeySize {
+		return ErrKeyTooLarge
+	}
+	val, err := t.makeValue(v)
+	if err != nil {
+		return err
+	}
+	// master insert method, only inserts if the key
+	// does not currently exist in the tree
+	_, found, err := t.insert(k, val, true)
+	if err != nil || found {
+		// the value was never added to the tree
+		_ = t.freeValue(val)
+	}
+	return err
+}
+
+// Put is mainly used when you wish to upsert as it assumes the
+// data to already be contained the tree. It will overwrite
+// duplicate keys, as it does not check to see if the key exists.
+// It returns true if an existing record was updated.
+func (t *BPTree) Put(k []byte, v []byte) (bool, error) {
+	if len(k) > maxKeySize {
+		return false, ErrKeyTooLarge
+	}
+	val, err := t.makeValue(v)
+	if err != nil {
+		return false, err
+	}
+	// master insert method treats insertion much like
+	// "setting" in a hashmap (an upsert) by default
+	old, found, err := t.insert(k, val, false)
+	if err != nil {
+		_ = t.freeValue(val)
+		return false, err
+	}
+	// free up the replaced value, if need be
+	return found, t.freeValue(old)
+}
+
+// Get returns the record for a given key if it exists
+func (t *BPTree) Get(k []byte) ([]byte, []byte, error) {
+	leaf, err := t.findLeaf(k)
+	if err != nil {
+		return nil, nil, err
+	}
+	i, found := leaf.search(k, t.cmp)
+	if !found {
+		return nil, nil, nil
+	}
+	v, err := t.readValue(leaf.vals[i])
+	if err != nil {
+		return nil, nil, err
+	}
+	return leaf.keys[i], v, nil
+}
+
+// Del removes the record for the supplied key and attempts
+// to return the previous key and value
+func (t *BPTree) Del(k []byte) ([]byte, []byte, error) {
+	v, err := t.delete(k)
+	if err != nil || v == nil {
+		return nil, nil, err
+	}
+	data, err := t.readValue(v)
+	if err != nil {
+		return nil, nil, err
+	}
+	return k, data, t.freeValue(v)
+}
+
+// Range provides a simple iteration function for the tree
+func (t *BPTree) Range(iter func(k []byte, v []byte) bool) error {
+	c, err := t.findFirstLeaf()
+	if err != nil {
+		return err
+	}
+	for c != nil {
+		for i := 0; i < c.numKeys(); i++ {
+			v, err := t.readValue(c.vals[i])
+			if err != nil {
+				return err
+			}
+			if !iter(c.keys[i], v) {
+				return nil
+			}
+		}
+		c, err = t.nextLeaf(c)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Min returns the minimum (lowest) key and value pair in the tree
+func (t *BPTree) Min() ([]byte, []byte, error) {
+	c, err := t.findFirstLeaf()
+	if err != nil || c.numKeys() == 0 {
+		return nil, nil, err
+	}
+	v, err := t.readValue(c.vals[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.keys[0], v, nil
+}
+
+// Max returns the maximum (highest) key and value pair in the tree
+func (t *BPTree) Max() ([]byte, []byte, error) {
+	c, err := t.findLastLeaf()
+	if err != nil || c.numKeys() == 0 {
+		return nil, nil, err
+	}
+	v, err := t.readValue(c.vals[c.numKeys()-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.keys[c.numKeys()-1], v, nil
+}
+
+// GetClosest attempts to return the closest match in the tree
+// if an explicit match cannot be found
+func (t *BPTree) GetClosest(k []byte) ([]byte, []byte, error) {
+	l, err := t.findLeaf(k)
+	if err != nil {
+		return nil, nil, err
+	}
+	i, ok := l.closest(k, t.cmp)
+	if !ok {
+		return nil, nil, nil
+	}
+	v, err := t.readValue(l.vals[i])
+	if err != nil {
+		return nil, nil, err
+	}
+	return l.keys[i], v, nil
+}
+
+// Len returns the a count of the number of items in the tree
+func (t *BPTree) Len() (int, error) {
+	var count int
+	n, err := t.findFirstLeaf()
+	for n != nil && err == nil {
+		count += n.numKeys()
+		n, err = t.nextLeaf(n)
+	}
+	return count, err
+}
+
+// Close closes the tree, and the underlying PageManager
+func (t *BPTree) Close() error {
+	return t.pm.Close()
+}
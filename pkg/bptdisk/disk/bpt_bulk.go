@@ -0,0 +1,165 @@
+package disk
+
+// bulkBatchSize is the number of nodes that are written
+// together while bulk loading the tree
+const bulkBatchSize = 64
+
+// levelEntry is a node in a level of the tree being bulk loaded,
+// along with the lowest key found in the subtree rooted at it
+type levelEntry struct {
+	pid uint32
+	key []byte
+}
+
+// BulkLoad builds the tree from the bottom up using the records returned
+// by iter, which must return the keys in sorted (ascending and unique)
+// order, and returns false once there are no more records. Leaves are
+// packed with records as they are read, and then each internal level is
+// built on top of the level below it until a single root remains. The
+// fill factor (in the range (0, 1]) controls how full each node is packed
+// (by both the number of keys and the encoded size). BulkLoad can only be
+// used on an empty tree.
+//
+// Nodes are written in batches as they are filled, and the meta page is
+// only pointed at the new root once every node has been written, so the
+// tree stays empty if the load fails part way through.
+func (t *BPTree) BulkLoad(iter func() ([]byte, []byte, bool), fill float64) error {
+	if fill <= 0 || fill > 1 {
+		return ErrBadFillFactor
+	}
+	t.begin()
+	root, err := t.load(t.root)
+	if err != nil {
+		return err
+	}
+	if !root.isLeaf || root.numKeys() > 0 {
+		return ErrTreeNotEmpty
+	}
+	var pids []uint32
+	var vals []*value
+	level, err := t.bulkLoadLeaves(iter, fill, &pids, &vals)
+	for err == nil && len(level) > 1 {
+		level, err = t.bulkLoadLevel(level, fill, &pids)
+	}
+	if err != nil {
+		// free up everything that was written before the error
+		t.begin()
+		for _, pid := range pids {
+			t.freed[pid] = true
+		}
+		for _, v := range vals {
+			_ = t.freeValue(v)
+		}
+		_ = t.commit()
+		return err
+	}
+	if len(level) == 0 {
+		return nil
+	}
+	// point the tree at the new root, and free the old one
+	t.setRoot(level[0].pid)
+	t.release(root)
+	return t.commit()
+}
+
+// bulkLoadLeaves packs the records returned by iter into a chain of
+// leaves, and returns the leaf level
+func (t *BPTree) bulkLoadLeaves(iter func() ([]byte, []byte, bool), fill float64, pids *[]uint32, vals *[]*value) ([]levelEntry, error) {
+	maxKeys := int(float64(t.order-1) * fill)
+	if maxKeys < 1 {
+		maxKeys = 1
+	}
+	maxSize := int(float64(t.capacity) * fill)
+	var level []levelEntry
+	var leaf *node
+	var last []byte
+	for {
+		k, data, ok := iter()
+		if !ok {
+			break
+		}
+		if len(k) > maxKeySize {
+			return nil, ErrKeyTooLarge
+		}
+		if last != nil && t.cmp(last, k) >= 0 {
+			return nil, ErrUnsortedKeys
+		}
+		last = append(last[:0], k...)
+		v, err := t.makeValue(data)
+		if err != nil {
+			return nil, err
+		}
+		if v.rid != nil {
+			*vals = append(*vals, v)
+		}
+		k = append([]byte(nil), k...)
+		// add the record to the current leaf, if it fits
+		if leaf != nil && leaf.numKeys() < maxKeys {
+			insertIntoLeaf(leaf, leaf.numKeys(), k, v)
+			if leaf.size() <= maxSize {
+				t.stage(leaf)
+				continue
+			}
+			removeFromLeaf(leaf, leaf.numKeys()-1)
+		}
+		// otherwise, start a new leaf and link it in. the leaf
+		// is given a brand new page, since large values written
+		// while the leaf is being filled may reuse free pages
+		next := newLeaf(t.pm.AllocatePage().PageID())
+		*pids = append(*pids, next.pid)
+		if leaf != nil {
+			leaf.next = next.pid
+			next.prev = leaf.pid
+			t.stage(leaf)
+		}
+		insertIntoLeaf(next, 0, k, v)
+		t.stage(next)
+		level = append(level, levelEntry{next.pid, k})
+		leaf = next
+		// write out the finished nodes every so often
+		if len(t.dirty) >= bulkBatchSize {
+			err = t.commit()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return level, t.commit()
+}
+
+// bulkLoadLevel packs the nodes of a level into internal nodes, and
+// returns the new level
+func (t *BPTree) bulkLoadLevel(below []levelEntry, fill float64, pids *[]uint32) ([]levelEntry, error) {
+	maxPtrs := int(float64(t.order) * fill)
+	if maxPtrs < 2 {
+		maxPtrs = 2
+	}
+	maxSize := int(float64(t.capacity) * fill)
+	var level []levelEntry
+	var n *node
+	for _, e := range below {
+		// add the child to the current node, if it fits
+		if n != nil && len(n.ptrs) < maxPtrs {
+			insertIntoNode(n, n.numKeys(), e.key, e.pid)
+			if n.size() <= maxSize {
+				t.stage(n)
+				continue
+			}
+			removeFromNode(n, len(n.ptrs)-1)
+		}
+		// otherwise, start a new node
+		n = newInternal(t.alloc())
+		*pids = append(*pids, n.pid)
+		n.ptrs = []uint32{e.pid}
+		t.stage(n)
+		level = append(level, levelEntry{n.pid, e.key})
+		// write out the finished nodes every so often
+		if len(t.dirty) >= bulkBatchSize {
+			err := t.commit()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return level, t.commit()
+}
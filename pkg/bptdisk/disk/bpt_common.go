@@ -0,0 +1,174 @@
+package disk
+
+import (
+	"bytes"
+
+	"github.com/cagnosolutions/pager/pkg/pager"
+)
+
+// Comparator compares two keys, and returns an integer comparing them. The
+// result will be 0 if a == b, -1 if a < b, and +1 if a > b.
+type Comparator func(a, b []byte) int
+
+// DefaultComparator orders keys lexicographically
+var DefaultComparator Comparator = bytes.Compare
+
+// BPTree represents a b+tree whose nodes live in the pages of a
+// pager.PageManager. Nodes are read from the PageManager as they are
+// needed, and every change made by a single tree operation is written
+// back in a single commit. Keys are ordered using the Comparator the
+// tree was opened with. Nodes are sized to fit the pages of the
+// PageManager.
+type BPTree struct {
+	pm       *pager.PageManager
+	root     uint32
+	order    int
+	capacity int // the largest a node can be once encoded
+	cmp      Comparator
+	// state for the operation in progress
+	dirty   map[uint32]*node
+	freed   map[uint32]bool
+	newRoot bool
+}
+
+// cut finds the appropriate place to split a node that is
+// too big. it is used both during insertion and deletion
+func cut(length int) int {
+	if length%2 == 0 {
+		return length / 2
+	}
+	return length/2 + 1
+}
+
+// begin resets the state for a new operation
+func (t *BPTree) begin() {
+	t.dirty = make(map[uint32]*node)
+	t.freed = make(map[uint32]bool)
+	t.newRoot = false
+}
+
+// load returns the node for the provided page id, from the
+// operation in progress or from the PageManager
+func (t *BPTree) load(pid uint32) (*node, error) {
+	// check the nodes changed by the operation in progress
+	if n, ok := t.dirty[pid]; ok {
+		return n, nil
+	}
+	// otherwise, read the node from its page
+	p, err := t.pm.ReadPage(pid)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := p.GetRecord(&pager.RecordID{PageID: pid, SlotID: 0})
+	if err != nil {
+		return nil, err
+	}
+	n := &node{pid: pid}
+	err = decodeNode(rec, n)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// stage marks the node as changed by the operation in progress
+func (t *BPTree) stage(n *node) {
+	t.dirty[n.pid] = n
+}
+
+// release frees the node's page once the operation in progress
+// is committed
+func (t *BPTree) release(n *node) {
+	delete(t.dirty, n.pid)
+	t.freed[n.pid] = true
+}
+
+// setRoot updates the root of the tree
+func (t *BPTree) setRoot(pid uint32) {
+	t.root = pid
+	t.newRoot = true
+}
+
+// alloc returns a page id for a new node, reusing free pages first
+func (t *BPTree) alloc() uint32 {
+	for _, pid := range t.pm.GetFreePageIDs() {
+		// skip any pages that are in use by the operation in progress
+		if _, ok := t.dirty[pid]; ok || t.freed[pid] {
+			continue
+		}
+		return pid
+	}
+	return t.pm.AllocatePage().PageID()
+}
+
+// commit writes every node changed by the operation in progress (and
+// the meta page, if the root has changed) in a single commit. Pages
+// freed by the operation are written as fresh empty pages.
+func (t *BPTree) commit() error {
+	ps := make([]*pager.Page, 0, len(t.dirty)+len(t.freed)+1)
+	if t.newRoot {
+		p, err := t.newRecordPage(metaPageID, encodeMeta(t.root))
+		if err != nil {
+			return err
+		}
+		ps = append(ps, p)
+	}
+	for _, n := range t.dirty {
+		b := make([]byte, n.size())
+		encodeNode(b, n)
+		p, err := t.newRecordPage(n.pid, b)
+		if err != nil {
+			return err
+		}
+		ps = append(ps, p)
+	}
+	for pid := range t.freed {
+		ps = append(ps, t.pm.NewPage(pid))
+	}
+	t.begin()
+	if len(ps) == 0 {
+		return nil
+	}
+	return t.pm.WritePages(ps)
+}
+
+// newRecordPage returns a new page holding the record provided
+func (t *BPTree) newRecordPage(pid uint32, rec []byte) (*pager.Page, error) {
+	p := t.pm.NewPage(pid)
+	_, err := p.AddRecord(rec)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// makeValue returns the value to store in a leaf for the data provided.
+// Large values are written to the PageManager as their own record.
+func (t *BPTree) makeValue(data []byte) (*value, error) {
+	if len(data) <= maxInlineValueSize {
+		v := &value{data: make([]byte, len(data))}
+		copy(v.data, data)
+		return v, nil
+	}
+	rid, err := t.pm.AddRecord(data)
+	if err != nil {
+		return nil, err
+	}
+	return &value{rid: rid}, nil
+}
+
+// readValue returns the data for a value stored in a leaf
+func (t *BPTree) readValue(v *value) ([]byte, error) {
+	if v.rid == nil {
+		return v.data, nil
+	}
+	return t.pm.GetRecord(v.rid)
+}
+
+// freeValue removes a value that was stored as its own record
+func (t *BPTree) freeValue(v *value) error {
+	if v == nil || v.rid == nil {
+		return nil
+	}
+	return t.pm.DelRecord(v.rid)
+}
@@ -0,0 +1,139 @@
+package disk
+
+// Cursor is used to iterate over the records in a tree in either
+// direction by walking the leaf chain. A new cursor is not positioned
+// on any record, so one of First, Last or Seek must be called before
+// it can be used. Modifying the tree invalidates any open cursors.
+//
+// The movement methods return false once the cursor runs off either
+// end of the tree, or if an error occurs. Err returns the error (if
+// any) that stopped the cursor.
+type Cursor struct {
+	t    *BPTree
+	leaf *node
+	i    int
+	err  error
+}
+
+// Cursor returns a new cursor for the tree
+func (t *BPTree) Cursor() *Cursor {
+	return &Cursor{t: t}
+}
+
+// Valid reports whether the cursor is positioned on a record
+func (c *Cursor) Valid() bool {
+	return c.err == nil && c.leaf != nil && c.i >= 0 && c.i < c.leaf.numKeys()
+}
+
+// Err returns the error that stopped the cursor, if any
+func (c *Cursor) Err() error {
+	return c.err
+}
+
+// First moves the cursor to the first (lowest) record in the tree. It
+// returns false if the tree is empty.
+func (c *Cursor) First() bool {
+	c.leaf, c.err = c.t.findFirstLeaf()
+	c.i = 0
+	return c.skipForward()
+}
+
+// Last moves the cursor to the last (highest) record in the tree. It
+// returns false if the tree is empty.
+func (c *Cursor) Last() bool {
+	c.leaf, c.err = c.t.findLastLeaf()
+	if c.leaf != nil {
+		c.i = c.leaf.numKeys() - 1
+	}
+	return c.skipBackward()
+}
+
+// Seek moves the cursor to the record with the provided key, or the
+// next record after it if the key does not exist. It returns false if
+// there are no records at or after the key.
+func (c *Cursor) Seek(k []byte) bool {
+	c.leaf, c.err = c.t.findLeaf(k)
+	if c.leaf != nil {
+		c.i, _ = c.leaf.search(k, c.t.cmp)
+	}
+	return c.skipForward()
+}
+
+// Next moves the cursor to the next record. It returns false once the
+// cursor moves past the last record.
+func (c *Cursor) Next() bool {
+	if !c.Valid() {
+		return false
+	}
+	c.i++
+	return c.skipForward()
+}
+
+// Prev moves the cursor to the previous record. It returns false once
+// the cursor moves past the first record.
+func (c *Cursor) Prev() bool {
+	if !c.Valid() {
+		return false
+	}
+	c.i--
+	return c.skipBackward()
+}
+
+// Key returns the key of the record the cursor is positioned on
+func (c *Cursor) Key() []byte {
+	if !c.Valid() {
+		return nil
+	}
+	return c.leaf.keys[c.i]
+}
+
+// Value returns the value of the record the cursor is positioned on
+func (c *Cursor) Value() ([]byte, error) {
+	if !c.Valid() {
+		return nil, c.err
+	}
+	return c.t.readValue(c.leaf.vals[c.i])
+}
+
+// skipForward moves the cursor on to the next leaf (and the next, and
+// so on) while the cursor is past the end of the current leaf
+func (c *Cursor) skipForward() bool {
+	for c.err == nil && c.leaf != nil && c.i >= c.leaf.numKeys() {
+		c.leaf, c.err = c.t.nextLeaf(c.leaf)
+		c.i = 0
+	}
+	return c.Valid()
+}
+
+// skipBackward moves the cursor back to the previous leaf (and the
+// previous, and so on) while the cursor is before the start of the
+// current leaf
+func (c *Cursor) skipBackward() bool {
+	for c.err == nil && c.leaf != nil && c.i < 0 {
+		c.leaf, c.err = c.t.prevLeaf(c.leaf)
+		if c.leaf != nil {
+			c.i = c.leaf.numKeys() - 1
+		}
+	}
+	return c.Valid()
+}
+
+// RangeBetween iterates over the records with keys from start (inclusive)
+// up to end (exclusive). A nil end key iterates to the end of the tree,
+// and returning false from iter stops the iteration.
+func (t *BPTree) RangeBetween(start, end []byte, iter func(k []byte, v []byte) bool) error {
+	c := t.Cursor()
+	for ok := c.Seek(start); ok; ok = c.Next() {
+		if end != nil && t.cmp(c.Key(), end) >= 0 {
+			return nil
+		}
+		v, err := c.Value()
+		if err != nil {
+			return err
+		}
+		if !iter(c.Key(), v) {
+			return nil
+		}
+	}
+	return c.Err()
+}
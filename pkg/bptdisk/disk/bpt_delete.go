@@ -0,0 +1,133 @@
+package disk
+
+// delete is the "master" deletion function. it removes the key and the
+// associated value from the tree, and returns the removed value (or nil
+// if the key could not be found).
+//
+// nodes are not merged or rebalanced when they underflow, instead a node
+// is only removed from the tree once it is completely empty, and its page
+// is freed so that it can be reused.
+func (t *BPTree) delete(k []byte) (*value, error) {
+	t.begin()
+	// find the leaf the key belongs in
+	leaf, path, err := t.findPath(k)
+	if err != nil {
+		return nil, err
+	}
+	i, found := leaf.search(k, t.cmp)
+	if !found {
+		return nil, nil
+	}
+	// remove the key and value from the leaf
+	v := leaf.vals[i]
+	removeFromLeaf(leaf, i)
+	// if the leaf still has keys in it, or it is the root, we are done
+	if leaf.numKeys() > 0 || len(path) == 0 {
+		t.stage(leaf)
+		return v, t.commit()
+	}
+	// otherwise, the leaf is empty and needs to be removed
+	err = t.removeLeaf(leaf)
+	if err != nil {
+		return nil, err
+	}
+	err = t.removeChild(path)
+	if err != nil {
+		return nil, err
+	}
+	// and finally, shrink the tree if we can
+	err = t.adjustRoot()
+	if err != nil {
+		return nil, err
+	}
+	return v, t.commit()
+}
+
+// removeFromLeaf removes the key and value at index i from the leaf
+func removeFromLeaf(leaf *node, i int) {
+	copy(leaf.keys[i:], leaf.keys[i+1:])
+	leaf.keys = leaf.keys[:len(leaf.keys)-1]
+	copy(leaf.vals[i:], leaf.vals[i+1:])
+	leaf.vals[len(leaf.vals)-1] = nil
+	leaf.vals = leaf.vals[:len(leaf.vals)-1]
+}
+
+// removeFromNode removes the child pointer at index i (along with the
+// key to the left of it, or to the right of it for the first child)
+func removeFromNode(n *node, i int) {
+	if n.numKeys() > 0 {
+		j := i - 1
+		if j < 0 {
+			j = 0
+		}
+		copy(n.keys[j:], n.keys[j+1:])
+		n.keys = n.keys[:len(n.keys)-1]
+	}
+	copy(n.ptrs[i:], n.ptrs[i+1:])
+	n.ptrs = n.ptrs[:len(n.ptrs)-1]
+}
+
+// removeLeaf unlinks an empty leaf from the leaf chain and frees it
+func (t *BPTree) removeLeaf(leaf *node) error {
+	if leaf.prev != nilPage {
+		prev, err := t.load(leaf.prev)
+		if err != nil {
+			return err
+		}
+		prev.next = leaf.next
+		t.stage(prev)
+	}
+	if leaf.next != nilPage {
+		next, err := t.load(leaf.next)
+		if err != nil {
+			return err
+		}
+		next.prev = leaf.prev
+		t.stage(next)
+	}
+	t.release(leaf)
+	return nil
+}
+
+// removeChild removes the child at the end of the path from its parent.
+// if the parent is left with no children, it is removed as well.
+func (t *BPTree) removeChild(path []pathEntry) error {
+	for len(path) > 0 {
+		parent, i := path[len(path)-1].n, path[len(path)-1].i
+		removeFromNode(parent, i)
+		if len(parent.ptrs) > 0 {
+			t.stage(parent)
+			return nil
+		}
+		// the parent is now empty, if it is the root then
+		// the whole tree is empty, so it becomes an empty leaf
+		if len(path) == 1 {
+			root := newLeaf(parent.pid)
+			t.stage(root)
+			return nil
+		}
+		// otherwise, remove it from its parent
+		t.release(parent)
+		path = path[:len(path)-1]
+	}
+	return nil
+}
+
+// adjustRoot shrinks the tree while the root is an internal node with
+// only a single child
+func (t *BPTree) adjustRoot() error {
+	root, err := t.load(t.root)
+	if err != nil {
+		return err
+	}
+	for !root.isLeaf && len(root.ptrs) == 1 {
+		child, err := t.load(root.ptrs[0])
+		if err != nil {
+			return err
+		}
+		t.release(root)
+		t.setRoot(child.pid)
+		root = child
+	}
+	return nil
+}
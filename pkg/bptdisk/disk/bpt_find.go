@@ -0,0 +1,129 @@
+package disk
+
+import (
+	"sort"
+)
+
+// pathEntry is a single step taken on the path from the root to a leaf,
+// it records the node along with the index of the child pointer taken
+type pathEntry struct {
+	n *node
+	i int
+}
+
+// childIndex returns the index of the child pointer to follow for the
+// provided key in an internal node
+func (n *node) childIndex(k []byte, cmp Comparator) int {
+	i := 0
+	for i < n.numKeys() {
+		if cmp(k, n.keys[i]) >= 0 {
+			i++
+		} else {
+			break
+		}
+	}
+	return i
+}
+
+// search returns the index of the key in the node, or where the key
+// would be inserted, along with a boolean reporting if it was found
+func (n *node) search(k []byte, cmp Comparator) (int, bool) {
+	i := sort.Search(n.numKeys(), func(i int) bool {
+		return cmp(n.keys[i], k) >= 0
+	})
+	return i, i < n.numKeys() && cmp(n.keys[i], k) == 0
+}
+
+// closest returns the index of the closest matching key for the
+// provided key in a leaf node
+func (n *node) closest(k []byte, cmp Comparator) (int, bool) {
+	if !n.isLeaf || n.numKeys() == 0 {
+		return -1, false
+	}
+	i := 0
+	for ; i < n.numKeys(); i++ {
+		if cmp(k, n.keys[i]) < 0 {
+			break
+		}
+	}
+	if i > 0 {
+		i--
+	}
+	return i, true
+}
+
+// findPath traces the path from the root to a leaf, searching by key.
+// it returns the leaf containing the given key, along with the path
+// taken to get there
+func (t *BPTree) findPath(k []byte) (*node, []pathEntry, error) {
+	var path []pathEntry
+	c, err := t.load(t.root)
+	if err != nil {
+		return nil, nil, err
+	}
+	for !c.isLeaf {
+		i := c.childIndex(k, t.cmp)
+		path = append(path, pathEntry{c, i})
+		c, err = t.load(c.ptrs[i])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	// c is the found leaf node
+	return c, path, nil
+}
+
+// findLeaf traces the path from the root to a leaf, searching by key.
+// findLeaf returns the leaf containing the given key
+func (t *BPTree) findLeaf(k []byte) (*node, error) {
+	leaf, _, err := t.findPath(k)
+	return leaf, err
+}
+
+// findFirstLeaf traces the path from the root to the leftmost leaf in the tree
+func (t *BPTree) findFirstLeaf() (*node, error) {
+	c, err := t.load(t.root)
+	if err != nil {
+		return nil, err
+	}
+	for !c.isLeaf {
+		c, err = t.load(c.ptrs[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// findLastLeaf traces the path from the root to the rightmost leaf in the tree
+func (t *BPTree) findLastLeaf() (*node, error) {
+	c, err := t.load(t.root)
+	if err != nil {
+		return nil, err
+	}
+	for !c.isLeaf {
+		c, err = t.load(c.ptrs[c.numKeys()])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// nextLeaf returns the next leaf in the chain (to the right) of the
+// current leaf, or nil if it is the last leaf
+func (t *BPTree) nextLeaf(n *node) (*node, error) {
+	if n.next == nilPage {
+		return nil, nil
+	}
+	return t.load(n.next)
+}
+
+// prevLeaf returns the previous leaf in the chain (to the left) of the
+// current leaf, or nil if it is the first leaf
+func (t *BPTree) prevLeaf(n *node) (*node, error) {
+	if n.prev == nilPage {
+		return nil, nil
+	}
+	return t.load(n.prev)
+}
@@ -0,0 +1,169 @@
+package disk
+
+// insert is the "master" insertion function. it inserts a key and an
+// associated value into the tree causing the tree to be adjusted however
+// necessary to maintain the tree's properties. if unique is set, then an
+// existing key is left alone. it returns the value that was replaced (if
+// any) and a boolean reporting if the key already existed.
+func (t *BPTree) insert(k []byte, v *value, unique bool) (*value, bool, error) {
+	t.begin()
+	// find the leaf the key belongs in
+	leaf, path, err := t.findPath(k)
+	if err != nil {
+		return nil, false, err
+	}
+	i, found := leaf.search(k, t.cmp)
+	if found {
+		// the key already exists in this tree
+		if unique {
+			return nil, true, nil
+		}
+		// so we can simply proceed to just update the value
+		old := leaf.vals[i]
+		leaf.vals[i] = v
+		t.stage(leaf)
+		err = t.splitIfNeeded(leaf, path)
+		if err != nil {
+			return nil, true, err
+		}
+		return old, true, t.commit()
+	}
+	// otherwise, insert (a copy of) the new key and value into the leaf
+	insertIntoLeaf(leaf, i, append([]byte(nil), k...), v)
+	t.stage(leaf)
+	// and split it, if it has grown too large
+	err = t.splitIfNeeded(leaf, path)
+	if err != nil {
+		return nil, false, err
+	}
+	return nil, false, t.commit()
+}
+
+// insertIntoLeaf inserts the key and value into the leaf at index i
+func insertIntoLeaf(leaf *node, i int, k []byte, v *value) {
+	leaf.keys = append(leaf.keys, nil)
+	copy(leaf.keys[i+1:], leaf.keys[i:])
+	leaf.keys[i] = k
+	leaf.vals = append(leaf.vals, nil)
+	copy(leaf.vals[i+1:], leaf.vals[i:])
+	leaf.vals[i] = v
+}
+
+// insertIntoNode inserts the key and right child pointer into the
+// internal node, to the right of the child pointer at index i
+func insertIntoNode(n *node, i int, k []byte, right uint32) {
+	n.keys = append(n.keys, nil)
+	copy(n.keys[i+1:], n.keys[i:])
+	n.keys[i] = k
+	n.ptrs = append(n.ptrs, 0)
+	copy(n.ptrs[i+2:], n.ptrs[i+1:])
+	n.ptrs[i+1] = right
+}
+
+// isFull reports whether the node has grown too large, and needs to be split
+func (t *BPTree) isFull(n *node) bool {
+	return n.numKeys() > t.order-1 || n.size() > t.capacity
+}
+
+// splitIfNeeded splits the leaf if it has grown too large
+func (t *BPTree) splitIfNeeded(leaf *node, path []pathEntry) error {
+	if !t.isFull(leaf) {
+		return nil
+	}
+	return t.splitLeaf(leaf, path)
+}
+
+// splitIndex returns the index to split the node at, so that
+// both halves are roughly the same size
+func (t *BPTree) splitIndex(n *node) int {
+	// if the node has too many keys, split by count
+	if n.size() <= t.capacity {
+		return cut(n.numKeys())
+	}
+	// otherwise, split by size (ignoring the shared key prefix,
+	// which can only make each half smaller)
+	var total int
+	for i := range n.keys {
+		total += n.entrySize(i)
+	}
+	var sz int
+	for i := range n.keys {
+		sz += n.entrySize(i)
+		if sz >= total/2 {
+			if i == 0 {
+				return 1
+			}
+			return i
+		}
+	}
+	return cut(n.numKeys())
+}
+
+// splitLeaf splits the leaf into two, moving the upper half of the keys
+// into a new leaf to the right, and inserts the new leaf into the parent
+func (t *BPTree) splitLeaf(leaf *node, path []pathEntry) error {
+	split := t.splitIndex(leaf)
+	// create the new leaf, and move the upper half into it
+	right := newLeaf(t.alloc())
+	right.keys = append(right.keys, leaf.keys[split:]...)
+	right.vals = append(right.vals, leaf.vals[split:]...)
+	leaf.keys = append([][]byte(nil), leaf.keys[:split]...)
+	leaf.vals = append([]*value(nil), leaf.vals[:split]...)
+	// link the new leaf into the leaf chain
+	right.next = leaf.next
+	right.prev = leaf.pid
+	if leaf.next != nilPage {
+		next, err := t.load(leaf.next)
+		if err != nil {
+			return err
+		}
+		next.prev = right.pid
+		t.stage(next)
+	}
+	leaf.next = right.pid
+	t.stage(leaf)
+	t.stage(right)
+	// and insert the new leaf into the parent
+	return t.insertIntoParent(path, leaf, right.keys[0], right)
+}
+
+// insertIntoParent inserts a new node (right) into the tree, to the right
+// of the left node, splitting the parent if need be
+func (t *BPTree) insertIntoParent(path []pathEntry, left *node, k []byte, right *node) error {
+	// if there is no parent, then we need a new root
+	if len(path) == 0 {
+		root := newInternal(t.alloc())
+		root.keys = [][]byte{k}
+		root.ptrs = []uint32{left.pid, right.pid}
+		t.stage(root)
+		t.setRoot(root.pid)
+		return nil
+	}
+	// otherwise, insert into the parent
+	parent, i := path[len(path)-1].n, path[len(path)-1].i
+	insertIntoNode(parent, i, k, right.pid)
+	t.stage(parent)
+	if !t.isFull(parent) {
+		return nil
+	}
+	// the parent is too large and needs to be split
+	return t.splitNode(parent, path[:len(path)-1])
+}
+
+// splitNode splits the internal node into two, moving the upper half of
+// the keys into a new node to the right and pushing the middle key up
+// into the parent
+func (t *BPTree) splitNode(n *node, path []pathEntry) error {
+	split := t.splitIndex(n) - 1
+	k := n.keys[split]
+	// create the new node, and move the upper half into it
+	right := newInternal(t.alloc())
+	right.keys = append(right.keys, n.keys[split+1:]...)
+	right.ptrs = append(right.ptrs, n.ptrs[split+1:]...)
+	n.keys = append([][]byte(nil), n.keys[:split]...)
+	n.ptrs = append([]uint32(nil), n.ptrs[:split+1]...)
+	t.stage(n)
+	t.stage(right)
+	// and insert the new node into the parent
+	return t.insertIntoParent(path, n, k, right)
+}
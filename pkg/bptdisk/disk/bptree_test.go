@@ -0,0 +1,385 @@
+package disk
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/cagnosolutions/pager/pkg/pager"
+)
+
+const count = 500
+
+func makeKey(i int) []byte {
+	return []byte(fmt.Sprintf("key-%.6d", i))
+}
+
+func makeVal(i int) []byte {
+	return []byte(fmt.Sprintf("{\"id\":%.6d,\"key\":\"key-%.6d\",\"value\":\"val-%.6d\"}", i, i, i))
+}
+
+// openTree opens a tree with a small order, so the tests
+// end up splitting (and removing) plenty of nodes
+func openTree(t *testing.T, path string) *BPTree {
+	tree, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %s", err)
+	}
+	tree.order = 4
+	return tree
+}
+
+func TestBPTree_PutGetDel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bptree.db")
+	tree := openTree(t, path)
+	keys := rand.New(rand.NewSource(1)).Perm(count)
+	for _, i := range keys {
+		existing, err := tree.Put(makeKey(i*2), makeVal(i))
+		if err != nil || existing {
+			t.Fatalf("putting: %v, %v", existing, err)
+		}
+	}
+	// a large value gets stored as its own record
+	large := bytes.Repeat([]byte("large-value+"), 2000)
+	_, err := tree.Put(makeKey(count*2), large)
+	if err != nil {
+		t.Fatalf("putting large value: %s", err)
+	}
+	err = tree.Close()
+	if err != nil {
+		t.Fatalf("close: %s", err)
+	}
+	// reopen and check everything made it
+	tree = openTree(t, path)
+	defer tree.Close()
+	n, err := tree.Len()
+	if err != nil || n != count+1 {
+		t.Fatalf("len: expected %d, got %d (%v)", count+1, n, err)
+	}
+	for i := 0; i < count; i++ {
+		k, v, err := tree.Get(makeKey(i * 2))
+		if err != nil || !bytes.Equal(k, makeKey(i*2)) || !bytes.Equal(v, makeVal(i)) {
+			t.Fatalf("getting %d: got %s, %q, %v", i*2, k, v, err)
+		}
+		ok, err := tree.Has(makeKey(i*2 + 1))
+		if err != nil || ok {
+			t.Fatalf("has %d: got %v, %v", i*2+1, ok, err)
+		}
+	}
+	_, v, err := tree.Get(makeKey(count * 2))
+	if err != nil || !bytes.Equal(v, large) {
+		t.Fatalf("getting large value: got len=%d, %v", len(v), err)
+	}
+	// range should be in order
+	var last []byte
+	var seen int
+	err = tree.Range(func(k []byte, v []byte) bool {
+		if seen > 0 && bytes.Compare(k, last) <= 0 {
+			t.Errorf("range: keys out of order %s <= %s", k, last)
+		}
+		last = k
+		seen++
+		return true
+	})
+	if err != nil || seen != count+1 {
+		t.Fatalf("range: saw %d, %v", seen, err)
+	}
+	// min, max and closest
+	if k, _, _ := tree.Min(); !bytes.Equal(k, makeKey(0)) {
+		t.Errorf("min: expected %s, got %s", makeKey(0), k)
+	}
+	if k, _, _ := tree.Max(); !bytes.Equal(k, makeKey(count*2)) {
+		t.Errorf("max: expected %s, got %s", makeKey(count*2), k)
+	}
+	if k, _, _ := tree.GetClosest(makeKey(101)); !bytes.Equal(k, makeKey(100)) {
+		t.Errorf("closest: expected %s, got %s", makeKey(100), k)
+	}
+	// delete everything
+	pages := tree.pm.PageCount()
+	for _, i := range keys {
+		k, v, err := tree.Del(makeKey(i * 2))
+		if err != nil || !bytes.Equal(k, makeKey(i*2)) || !bytes.Equal(v, makeVal(i)) {
+			t.Fatalf("deleting %d: got %s, %q, %v", i*2, k, v, err)
+		}
+	}
+	_, v, err = tree.Del(makeKey(count * 2))
+	if err != nil || !bytes.Equal(v, large) {
+		t.Fatalf("deleting large value: %v", err)
+	}
+	n, err = tree.Len()
+	if err != nil || n != 0 {
+		t.Fatalf("len: expected 0, got %d (%v)", n, err)
+	}
+	// the freed pages should get reused
+	for _, i := range keys {
+		_, err := tree.Put(makeKey(i), makeVal(i))
+		if err != nil {
+			t.Fatalf("putting: %s", err)
+		}
+	}
+	if tree.pm.PageCount() > pages {
+		t.Errorf("expected freed pages to be reused, had %d pages, now %d", pages, tree.pm.PageCount())
+	}
+}
+
+func TestBPTree_Add(t *testing.T) {
+	tree := openTree(t, filepath.Join(t.TempDir(), "bptree-add.db"))
+	defer tree.Close()
+	err := tree.Add([]byte("one"), []byte("first"))
+	if err != nil {
+		t.Fatalf("add: %s", err)
+	}
+	err = tree.Add([]byte("one"), []byte("second"))
+	if err != nil {
+		t.Fatalf("add: %s", err)
+	}
+	_, v, err := tree.Get([]byte("one"))
+	if err != nil || string(v) != "first" {
+		t.Errorf("get: expected %q, got %q (%v)", "first", v, err)
+	}
+}
+
+func TestBPTree_LongKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bptree-keys.db")
+	tree, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %s", err)
+	}
+	// long keys sharing a long prefix, so internal nodes
+	// have to be split by size and not by count
+	prefix := bytes.Repeat([]byte("p"), 400)
+	longKey := func(i int) []byte {
+		return append(append([]byte(nil), prefix...), makeKey(i)...)
+	}
+	for _, i := range rand.New(rand.NewSource(2)).Perm(count * 4) {
+		_, err := tree.Put(longKey(i), makeVal(i))
+		if err != nil {
+			t.Fatalf("putting: %s", err)
+		}
+	}
+	_, err = tree.Put(bytes.Repeat([]byte("k"), maxKeySize+1), nil)
+	if err != ErrKeyTooLarge {
+		t.Errorf("put: expected %v, got %v", ErrKeyTooLarge, err)
+	}
+	err = tree.Close()
+	if err != nil {
+		t.Fatalf("close: %s", err)
+	}
+	tree, err = Open(path)
+	if err != nil {
+		t.Fatalf("open: %s", err)
+	}
+	defer tree.Close()
+	for i := 0; i < count*4; i++ {
+		_, v, err := tree.Get(longKey(i))
+		if err != nil || !bytes.Equal(v, makeVal(i)) {
+			t.Fatalf("getting %d: got %q, %v", i, v, err)
+		}
+	}
+}
+
+func TestBPTree_Comparator(t *testing.T) {
+	reverse := func(a, b []byte) int {
+		return bytes.Compare(b, a)
+	}
+	tree, err := OpenWithComparator(filepath.Join(t.TempDir(), "bptree-cmp.db"), reverse)
+	if err != nil {
+		t.Fatalf("open: %s", err)
+	}
+	defer tree.Close()
+	tree.order = 4
+	for i := 0; i < count; i++ {
+		_, err := tree.Put(makeKey(i), makeVal(i))
+		if err != nil {
+			t.Fatalf("putting: %s", err)
+		}
+	}
+	i := count - 1
+	err = tree.Range(func(k []byte, v []byte) bool {
+		if !bytes.Equal(k, makeKey(i)) {
+			t.Errorf("range: expected %s, got %s", makeKey(i), k)
+		}
+		i--
+		return true
+	})
+	if err != nil || i != -1 {
+		t.Fatalf("range: stopped at %d, %v", i, err)
+	}
+}
+
+func TestBPTree_Cursor(t *testing.T) {
+	tree := openTree(t, filepath.Join(t.TempDir(), "bptree-cursor.db"))
+	defer tree.Close()
+	c := tree.Cursor()
+	if c.First() || c.Last() || c.Seek(makeKey(0)) {
+		t.Errorf("cursor: expected an empty tree")
+	}
+	for _, i := range rand.New(rand.NewSource(3)).Perm(count) {
+		_, err := tree.Put(makeKey(i*2), makeVal(i))
+		if err != nil {
+			t.Fatalf("putting: %s", err)
+		}
+	}
+	// walk forward
+	i := 0
+	for ok := c.First(); ok; ok = c.Next() {
+		v, err := c.Value()
+		if !bytes.Equal(c.Key(), makeKey(i*2)) || !bytes.Equal(v, makeVal(i)) || err != nil {
+			t.Fatalf("next: expected %s, got %s, %q, %v", makeKey(i*2), c.Key(), v, err)
+		}
+		i++
+	}
+	if c.Err() != nil || i != count {
+		t.Fatalf("next: stopped at %d, %v", i, c.Err())
+	}
+	// walk backward
+	i = count - 1
+	for ok := c.Last(); ok; ok = c.Prev() {
+		if !bytes.Equal(c.Key(), makeKey(i*2)) {
+			t.Fatalf("prev: expected %s, got %s", makeKey(i*2), c.Key())
+		}
+		i--
+	}
+	if c.Err() != nil || i != -1 {
+		t.Fatalf("prev: stopped at %d, %v", i, c.Err())
+	}
+	// seek to a missing key lands on the next key
+	if !c.Seek(makeKey(101)) || !bytes.Equal(c.Key(), makeKey(102)) {
+		t.Fatalf("seek: expected %s, got %s", makeKey(102), c.Key())
+	}
+	if !c.Prev() || !bytes.Equal(c.Key(), makeKey(100)) {
+		t.Fatalf("prev: expected %s, got %s", makeKey(100), c.Key())
+	}
+	if c.Seek(makeKey(count * 2)) {
+		t.Errorf("seek: expected no key, got %s", c.Key())
+	}
+	// bounded range
+	i = 50
+	err := tree.RangeBetween(makeKey(100), makeKey(200), func(k []byte, v []byte) bool {
+		if !bytes.Equal(k, makeKey(i*2)) {
+			t.Errorf("range: expected %s, got %s", makeKey(i*2), k)
+		}
+		i++
+		return true
+	})
+	if err != nil || i != 100 {
+		t.Fatalf("range: stopped at %d, %v", i, err)
+	}
+}
+
+func TestBPTree_BulkLoad(t *testing.T) {
+	for _, order := range []int{4, maxOrder(pager.MaxRecordSize)} {
+		for _, fill := range []float64{0.5, 1} {
+			path := filepath.Join(t.TempDir(), "bptree-bulk.db")
+			tree := openTree(t, path)
+			tree.order = order
+			i := 0
+			err := tree.BulkLoad(func() ([]byte, []byte, bool) {
+				if i == count {
+					return nil, nil, false
+				}
+				i++
+				return makeKey(i - 1), makeVal(i - 1), true
+			}, fill)
+			if err != nil {
+				t.Fatalf("bulk load: %s", err)
+			}
+			err = tree.Close()
+			if err != nil {
+				t.Fatalf("close: %s", err)
+			}
+			tree = openTree(t, path)
+			n, err := tree.Len()
+			if err != nil || n != count {
+				t.Fatalf("len: expected %d, got %d (%v)", count, n, err)
+			}
+			for i := 0; i < count; i++ {
+				_, v, err := tree.Get(makeKey(i))
+				if err != nil || !bytes.Equal(v, makeVal(i)) {
+					t.Fatalf("getting %d: got %q, %v", i, v, err)
+				}
+			}
+			// the tree should behave normally after being loaded
+			for i := 0; i < count; i += 2 {
+				_, err := tree.Put(makeKey(i), makeVal(i+1))
+				if err != nil {
+					t.Fatalf("putting: %s", err)
+				}
+				_, _, err = tree.Del(makeKey(i + 1))
+				if err != nil {
+					t.Fatalf("deleting: %s", err)
+				}
+			}
+			n, err = tree.Len()
+			if err != nil || n != count/2 {
+				t.Fatalf("len: expected %d, got %d (%v)", count/2, n, err)
+			}
+			tree.Close()
+		}
+	}
+	// bulk loading unsorted keys should fail, and leave the tree empty
+	tree := openTree(t, filepath.Join(t.TempDir(), "bptree-unsorted.db"))
+	defer tree.Close()
+	keys := []int{1, 3, 2}
+	err := tree.BulkLoad(func() ([]byte, []byte, bool) {
+		if len(keys) == 0 {
+			return nil, nil, false
+		}
+		k := keys[0]
+		keys = keys[1:]
+		return makeKey(k), makeVal(k), true
+	}, 1)
+	if err != ErrUnsortedKeys {
+		t.Fatalf("bulk load: expected %v, got %v", ErrUnsortedKeys, err)
+	}
+	if n, err := tree.Len(); err != nil || n != 0 {
+		t.Fatalf("len: expected 0, got %d (%v)", n, err)
+	}
+}
+
+func TestBPTree_PageSize(t *testing.T) {
+	for _, size := range []int{pager.MinPageSize, 64 << 10} {
+		path := filepath.Join(t.TempDir(), "bptree-pagesize.db")
+		pm, err := pager.OpenPageManagerWithPageSize(path, size)
+		if err != nil {
+			t.Fatalf("open page manager: %s", err)
+		}
+		tree, err := NewBPTree(pm)
+		if err != nil {
+			t.Fatalf("new tree: %s", err)
+		}
+		if tree.capacity != pm.MaxRecordSize() || tree.order != maxOrder(pm.MaxRecordSize()) {
+			t.Fatalf("expected nodes to fit a %d byte page, got capacity=%d order=%d", size, tree.capacity, tree.order)
+		}
+		// long keys, so the nodes get split by size
+		long := func(i int) []byte {
+			return append(bytes.Repeat([]byte{'k'}, maxKeySize-10), makeKey(i)...)
+		}
+		for i := 0; i < count; i++ {
+			_, err = tree.Put(long(i), makeVal(i))
+			if err != nil {
+				t.Fatalf("put: %s", err)
+			}
+		}
+		err = tree.Close()
+		if err != nil {
+			t.Fatalf("close: %s", err)
+		}
+		tree, err = Open(path)
+		if err != nil {
+			t.Fatalf("open: %s", err)
+		}
+		for i := 0; i < count; i++ {
+			_, v, err := tree.Get(long(i))
+			if err != nil || !bytes.Equal(v, makeVal(i)) {
+				t.Fatalf("get %d: got %q, %v", i, v, err)
+			}
+		}
+		err = tree.Close()
+		if err != nil {
+			t.Fatalf("close: %s", err)
+		}
+	}
+}
@@ -1,6 +1,48 @@
 package disk
 
 /*
+	Every node in the tree lives in its own pager.Page, encoded as the
+	one and only record in the Page (slot 0). The first Page in the
+	PageManager (page 0) holds the tree meta record.
 
+	meta record
+	-----------
+	bytes 0-4   = magic ("BPT1")
+	bytes 4-6   = version
+	bytes 6-10  = root node pointer (page)
 
- */
+	node header
+	-----------
+	byte  0     = isLeaf
+	byte  1     = unused
+	bytes 2-4   = num keys
+	bytes 4-8   = next leaf pointer (page)
+	bytes 8-12  = prev leaf pointer (page)
+	bytes 12-14 = shared key prefix length
+	bytes 14-.. = shared key prefix
+
+	keys are stored prefix compressed, the prefix shared by every key in
+	the node is stored once in the header, and each key entry only holds
+	the remaining suffix of the key
+	bytes 0-2   = key suffix length
+	bytes 2-... = key suffix
+
+	internal node body
+	------------------
+	bytes 0-4   = child pointer 0 (page)
+	followed by num keys entries, each entry is
+	key suffix, followed by
+	bytes 0-4   = child pointer (page)
+
+	leaf node body
+	--------------
+	followed by num keys entries, each entry is
+	key suffix, followed by
+	byte  0     = value kind (inline or record)
+	bytes 1-3   = value length
+	bytes 3-... = value data
+
+	values that are larger than maxInlineValueSize are stored as their
+	own record in the PageManager (spilling over into overflow pages if
+	need be) and the leaf entry holds the encoded pager.RecordID.
+*/
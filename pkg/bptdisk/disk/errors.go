@@ -0,0 +1,13 @@
+package disk
+
+import "errors"
+
+var (
+	ErrBadMetaPage = errors.New("bptree: bad or missing tree meta page")
+	ErrBadNode     = errors.New("bptree: bad or corrupted node")
+	ErrKeyTooLarge = errors.New("bptree: key is too large")
+
+	ErrTreeNotEmpty  = errors.New("bptree: tree is not empty")
+	ErrUnsortedKeys  = errors.New("bptree: keys are not sorted, or are not unique")
+	ErrBadFillFactor = errors.New("bptree: fill factor must be greater than 0 and at most 1")
+)
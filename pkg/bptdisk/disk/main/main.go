@@ -1,14 +1,33 @@
 package main
 
 import (
-	"encoding/hex"
 	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
 	"github.com/cagnosolutions/pager/pkg/bptdisk/disk"
 )
 
 func main() {
-	n := disk.NewNode()
-	b := make([]byte, 4096)
-	disk.Encode(b, n)
-	fmt.Println(hex.Dump(b))
+	path := filepath.Join(os.TempDir(), "bptdisk-example.db")
+	t, err := disk.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer t.Close()
+	for i := 0; i < 16; i++ {
+		k := []byte(fmt.Sprintf("key-%.6d", i))
+		_, err = t.Put(k, []byte(fmt.Sprintf("val-%.6d", i)))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	err = t.Range(func(k []byte, v []byte) bool {
+		fmt.Printf("%s => %q\n", k, v)
+		return true
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
 }
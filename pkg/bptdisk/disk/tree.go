@@ -2,44 +2,152 @@ package disk
 
 import (
 	"encoding/binary"
+
+	"github.com/cagnosolutions/pager/pkg/pager"
 )
 
 const (
-	order    = 255
-	keylen   = 12
-	pageSize = 4096
+	// metaPageID is the page the tree meta record lives in
+	metaPageID = 0
+	// metaMagic identifies a page holding the tree meta record
+	metaMagic = 0x31545042 // "BPT1"
+	// metaVersion is the current tree format version
+	metaVersion = 2
+	// metaSize is the size of the encoded meta record
+	metaSize = 10
+
+	// nilPage is used for node pointers that do not point anywhere
+	nilPage = ^uint32(0)
+
+	// nodeHeaderSize is the size of the encoded node header, not
+	// including the shared key prefix
+	nodeHeaderSize = 14
+	// keyHeaderSize is the size of the encoded key suffix length
+	keyHeaderSize = 2
+	// leafEntryHeaderSize is the size of an encoded leaf entry,
+	// not including the key suffix or the value data
+	leafEntryHeaderSize = keyHeaderSize + 3
+	// internalEntryHeaderSize is the size of an encoded internal
+	// node entry, not including the key suffix
+	internalEntryHeaderSize = keyHeaderSize + 4
+	// maxKeySize is the largest key that can be stored in the tree
+	maxKeySize = 512
+	// maxInlineValueSize is the largest value that will be stored
+	// directly in a leaf, larger values get stored as a record
+	maxInlineValueSize = 1 << 10
+	// recordIDSize is the size of an encoded pager.RecordID
+	recordIDSize = 6
+
+	// maxNodeKeys is the largest number of keys that can be
+	// encoded in a single node
+	maxNodeKeys = 1<<16 - 1
 )
 
-type tree struct {
-	root *node
+// maxOrder returns the largest number of children that could ever
+// fit in an internal node of the provided capacity (nodes are also
+// split once they grow larger than the capacity)
+func maxOrder(capacity int) int {
+	order := (capacity-nodeHeaderSize-4)/internalEntryHeaderSize + 1
+	if order > maxNodeKeys+1 {
+		return maxNodeKeys + 1
+	}
+	return order
 }
 
-func NewNode() *node {
-	n := &node{
-		nkeys:  254,
-		keys:   [254][12]byte{},
-		ptrs:   [255]uint32{},
-		parent: 99999,
-		isLeaf: false,
-	}
-	c := [12]byte{'k', '-', 'd', 'e', 'a', 'd', 'b', 'e', 'e', 'f', '+', '+'}
-	for i := range n.keys {
-		n.keys[i] = c
-		n.ptrs[i] = uint32(i)
-	}
-	return n
+const (
+	valueInline uint8 = iota
+	valueRecord
+)
+
+// value is the value stored in a leaf entry. It is either held
+// inline (data) or is stored as its own record (rid).
+type value struct {
+	data []byte
+	rid  *pager.RecordID
 }
 
-func Encode(b []byte, n *node) {
-	encodeNode(b, n)
+// size returns the encoded size of the value
+func (v *value) size() int {
+	if v.rid != nil {
+		return recordIDSize
+	}
+	return len(v.data)
 }
 
+// node represents a node of the BPTree. Internal nodes hold
+// numKeys+1 child pointers (ptrs) and leaf nodes hold numKeys
+// values (vals). Leaves are linked together using next and prev.
 type node struct {
-	nkeys  uint16 // max keys per node 65535
-	keys   [order - 1][keylen]byte
-	ptrs   [order]uint32
-	parent uint32
+	pid    uint32
 	isLeaf bool
+	keys   [][]byte
+	ptrs   []uint32
+	vals   []*value
+	next   uint32
+	prev   uint32
+}
+
+// newLeaf returns a new, empty leaf node
+func newLeaf(pid uint32) *node {
+	return &node{
+		pid:    pid,
+		isLeaf: true,
+		next:   nilPage,
+		prev:   nilPage,
+	}
+}
+
+// newInternal returns a new, empty internal node
+func newInternal(pid uint32) *node {
+	return &node{
+		pid:  pid,
+		next: nilPage,
+		prev: nilPage,
+	}
+}
+
+// numKeys returns the number of keys in the node
+func (n *node) numKeys() int {
+	return len(n.keys)
+}
+
+// prefix returns the prefix shared by every key in the node. The
+// shared prefix is only stored once when the node is encoded.
+func (n *node) prefix() []byte {
+	if len(n.keys) == 0 {
+		return nil
+	}
+	p := n.keys[0]
+	for _, k := range n.keys[1:] {
+		i := 0
+		for i < len(p) && i < len(k) && p[i] == k[i] {
+			i++
+		}
+		p = p[:i]
+	}
+	return p
+}
+
+// entrySize returns the encoded size of the entry at index i, not
+// taking the shared key prefix into account
+func (n *node) entrySize(i int) int {
+	if !n.isLeaf {
+		return internalEntryHeaderSize + len(n.keys[i])
+	}
+	return leafEntryHeaderSize + len(n.keys[i]) + n.vals[i].size()
+}
+
+// size returns the encoded size of the node
+func (n *node) size() int {
+	pre := len(n.prefix())
+	sz := nodeHeaderSize + pre
+	if !n.isLeaf {
+		sz += 4
+	}
+	for i := range n.keys {
+		sz += n.entrySize(i) - pre
+	}
+	return sz
 }
 
 func boolToByte(ok bool) uint8 {
@@ -49,59 +157,198 @@ func boolToByte(ok bool) uint8 {
 	return 0
 }
 
-// example:
-// a full root node could have 255 keys and child pointers
-// and each of those children could have 255 keys and child
-// pointers, and each one of those could have 255 keys, and
-// point to leaf nodes...
-// ...which would be 255 * 255 * 255 = 16,581,375 records.
-
-// byte 0 = isLeaf
-// byte 1 = isRoot
-// bytes 2-6 = parent_pointer (page)
-// bytes 6-8 = num_keys
-// bytes 9-12 = right child
-// bytes 12-4092 = child pointer + key pairs
-// bytes 4092-4094 = leftover space
-//
-// **each child pointer + key pair = 16 bytes
-// which means we can fit 255 keys and pointers
-// plus the rest of the node header information
-// in a single 4096 byte page.
-
-func encodeNode(b []byte, np *node) {
+// encodeNode encodes the node into b, which must be at least
+// n.size() bytes long. It returns the number of bytes encoded.
+func encodeNode(b []byte, np *node) int {
 	// write node header
 	var n int
 	// is leaf?
 	b[n] = boolToByte(np.isLeaf)
 	n += 1
-	// is root?
-	b[n] = boolToByte(np.parent == 0)
+	// unused
+	b[n] = 0
 	n += 1
-	// parent pointer
-	binary.LittleEndian.PutUint32(b[n:n+4], np.parent)
-	n += 4
 	// num keys
-	binary.LittleEndian.PutUint16(b[n:n+2], np.nkeys)
+	binary.LittleEndian.PutUint16(b[n:n+2], uint16(np.numKeys()))
 	n += 2
-	// right child
-	binary.LittleEndian.PutUint32(b[n:n+4], np.ptrs[np.nkeys])
+	// next leaf
+	binary.LittleEndian.PutUint32(b[n:n+4], np.next)
 	n += 4
-	// encode child pointers and keys...
-	for i := range np.keys {
-		// encode child pointer at i
-		binary.LittleEndian.PutUint32(b[n:n+4], np.ptrs[i])
+	// prev leaf
+	binary.LittleEndian.PutUint32(b[n:n+4], np.prev)
+	n += 4
+	// shared key prefix
+	pre := np.prefix()
+	binary.LittleEndian.PutUint16(b[n:n+2], uint16(len(pre)))
+	n += 2
+	n += copy(b[n:], pre)
+	// encode internal node child pointers and keys...
+	if !np.isLeaf {
+		binary.LittleEndian.PutUint32(b[n:n+4], np.ptrs[0])
 		n += 4
-		// encode key at i
-		copy(b[n:n+keylen], np.keys[i][:])
-		n += keylen
+		for i := range np.keys {
+			// encode key suffix at i
+			n += encodeKeySuffix(b[n:], np.keys[i][len(pre):])
+			// encode child pointer at i+1
+			binary.LittleEndian.PutUint32(b[n:n+4], np.ptrs[i+1])
+			n += 4
+		}
+		return n
+	}
+	// or, encode leaf node keys and values...
+	for i := range np.keys {
+		v := np.vals[i]
+		// encode key suffix at i
+		n += encodeKeySuffix(b[n:], np.keys[i][len(pre):])
+		// encode value kind and length
+		if v.rid != nil {
+			b[n] = valueRecord
+		} else {
+			b[n] = valueInline
+		}
+		n += 1
+		binary.LittleEndian.PutUint16(b[n:n+2], uint16(v.size()))
+		n += 2
+		// encode value
+		if v.rid != nil {
+			binary.LittleEndian.PutUint32(b[n:n+4], v.rid.PageID)
+			binary.LittleEndian.PutUint16(b[n+4:n+6], v.rid.SlotID)
+			n += recordIDSize
+			continue
+		}
+		n += copy(b[n:], v.data)
 	}
-	// encode remaining "space"
-	leftover := uint16(4<<10 - (n + 2))
-	binary.LittleEndian.PutUint16(b[n:], leftover)
+	return n
+}
+
+// encodeKeySuffix encodes the length of the key suffix followed by the
+// key suffix itself. It returns the number of bytes encoded.
+func encodeKeySuffix(b []byte, suffix []byte) int {
+	binary.LittleEndian.PutUint16(b[0:keyHeaderSize], uint16(len(suffix)))
+	return keyHeaderSize + copy(b[keyHeaderSize:], suffix)
+}
+
+// decodeKey decodes a key suffix, and returns the full key (the
+// shared prefix followed by the suffix) along with the number of
+// bytes decoded
+func decodeKey(b []byte, pre []byte) ([]byte, int, error) {
+	if len(b) < keyHeaderSize {
+		return nil, 0, ErrBadNode
+	}
+	n := int(binary.LittleEndian.Uint16(b[0:keyHeaderSize]))
+	if len(b) < keyHeaderSize+n {
+		return nil, 0, ErrBadNode
+	}
+	k := make([]byte, len(pre)+n)
+	copy(k, pre)
+	copy(k[len(pre):], b[keyHeaderSize:keyHeaderSize+n])
+	return k, keyHeaderSize + n, nil
+}
+
+// decodeNode decodes the node found in b into np
+func decodeNode(b []byte, np *node) error {
+	if len(b) < nodeHeaderSize {
+		return ErrBadNode
+	}
+	// read node header
+	var n int
+	// is leaf?
+	np.isLeaf = b[n] == 1
 	n += 2
+	// num keys
+	nkeys := int(binary.LittleEndian.Uint16(b[n : n+2]))
+	n += 2
+	// next leaf
+	np.next = binary.LittleEndian.Uint32(b[n : n+4])
+	n += 4
+	// prev leaf
+	np.prev = binary.LittleEndian.Uint32(b[n : n+4])
+	n += 4
+	// shared key prefix
+	plen := int(binary.LittleEndian.Uint16(b[n : n+2]))
+	n += 2
+	if len(b) < n+plen {
+		return ErrBadNode
+	}
+	pre := b[n : n+plen]
+	n += plen
+	np.keys = make([][]byte, nkeys)
+	// decode internal node child pointers and keys...
+	if !np.isLeaf {
+		if len(b) < n+4 {
+			return ErrBadNode
+		}
+		np.ptrs = make([]uint32, nkeys+1)
+		np.ptrs[0] = binary.LittleEndian.Uint32(b[n : n+4])
+		n += 4
+		for i := 0; i < nkeys; i++ {
+			k, nn, err := decodeKey(b[n:], pre)
+			if err != nil {
+				return err
+			}
+			np.keys[i] = k
+			n += nn
+			if len(b) < n+4 {
+				return ErrBadNode
+			}
+			np.ptrs[i+1] = binary.LittleEndian.Uint32(b[n : n+4])
+			n += 4
+		}
+		return nil
+	}
+	// or, decode leaf node keys and values...
+	np.vals = make([]*value, nkeys)
+	for i := 0; i < nkeys; i++ {
+		k, nn, err := decodeKey(b[n:], pre)
+		if err != nil {
+			return err
+		}
+		np.keys[i] = k
+		n += nn
+		if len(b) < n+3 {
+			return ErrBadNode
+		}
+		kind := b[n]
+		n += 1
+		vlen := int(binary.LittleEndian.Uint16(b[n : n+2]))
+		n += 2
+		if len(b) < n+vlen {
+			return ErrBadNode
+		}
+		v := new(value)
+		if kind == valueRecord {
+			if vlen != recordIDSize {
+				return ErrBadNode
+			}
+			v.rid = &pager.RecordID{
+				PageID: binary.LittleEndian.Uint32(b[n : n+4]),
+				SlotID: binary.LittleEndian.Uint16(b[n+4 : n+6]),
+			}
+		} else {
+			v.data = make([]byte, vlen)
+			copy(v.data, b[n:n+vlen])
+		}
+		n += vlen
+		np.vals[i] = v
+	}
+	return nil
 }
 
-func decodeNode(b []byte, n *node) {
+// encodeMeta encodes the tree meta record
+func encodeMeta(root uint32) []byte {
+	b := make([]byte, metaSize)
+	binary.LittleEndian.PutUint32(b[0:4], metaMagic)
+	binary.LittleEndian.PutUint16(b[4:6], metaVersion)
+	binary.LittleEndian.PutUint32(b[6:10], root)
+	return b
+}
 
+// decodeMeta decodes the tree meta record and returns the root
+func decodeMeta(b []byte) (uint32, error) {
+	if len(b) < metaSize ||
+		binary.LittleEndian.Uint32(b[0:4]) != metaMagic ||
+		binary.LittleEndian.Uint16(b[4:6]) != metaVersion {
+		return nilPage, ErrBadMetaPage
+	}
+	return binary.LittleEndian.Uint32(b[6:10]), nil
 }
@@ -6,6 +6,13 @@ func NewBPTree() (*BPTree, error) {
 	return bpt, nil
 }
 
+// NewBPTreeWithComparator initializes a new tree that orders
+// its keys using the comparator provided
+func NewBPTreeWithComparator(cmp Comparator) (*BPTree, error) {
+	bpt := &BPTree{cmp: cmp}
+	return bpt, nil
+}
+
 // Has returns a boolean indicating weather or not
 // the provided key and associated record exists.
 func (t *BPTree) Has(k keyType) bool {
@@ -93,11 +100,11 @@ func (t *BPTree) Max() (keyType, valType) {
 // GetClosest attempts to return the closest match in the tree
 // if an explicit match cannot be found
 func (t *BPTree) GetClosest(k keyType) (keyType, valType) {
-	l := findLeaf(t.root, k)
+	l := findLeaf(t.root, k, t.comparator())
 	if l == nil {
 		return *new(keyType), *new(valType)
 	}
-	e, ok := l.closest(k)
+	e, ok := l.closest(k, t.comparator())
 	if !ok {
 		return *new(keyType), *new(valType)
 	}
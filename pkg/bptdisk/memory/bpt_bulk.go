@@ -0,0 +1,114 @@
+package memory
+
+import "unsafe"
+
+// BulkLoad builds the tree from the bottom up using the records returned
+// by iter, which must return the keys in sorted (ascending and unique)
+// order, and returns false once there are no more records. Leaves are
+// packed with records, and then each internal level is built on top of
+// the level below it until a single root remains. The fill factor (in
+// the range (0, 1]) controls how full each node is packed. BulkLoad can
+// only be used on an empty tree.
+func (t *BPTree) BulkLoad(iter func() (keyType, valType, bool), fill float64) error {
+	if t.root != nil {
+		return ErrTreeNotEmpty
+	}
+	if fill <= 0 || fill > 1 {
+		return ErrBadFillFactor
+	}
+	cmp := t.comparator()
+	// read in every record, making sure they are in order
+	var recs []*record
+	for {
+		k, v, ok := iter()
+		if !ok {
+			break
+		}
+		if len(recs) > 0 && cmp.compare(recs[len(recs)-1].Key, k) >= 0 {
+			return ErrUnsortedKeys
+		}
+		recs = append(recs, &record{k, v})
+	}
+	if len(recs) == 0 {
+		return nil
+	}
+	// build the leaf level
+	var level []*node
+	var prev *node
+	for _, size := range fillSizes(len(recs), fillCount(order-1, cut(order-1), fill), cut(order-1), order-1) {
+		leaf := &node{isLeaf: true}
+		for i := 0; i < size; i++ {
+			leaf.keys[i] = recs[i].Key
+			leaf.ptrs[i] = unsafe.Pointer(recs[i])
+		}
+		leaf.numKeys = size
+		recs = recs[size:]
+		// link the previous leaf to this one
+		if prev != nil {
+			prev.ptrs[order-1] = unsafe.Pointer(leaf)
+		}
+		prev = leaf
+		level = append(level, leaf)
+	}
+	// and then build each internal level on top of the level
+	// below it, until we are left with a single root node
+	for len(level) > 1 {
+		var parents []*node
+		for _, size := range fillSizes(len(level), fillCount(order, cut(order), fill), cut(order), order) {
+			parent := &node{}
+			for i := 0; i < size; i++ {
+				if i > 0 {
+					parent.keys[i-1] = minKey(level[i])
+				}
+				parent.ptrs[i] = unsafe.Pointer(level[i])
+				level[i].parent = parent
+			}
+			parent.numKeys = size - 1
+			level = level[size:]
+			parents = append(parents, parent)
+		}
+		level = parents
+	}
+	t.root = level[0]
+	return nil
+}
+
+// minKey returns the lowest key found in the subtree rooted at n
+func minKey(n *node) keyType {
+	for !n.isLeaf {
+		n = (*node)(n.ptrs[0])
+	}
+	return n.keys[0]
+}
+
+// fillCount returns the number of entries to pack into each node, for
+// nodes holding between min and max entries and the fill factor given
+func fillCount(max, min int, fill float64) int {
+	n := int(float64(max) * fill)
+	if n < min {
+		return min
+	}
+	return n
+}
+
+// fillSizes splits n entries into nodes holding per entries each. If
+// the entries left over for the last node are fewer than min, they are
+// added to the node before it or (if there is not enough room) the
+// last two nodes split the entries evenly between them.
+func fillSizes(n, per, min, max int) []int {
+	var sizes []int
+	for ; n > per; n -= per {
+		sizes = append(sizes, per)
+	}
+	sizes = append(sizes, n)
+	last := len(sizes) - 1
+	if last == 0 || sizes[last] >= min {
+		return sizes
+	}
+	total := sizes[last-1] + sizes[last]
+	if total <= max {
+		return append(sizes[:last-1], total)
+	}
+	sizes[last-1], sizes[last] = cut(total), total-cut(total)
+	return sizes
+}
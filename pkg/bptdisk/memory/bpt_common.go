@@ -1,11 +1,24 @@
 package memory
 
 import (
+	"bytes"
 	"unsafe"
 )
 
 type keyType struct {
-	data uint32
+	data []byte
+}
+
+// Comparator compares two keys, and returns an integer comparing them. The
+// result will be 0 if a == b, -1 if a < b, and +1 if a > b.
+type Comparator func(a, b []byte) int
+
+// DefaultComparator orders keys lexicographically
+var DefaultComparator Comparator = bytes.Compare
+
+// compare compares two keys using the comparator provided
+func (cmp Comparator) compare(a, b keyType) int {
+	return cmp(a.data, b.data)
 }
 
 type valType struct {
@@ -41,6 +54,15 @@ type node struct {
 // is to simply call bpt := new(BPTree)
 type BPTree struct {
 	root *node
+	cmp  Comparator
+}
+
+// comparator returns the comparator used to order the keys in the tree
+func (t *BPTree) comparator() Comparator {
+	if t.cmp == nil {
+		return DefaultComparator
+	}
+	return t.cmp
 }
 
 // cut finds the appropriate place to split a node that is
@@ -60,6 +82,29 @@ func (n *node) nextLeaf() *node {
 	return nil
 }
 
+// prevLeaf returns the previous leaf in the chain (to the left) of the current
+// leaf. leaves are only linked to the right, so it walks up the parents until it
+// can step to the left, and then back down to the rightmost leaf of that subtree
+func (n *node) prevLeaf() *node {
+	c := n
+	for c.parent != nil {
+		p := c.parent
+		i := 0
+		for i <= p.numKeys && (*node)(p.ptrs[i]) != c {
+			i++
+		}
+		if i > 0 && i <= p.numKeys {
+			c = (*node)(p.ptrs[i-1])
+			for !c.isLeaf {
+				c = (*node)(c.ptrs[c.numKeys])
+			}
+			return c
+		}
+		c = p
+	}
+	return nil
+}
+
 // destroyTree is a helper for "destroying" the tree
 func (t *BPTree) destroyTree() {
 	destroyTreeNodes(t.root)
@@ -107,10 +152,10 @@ func (t *BPTree) Size() int64 {
 }
 
 // hasKey reports whether this leaf node contains the provided key
-func (n *node) hasKey(k keyType) bool {
+func (n *node) hasKey(k keyType, cmp Comparator) bool {
 	if n.isLeaf {
 		for i := 0; i < n.numKeys; i++ {
-			if k.data == n.keys[i].data {
+			if cmp.compare(k, n.keys[i]) == 0 {
 				return true
 			}
 		}
@@ -119,11 +164,11 @@ func (n *node) hasKey(k keyType) bool {
 }
 
 // closest returns the closest matching record for the provided key
-func (n *node) closest(k keyType) (*record, bool) {
+func (n *node) closest(k keyType, cmp Comparator) (*record, bool) {
 	if n.isLeaf {
 		i := 0
 		for ; i < n.numKeys; i++ {
-			if k.data < n.keys[i].data {
+			if cmp.compare(k, n.keys[i]) < 0 {
 				break
 			}
 		}
@@ -136,10 +181,10 @@ func (n *node) closest(k keyType) (*record, bool) {
 }
 
 // record returns the matching record for the provided key
-func (n *node) record(k keyType) (*record, bool) {
+func (n *node) record(k keyType, cmp Comparator) (*record, bool) {
 	if n.isLeaf {
 		for i := 0; i < n.numKeys; i++ {
-			if k.data == n.keys[i].data {
+			if cmp.compare(k, n.keys[i]) == 0 {
 				return (*record)(n.ptrs[i]), true
 			}
 		}
@@ -0,0 +1,127 @@
+package memory
+
+// Cursor is used to iterate over the records in a tree in either
+// direction. A new cursor is not positioned on any record, so one of
+// First, Last or Seek must be called before it can be used. Modifying
+// the tree invalidates any open cursors.
+type Cursor struct {
+	t    *BPTree
+	leaf *node
+	i    int
+}
+
+// Cursor returns a new cursor for the tree
+func (t *BPTree) Cursor() *Cursor {
+	return &Cursor{t: t}
+}
+
+// Valid reports whether the cursor is positioned on a record
+func (c *Cursor) Valid() bool {
+	return c.leaf != nil && c.i >= 0 && c.i < c.leaf.numKeys
+}
+
+// First moves the cursor to the first (lowest) record in the tree. It
+// returns false if the tree is empty.
+func (c *Cursor) First() bool {
+	c.leaf, c.i = findFirstLeaf(c.t.root), 0
+	return c.skipForward()
+}
+
+// Last moves the cursor to the last (highest) record in the tree. It
+// returns false if the tree is empty.
+func (c *Cursor) Last() bool {
+	c.leaf = findLastLeaf(c.t.root)
+	if c.leaf != nil {
+		c.i = c.leaf.numKeys - 1
+	}
+	return c.skipBackward()
+}
+
+// Seek moves the cursor to the record with the provided key, or the
+// next record after it if the key does not exist. It returns false if
+// there are no records at or after the key.
+func (c *Cursor) Seek(k keyType) bool {
+	cmp := c.t.comparator()
+	c.leaf, c.i = findLeaf(c.t.root, k, cmp), 0
+	if c.leaf == nil {
+		return false
+	}
+	for c.i < c.leaf.numKeys && cmp.compare(c.leaf.keys[c.i], k) < 0 {
+		c.i++
+	}
+	return c.skipForward()
+}
+
+// Next moves the cursor to the next record. It returns false once the
+// cursor moves past the last record.
+func (c *Cursor) Next() bool {
+	if !c.Valid() {
+		return false
+	}
+	c.i++
+	return c.skipForward()
+}
+
+// Prev moves the cursor to the previous record. It returns false once
+// the cursor moves past the first record.
+func (c *Cursor) Prev() bool {
+	if !c.Valid() {
+		return false
+	}
+	c.i--
+	return c.skipBackward()
+}
+
+// Key returns the key of the record the cursor is positioned on
+func (c *Cursor) Key() keyType {
+	if !c.Valid() {
+		return *new(keyType)
+	}
+	return c.leaf.keys[c.i]
+}
+
+// Value returns the value of the record the cursor is positioned on
+func (c *Cursor) Value() valType {
+	if !c.Valid() {
+		return *new(valType)
+	}
+	return (*record)(c.leaf.ptrs[c.i]).Value
+}
+
+// skipForward moves the cursor on to the next leaf (and the next, and
+// so on) while the cursor is past the end of the current leaf
+func (c *Cursor) skipForward() bool {
+	for c.leaf != nil && c.i >= c.leaf.numKeys {
+		c.leaf, c.i = c.leaf.nextLeaf(), 0
+	}
+	return c.leaf != nil
+}
+
+// skipBackward moves the cursor back to the previous leaf (and the
+// previous, and so on) while the cursor is before the start of the
+// current leaf
+func (c *Cursor) skipBackward() bool {
+	for c.leaf != nil && c.i < 0 {
+		c.leaf = c.leaf.prevLeaf()
+		if c.leaf != nil {
+			c.i = c.leaf.numKeys - 1
+		}
+	}
+	return c.leaf != nil
+}
+
+// RangeBetween iterates over the records with keys from start (inclusive)
+// up to end (exclusive). A zero length end key iterates to the end of the
+// tree, and returning false from iter stops the iteration.
+func (t *BPTree) RangeBetween(start, end keyType, iter func(k keyType, v valType) bool) {
+	cmp := t.comparator()
+	c := t.Cursor()
+	for ok := c.Seek(start); ok; ok = c.Next() {
+		if len(end.data) > 0 && cmp.compare(c.Key(), end) >= 0 {
+			return
+		}
+		if !iter(c.Key(), c.Value()) {
+			return
+		}
+	}
+}
@@ -10,7 +10,7 @@ func (t *BPTree) delete(k keyType) *record {
 	var old *record
 	keyLeaf, keyEntry := t.find(k)
 	if keyEntry != nil && keyLeaf != nil {
-		t.root = deleteEntry(t.root, keyLeaf, k, unsafe.Pointer(keyEntry))
+		t.root = deleteEntry(t.root, keyLeaf, k, unsafe.Pointer(keyEntry), t.comparator())
 		old = keyEntry
 		keyEntry = nil
 	}
@@ -19,13 +19,13 @@ func (t *BPTree) delete(k keyType) *record {
 
 // deleteEntry removes the record and its key and pointer from the leaf, and then makes all
 // appropriate changes to preserve the tree's properties
-func deleteEntry(root, n *node, k keyType, pointer unsafe.Pointer) *node {
+func deleteEntry(root, n *node, k keyType, pointer unsafe.Pointer, cmp Comparator) *node {
 
 	// initialize temporary variables
 	var minKeys, kPrimeIndex, capacity int
 
 	// remove the key and value from the current node
-	n = removeEntryFromNode(n, k, pointer)
+	n = removeEntryFromNode(n, k, pointer, cmp)
 
 	// if the node is the room node, make sure to adjust
 	if n == root {
@@ -74,7 +74,7 @@ func deleteEntry(root, n *node, k keyType, pointer unsafe.Pointer) *node {
 
 	// coalesce (underflow) the nodes
 	if neighbor.numKeys+n.numKeys < capacity {
-		return coalesceNodes(root, n, neighbor, neighborIndex, kPrime)
+		return coalesceNodes(root, n, neighbor, neighborIndex, kPrime, cmp)
 	}
 
 	// redistribute the nodes
@@ -82,11 +82,11 @@ func deleteEntry(root, n *node, k keyType, pointer unsafe.Pointer) *node {
 }
 
 // removeEntryFromNode does just that
-func removeEntryFromNode(n *node, k keyType, pointer unsafe.Pointer) *node {
+func removeEntryFromNode(n *node, k keyType, pointer unsafe.Pointer, cmp Comparator) *node {
 
 	// remove the key and shift the other keys accordingly
 	var i, numPointers int
-	for n.keys[i].data != k.data {
+	for cmp.compare(n.keys[i], k) != 0 {
 		i++
 	}
 	for i++; i < n.numKeys; i++ { // was for i+=1;
@@ -166,7 +166,7 @@ func getNeighborIndex(n *node) int {
 // coalesceNodes coalesces a node (that has become too small after deletion) along with
 // a neighboring node that has room to accept the additional entries without exceeding
 // the maximum order of the tree
-func coalesceNodes(root, n, neighbor *node, neighborIndex int, kPrime keyType) *node {
+func coalesceNodes(root, n, neighbor *node, neighborIndex int, kPrime keyType, cmp Comparator) *node {
 
 	// initialize temp variables
 	var tmp *node
@@ -217,7 +217,7 @@ func coalesceNodes(root, n, neighbor *node, neighborIndex int, kPrime keyType) *
 		}
 		neighbor.ptrs[order-1] = n.ptrs[order-1]
 	}
-	root = deleteEntry(root, n.parent, kPrime, unsafe.Pointer(n))
+	root = deleteEntry(root, n.parent, kPrime, unsafe.Pointer(n), cmp)
 	n = nil // free
 	return root
 }
@@ -2,7 +2,8 @@ package memory
 
 // find, finds and returns the node and record to which a key refers
 func (t *BPTree) find(k keyType) (*node, *record) {
-	leaf := findLeaf(t.root, k)
+	cmp := t.comparator()
+	leaf := findLeaf(t.root, k, cmp)
 	if leaf == nil {
 		return nil, nil
 	}
@@ -11,7 +12,7 @@ func (t *BPTree) find(k keyType) (*node, *record) {
 	// the range of keys that would include the desired key
 	var i int
 	for i = 0; i < leaf.numKeys; i++ {
-		if leaf.keys[i].data == k.data {
+		if cmp.compare(leaf.keys[i], k) == 0 {
 			break
 		}
 	}
@@ -23,7 +24,7 @@ func (t *BPTree) find(k keyType) (*node, *record) {
 
 // findLeaf traces the path from the root to a leaf, searching by key.
 // findLeaf returns the leaf containing the given key
-func findLeaf(root *node, k keyType) *node {
+func findLeaf(root *node, k keyType, cmp Comparator) *node {
 	if root == nil {
 		return root
 	}
@@ -31,7 +32,7 @@ func findLeaf(root *node, k keyType) *node {
 	for !c.isLeaf {
 		i = 0
 		for i < c.numKeys {
-			if k.data >= c.keys[i].data {
+			if cmp.compare(k, c.keys[i]) >= 0 {
 				i++
 			} else {
 				break
@@ -48,7 +49,8 @@ func findLeaf(root *node, k keyType) *node {
 // like find does, mechanically you don't save any more time or space using this
 // version. consider removing it
 func (t *BPTree) findEntry(k keyType) *record {
-	leaf := findLeaf(t.root, k)
+	cmp := t.comparator()
+	leaf := findLeaf(t.root, k, cmp)
 	if leaf == nil {
 		return nil
 	}
@@ -57,7 +59,7 @@ func (t *BPTree) findEntry(k keyType) *record {
 	// the range of keys that would include the desired key
 	var i int
 	for i = 0; i < leaf.numKeys; i++ {
-		if leaf.keys[i].data == k.data {
+		if cmp.compare(leaf.keys[i], k) == 0 {
 			break
 		}
 	}
@@ -30,12 +30,12 @@ func (t *BPTree) insert(k keyType, v valType) bool {
 	// check to see if the leaf (that the record should go into) has room, and
 	// if it does, simply insert into the leaf and return
 	if leaf.numKeys < order-1 {
-		insertIntoLeaf(leaf, k, &record{k, v})
+		insertIntoLeaf(leaf, k, &record{k, v}, t.comparator())
 		return false
 	}
 
 	// otherwise, leaf does not have enough room and needs to be split
-	t.root = insertIntoLeafAfterSplitting(t.root, leaf, k, &record{k, v})
+	t.root = insertIntoLeafAfterSplitting(t.root, leaf, k, &record{k, v}, t.comparator())
 	return false
 }
 
@@ -48,9 +48,9 @@ func (t *BPTree) insertUnique(k keyType, v valType) {
 		return
 	}
 	// see what we get when we try to find the correct leaf
-	leaf := findLeaf(t.root, k)
+	leaf := findLeaf(t.root, k, t.comparator())
 	// check to ensure the leaf node does already contain the key
-	if leaf.hasKey(k) {
+	if leaf.hasKey(k, t.comparator()) {
 		// if this is true, then they key already exists, so we
 		// should just return
 		return
@@ -60,12 +60,12 @@ func (t *BPTree) insertUnique(k keyType, v valType) {
 	// to see if the leaf (that the record should go into) has room,
 	// and if it does, simply insert into the leaf and return
 	if leaf.numKeys < order-1 {
-		insertIntoLeaf(leaf, k, &record{k, v})
+		insertIntoLeaf(leaf, k, &record{k, v}, t.comparator())
 		return
 	}
 
 	// otherwise, leaf does not have enough room and needs to be split
-	t.root = insertIntoLeafAfterSplitting(t.root, leaf, k, &record{k, v})
+	t.root = insertIntoLeafAfterSplitting(t.root, leaf, k, &record{k, v}, t.comparator())
 }
 
 // startNewTree first insertion case: starts a new tree
@@ -81,9 +81,9 @@ func startNewTree(k keyType, ptr *record) *node {
 
 // insertIntoLeaf inserts a new pointer to a Record and its
 // corresponding key into a leaf.
-func insertIntoLeaf(leaf *node, k keyType, ptr *record) /* *node */ {
+func insertIntoLeaf(leaf *node, k keyType, ptr *record, cmp Comparator) /* *node */ {
 	var i, insertionPoint int
-	for insertionPoint < leaf.numKeys && leaf.keys[insertionPoint].data < k.data {
+	for insertionPoint < leaf.numKeys && cmp.compare(leaf.keys[insertionPoint], k) < 0 {
 		insertionPoint++
 	}
 	for i = leaf.numKeys; i > insertionPoint; i-- {
@@ -99,11 +99,11 @@ func insertIntoLeaf(leaf *node, k keyType, ptr *record) /* *node */ {
 // insertIntoLeafAfterSplitting is specifically called to insert a key and value when
 // the leaf node is full (aka, exceeds the order of the tree) and the leaf must be split
 // in half, and then re-balance upward toward the root
-func insertIntoLeafAfterSplitting(root, leaf *node, k keyType, pointer *record) *node {
+func insertIntoLeafAfterSplitting(root, leaf *node, k keyType, pointer *record, cmp Comparator) *node {
 
 	// perform linear search to find index to insert new record
 	var insertionIndex int
-	for insertionIndex < order-1 && leaf.keys[insertionIndex].data < k.data {
+	for insertionIndex < order-1 && cmp.compare(leaf.keys[insertionIndex], k) < 0 {
 		insertionIndex++
 	}
 
@@ -14,9 +14,9 @@ type print struct {
 func (n *node) String() string {
 	ss := fmt.Sprintf("[")
 	for i := 0; i < n.numKeys-1; i++ {
-		ss += fmt.Sprintf("%d|", n.keys[i].data)
+		ss += fmt.Sprintf("%s|", n.keys[i].data)
 	}
-	ss += fmt.Sprintf("%d]", n.keys[n.numKeys-1].data)
+	ss += fmt.Sprintf("%s]", n.keys[n.numKeys-1].data)
 	return ss
 }
 
@@ -173,9 +173,9 @@ func print_tree(root *node) {
 		}
 		fmt.Printf("[")
 		for i = 0; i < prt.node.numKeys-1; i++ {
-			fmt.Printf("%d|", prt.node.keys[i].data)
+			fmt.Printf("%s|", prt.node.keys[i].data)
 		}
-		fmt.Printf("%d]", prt.node.keys[prt.node.numKeys-1].data)
+		fmt.Printf("%s]", prt.node.keys[prt.node.numKeys-1].data)
 		if !prt.node.isLeaf {
 			for i = 0; i <= prt.node.numKeys; i++ {
 				enqueue((*node)(prt.node.ptrs[i]))
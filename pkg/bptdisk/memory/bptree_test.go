@@ -1,6 +1,7 @@
 package memory
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"reflect"
@@ -40,7 +41,7 @@ func TestDelFromNewBPTree(t *testing.T) {
 	var tree *BPTree
 	tree = new(BPTree)
 	AssertNotNil(t, tree)
-	tree.Del(keyType{data: 4})
+	tree.Del(keyType{data: []byte("key-000004")})
 	tree.Close()
 }
 
@@ -153,7 +154,7 @@ func TestBPTree_Range(t *testing.T) {
 
 	// do scan front
 	tree.Range(func(k keyType, v valType) bool {
-		if k.data < 0 {
+		if len(k.data) == 0 {
 			t.Errorf("scan front, issue with key: %v", k)
 			return false
 		}
@@ -166,6 +167,115 @@ func TestBPTree_Range(t *testing.T) {
 	tree.Close()
 }
 
+func TestBPTree_Comparator(t *testing.T) {
+	// order the keys in reverse
+	tree, err := NewBPTreeWithComparator(func(a, b []byte) int {
+		return bytes.Compare(b, a)
+	})
+	if err != nil {
+		t.Fatalf("new tree: %s", err)
+	}
+	for i := 0; i < n*thousand; i++ {
+		tree.Put(makeKey(i), makeVal(i))
+	}
+	AssertLen(t, n*thousand, tree.Len())
+	k, _ := tree.Min()
+	AssertEqual(t, makeKey(n*thousand-1), k)
+	k, _ = tree.Max()
+	AssertEqual(t, makeKey(0), k)
+	for i := 0; i < n*thousand; i++ {
+		_, v := tree.Del(makeKey(i))
+		AssertEqual(t, makeVal(i), v)
+	}
+	AssertLen(t, 0, tree.Len())
+	tree.Close()
+}
+
+func TestBPTree_Cursor(t *testing.T) {
+	tree := new(BPTree)
+	c := tree.Cursor()
+	if c.First() || c.Last() || c.Seek(makeKey(0)) {
+		t.Errorf("cursor: expected an empty tree")
+	}
+	for i := 0; i < n*thousand; i += 2 {
+		tree.Put(makeKey(i), makeVal(i))
+	}
+	// walk forward
+	i := 0
+	for ok := c.First(); ok; ok = c.Next() {
+		AssertEqual(t, makeKey(i), c.Key())
+		AssertEqual(t, makeVal(i), c.Value())
+		i += 2
+	}
+	AssertEqual(t, n*thousand, i)
+	// walk backward
+	i = n*thousand - 2
+	for ok := c.Last(); ok; ok = c.Prev() {
+		AssertEqual(t, makeKey(i), c.Key())
+		i -= 2
+	}
+	AssertEqual(t, -2, i)
+	// seek to a missing key lands on the next key
+	if !c.Seek(makeKey(101)) {
+		t.Fatalf("seek: expected to find a key")
+	}
+	AssertEqual(t, makeKey(102), c.Key())
+	c.Prev()
+	AssertEqual(t, makeKey(100), c.Key())
+	if c.Seek(makeKey(n * thousand)) {
+		t.Errorf("seek: expected no key, got %s", c.Key().data)
+	}
+	// bounded range
+	i = 100
+	tree.RangeBetween(makeKey(100), makeKey(200), func(k keyType, v valType) bool {
+		AssertEqual(t, makeKey(i), k)
+		i += 2
+		return true
+	})
+	AssertEqual(t, 200, i)
+	tree.Close()
+}
+
+func TestBPTree_BulkLoad(t *testing.T) {
+	for _, fill := range []float64{0.5, 0.75, 1} {
+		tree := new(BPTree)
+		i := 0
+		err := tree.BulkLoad(func() (keyType, valType, bool) {
+			if i == n*thousand {
+				return keyType{}, valType{}, false
+			}
+			i++
+			return makeKey(i - 1), makeVal(i - 1), true
+		}, fill)
+		AssertNoError(t, err)
+		AssertLen(t, n*thousand, tree.Len())
+		for i := 0; i < n*thousand; i++ {
+			_, v := tree.Get(makeKey(i))
+			AssertEqual(t, makeVal(i), v)
+		}
+		// the tree should behave normally after being loaded
+		for i := 0; i < n*thousand; i += 2 {
+			_, v := tree.Del(makeKey(i))
+			AssertEqual(t, makeVal(i), v)
+		}
+		tree.Put(makeKey(n*thousand), makeVal(n*thousand))
+		AssertLen(t, n*thousand/2+1, tree.Len())
+		tree.Close()
+	}
+	// bulk loading unsorted keys should fail
+	tree := new(BPTree)
+	keys := []int{1, 3, 2}
+	err := tree.BulkLoad(func() (keyType, valType, bool) {
+		if len(keys) == 0 {
+			return keyType{}, valType{}, false
+		}
+		k := keys[0]
+		keys = keys[1:]
+		return makeKey(k), makeVal(k), true
+	}, 1)
+	AssertEqual(t, ErrUnsortedKeys, err)
+}
+
 func TestBPTree_Close(t *testing.T) {
 	var tree *BPTree
 	tree = new(BPTree)
@@ -173,7 +283,7 @@ func TestBPTree_Close(t *testing.T) {
 }
 
 func makeKey(i int) keyType {
-	return keyType{data: uint32(i)}
+	return keyType{data: []byte(fmt.Sprintf("key-%.6d", i))}
 }
 
 func makeVal(i int) valType {
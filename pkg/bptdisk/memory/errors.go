@@ -0,0 +1,9 @@
+package memory
+
+import "errors"
+
+var (
+	ErrTreeNotEmpty  = errors.New("bptree: tree is not empty")
+	ErrUnsortedKeys  = errors.New("bptree: keys are not sorted, or are not unique")
+	ErrBadFillFactor = errors.New("bptree: fill factor must be greater than 0 and at most 1")
+)
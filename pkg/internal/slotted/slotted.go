@@ -0,0 +1,248 @@
+// Package slotted holds the on disk layout of a slotted page, which is
+// shared by pkg/pager and pkg/pagerv2. The header is followed by the
+// slots which grow down the page, and the records are added from the
+// end of the page and grow up. Pages of WidePageSize and larger use the
+// wide format, with 32-bit free space bounds stored just past the header
+// (the 16-bit fields are left as zero), and with 32-bit item offsets and
+// lengths in each slot.
+package slotted
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+)
+
+/*
+	pageID         uint32
+	nextPageID     uint32
+	prevPageID     uint32
+	freeSpaceLower uint16
+	freeSpaceUpper uint16
+	slotCount      uint16
+	freeSlotCount  uint16
+	hasOverflow    uint16
+	version        uint16
+	checksum       uint32
+	reserved       uint32
+*/
+
+const (
+	HeaderSize        = 32 // 32 bytes
+	SlotSize          = 8  // 8 bytes
+	FormatVersion     = 1
+	WidePageSize      = 64 << 10 // 64 KB
+	WideHeaderSize    = 40       // 40 bytes
+	WideSlotSize      = 12       // 12 bytes
+	WideFormatVersion = 2
+)
+
+const (
+	// header offsets within page
+	OffPageID         = 0  // +4
+	OffNextPageID     = 4  // +4
+	OffPrevPageID     = 8  // +4
+	OffFreeSpaceLower = 12 // +2
+	OffFreeSpaceUpper = 14 // +2
+	OffSlotCount      = 16 // +2
+	OffFreeSlotCount  = 18 // +2
+	OffHasOverflow    = 20 // +2
+	OffVersion        = 22 // +2
+	OffChecksum       = 24 // +4
+	OffReserved       = 28 // +4
+	OffStartSlots     = 32
+
+	// wide header offsets within page
+	OffWideFreeSpaceLower = 32 // +4
+	OffWideFreeSpaceUpper = 36 // +4
+	OffWideStartSlots     = 40
+
+	// entry offsets within slot space
+	OffSlotEntryID     = 0 // +2
+	OffSlotEntryStatus = 2 // +2
+	OffSlotEntryOffset = 4 // +2
+	OffSlotEntryLength = 6 // +2
+
+	// wide entry offsets within slot space
+	OffWideSlotEntryOffset = 4 // +4
+	OffWideSlotEntryLength = 8 // +4
+)
+
+const (
+	ItemStatusFree uint16 = iota
+	ItemStatusUsed
+)
+
+var bindata = binary.LittleEndian
+
+// crc32c is the (Castagnoli) table used for page checksums
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// Checksum calculates the checksum of the raw page data provided. The
+// checksum covers the whole page, except for the checksum field itself.
+func Checksum(p []byte) uint32 {
+	crc := crc32.Checksum(p[:OffChecksum], crc32c)
+	return crc32.Update(crc, crc32c, p[OffChecksum+4:])
+}
+
+// SetChecksum calculates and encodes the checksum of the raw page
+// data provided into the page header
+func SetChecksum(p []byte) {
+	bindata.PutUint32(p[OffChecksum:OffChecksum+4], Checksum(p))
+}
+
+// ChecksumOK reports whether the checksum encoded in the page header
+// matches the raw page data provided
+func ChecksumOK(p []byte) bool {
+	return bindata.Uint32(p[OffChecksum:OffChecksum+4]) == Checksum(p)
+}
+
+// IsWide reports whether the page uses the wide page format
+func IsWide(p []byte) bool {
+	return len(p) >= WidePageSize
+}
+
+// Layout returns the header size and the slot size used by the page
+func Layout(p []byte) (int, int) {
+	if IsWide(p) {
+		return WideHeaderSize, WideSlotSize
+	}
+	return HeaderSize, SlotSize
+}
+
+// MaxRecordSize returns the largest record that fits in a page
+// of the provided size
+func MaxRecordSize(size int) int {
+	if size >= WidePageSize {
+		return size - WideHeaderSize - WideSlotSize
+	}
+	return size - HeaderSize - SlotSize
+}
+
+// Init encodes a fresh page header for the page id provided
+func Init(p []byte, pid uint32) {
+	hdr, _ := Layout(p)
+	version := uint16(FormatVersion)
+	if IsWide(p) {
+		version = WideFormatVersion
+	}
+	// early bounds check to guarantee safety of writes below
+	_ = p[hdr-1]
+	bindata.PutUint32(p[OffPageID:], pid)      // pageID
+	bindata.PutUint32(p[OffNextPageID:], 0)    // nextPageID
+	bindata.PutUint32(p[OffPrevPageID:], 0)    // prevPageID
+	SetFreeSpaceLower(p, uint32(hdr))          // freeSpaceLower
+	SetFreeSpaceUpper(p, uint32(len(p)))       // freeSpaceUpper
+	bindata.PutUint16(p[OffSlotCount:], 0)     // slotCount
+	bindata.PutUint16(p[OffFreeSlotCount:], 0) // freeSlotCount
+	bindata.PutUint16(p[OffHasOverflow:], 0)   // hasOverflow
+	bindata.PutUint16(p[OffVersion:], version) // version
+	bindata.PutUint32(p[OffChecksum:], 0)      // checksum
+	bindata.PutUint32(p[OffReserved:], 0)      // reserved
+}
+
+/*
+Getter and setter functions for the page header
+*/
+
+func FreeSpaceLower(p []byte) uint32 {
+	if IsWide(p) {
+		return bindata.Uint32(p[OffWideFreeSpaceLower : OffWideFreeSpaceLower+4])
+	}
+	return uint32(bindata.Uint16(p[OffFreeSpaceLower : OffFreeSpaceLower+2]))
+}
+
+func SetFreeSpaceLower(p []byte, freeSpaceLower uint32) {
+	if IsWide(p) {
+		bindata.PutUint32(p[OffWideFreeSpaceLower:OffWideFreeSpaceLower+4], freeSpaceLower)
+		return
+	}
+	bindata.PutUint16(p[OffFreeSpaceLower:OffFreeSpaceLower+2], uint16(freeSpaceLower))
+}
+
+func FreeSpaceUpper(p []byte) uint32 {
+	if IsWide(p) {
+		return bindata.Uint32(p[OffWideFreeSpaceUpper : OffWideFreeSpaceUpper+4])
+	}
+	return uint32(bindata.Uint16(p[OffFreeSpaceUpper : OffFreeSpaceUpper+2]))
+}
+
+func SetFreeSpaceUpper(p []byte, freeSpaceUpper uint32) {
+	if IsWide(p) {
+		bindata.PutUint32(p[OffWideFreeSpaceUpper:OffWideFreeSpaceUpper+4], freeSpaceUpper)
+		return
+	}
+	bindata.PutUint16(p[OffFreeSpaceUpper:OffFreeSpaceUpper+2], uint16(freeSpaceUpper))
+}
+
+func SlotCount(p []byte) uint16 {
+	return bindata.Uint16(p[OffSlotCount : OffSlotCount+2])
+}
+
+func SetSlotCount(p []byte, slotCount uint16) {
+	bindata.PutUint16(p[OffSlotCount:OffSlotCount+2], slotCount)
+}
+
+// slot layout below (item offset and item length are
+// both uint32 in wide pages)
+// itemID     uint16
+// itemStatus uint16
+// itemOffset uint16
+// itemLength uint16
+
+func slotNOffset(p []byte, slotNumber int) int {
+	hdr, slot := Layout(p)
+	return hdr + slotNumber*slot
+}
+
+func SetSlotID(p []byte, slotNumber int, slotID uint16) {
+	n := slotNOffset(p, slotNumber) + OffSlotEntryID
+	bindata.PutUint16(p[n:n+2], slotID)
+}
+
+func SlotStatus(p []byte, slotNumber int) uint16 {
+	n := slotNOffset(p, slotNumber) + OffSlotEntryStatus
+	return bindata.Uint16(p[n : n+2])
+}
+
+func SetSlotStatus(p []byte, slotNumber int, slotStatus uint16) {
+	n := slotNOffset(p, slotNumber) + OffSlotEntryStatus
+	bindata.PutUint16(p[n:n+2], slotStatus)
+}
+
+func SlotOffset(p []byte, slotNumber int) uint32 {
+	if IsWide(p) {
+		n := slotNOffset(p, slotNumber) + OffWideSlotEntryOffset
+		return bindata.Uint32(p[n : n+4])
+	}
+	n := slotNOffset(p, slotNumber) + OffSlotEntryOffset
+	return uint32(bindata.Uint16(p[n : n+2]))
+}
+
+func SetSlotOffset(p []byte, slotNumber int, slotOffset uint32) {
+	if IsWide(p) {
+		n := slotNOffset(p, slotNumber) + OffWideSlotEntryOffset
+		bindata.PutUint32(p[n:n+4], slotOffset)
+		return
+	}
+	n := slotNOffset(p, slotNumber) + OffSlotEntryOffset
+	bindata.PutUint16(p[n:n+2], uint16(slotOffset))
+}
+
+func SlotLength(p []byte, slotNumber int) uint32 {
+	if IsWide(p) {
+		n := slotNOffset(p, slotNumber) + OffWideSlotEntryLength
+		return bindata.Uint32(p[n : n+4])
+	}
+	n := slotNOffset(p, slotNumber) + OffSlotEntryLength
+	return uint32(bindata.Uint16(p[n : n+2]))
+}
+
+func SetSlotLength(p []byte, slotNumber int, slotLength uint32) {
+	if IsWide(p) {
+		n := slotNOffset(p, slotNumber) + OffWideSlotEntryLength
+		bindata.PutUint32(p[n:n+4], slotLength)
+		return
+	}
+	n := slotNOffset(p, slotNumber) + OffSlotEntryLength
+	bindata.PutUint16(p[n:n+2], uint16(slotLength))
+}
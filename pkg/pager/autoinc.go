@@ -1,23 +1,17 @@
 package pager
 
-import "sync"
+import "sync/atomic"
 
 type autoPageID struct {
-	sync.Mutex
 	id uint32
 }
 
 func (a *autoPageID) getNewPageID() (id uint32) {
-	a.Lock()
-	defer a.Unlock()
-	id = a.id
-	a.id++
-	return
+	return atomic.AddUint32(&a.id, 1) - 1
 }
 
-func (a *autoPageID) undoGetNewPageID() {
-	a.Lock()
-	defer a.Unlock()
-	a.id--
-	return
+// undoGetNewPageID gives back the Page id provided, as long as it
+// is the last one handed out. It reports if it was given back.
+func (a *autoPageID) undoGetNewPageID(id uint32) bool {
+	return atomic.CompareAndSwapUint32(&a.id, id+1, id)
 }
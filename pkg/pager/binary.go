@@ -8,15 +8,17 @@ func setFreshHeader(p []byte, pid uint32) {
 	if len(p) != pageHeaderSize {
 		panic(ErrBadPageSize)
 	}
-	bindata.PutUint32(p[0:4], pid)              // pageID
-	bindata.PutUint32(p[4:8], 0)                // nextPageID
-	bindata.PutUint32(p[8:12], 0)               // prevPageID
-	bindata.PutUint16(p[12:14], pageHeaderSize) // freeSpaceLower
-	bindata.PutUint16(p[14:16], pageSize)       // freeSpaceUpper
-	bindata.PutUint16(p[16:18], 0)              // slotCount
-	bindata.PutUint16(p[18:20], 0)              // freeSlotCount
-	bindata.PutUint16(p[20:22], 0)              // hasOverflow
-	bindata.PutUint16(p[22:24], 0)              // reserved
+	bindata.PutUint32(p[0:4], pid)                 // pageID
+	bindata.PutUint32(p[4:8], 0)                   // nextPageID
+	bindata.PutUint32(p[8:12], 0)                  // prevPageID
+	bindata.PutUint16(p[12:14], pageHeaderSize)    // freeSpaceLower
+	bindata.PutUint16(p[14:16], pageSize)          // freeSpaceUpper
+	bindata.PutUint16(p[16:18], 0)                 // slotCount
+	bindata.PutUint16(p[18:20], 0)                 // freeSlotCount
+	bindata.PutUint16(p[20:22], 0)                 // hasOverflow
+	bindata.PutUint16(p[22:24], pageFormatVersion) // version
+	bindata.PutUint32(p[24:28], 0)                 // checksum
+	bindata.PutUint32(p[28:32], 0)                 // reserved
 }
 
 /*
@@ -62,9 +64,19 @@ func setHasOverflow(b []byte, hasOverflow uint16) {
 	bindata.PutUint16(b[20:22], hasOverflow)
 }
 
-func setReserved(b []byte, reserved uint16) {
+func setVersion(b []byte, version uint16) {
 	_ = b[1] // early bounds check to guarantee safety of writes below
-	bindata.PutUint16(b[22:24], reserved)
+	bindata.PutUint16(b[22:24], version)
+}
+
+func setChecksum(b []byte, checksum uint32) {
+	_ = b[3] // early bounds check to guarantee safety of writes below
+	bindata.PutUint32(b[24:28], checksum)
+}
+
+func setReserved(b []byte, reserved uint32) {
+	_ = b[3] // early bounds check to guarantee safety of writes below
+	bindata.PutUint32(b[28:32], reserved)
 }
 
 /*
@@ -110,9 +122,19 @@ func getHasOverflow(b []byte) uint16 {
 	return bindata.Uint16(b[22:24])
 }
 
-func getReserved(b []byte) uint16 {
+func getVersion(b []byte) uint16 {
 	_ = b[1] // bounds check hint to compiler; see golang.org/issue/14808
-	return bindata.Uint16(b)
+	return bindata.Uint16(b[22:24])
+}
+
+func getChecksum(b []byte) uint32 {
+	_ = b[3] // bounds check hint to compiler; see golang.org/issue/14808
+	return bindata.Uint32(b[24:28])
+}
+
+func getReserved(b []byte) uint32 {
+	_ = b[3] // bounds check hint to compiler; see golang.org/issue/14808
+	return bindata.Uint32(b[28:32])
 }
 
 // slot layout below
@@ -0,0 +1,17 @@
+//go:build !pagerdebug
+// +build !pagerdebug
+
+package pager
+
+// borrowState tracks the views borrowed from a Page (see Page.BorrowRecord).
+// Outside of debug builds nothing is tracked, so borrowing is free.
+type borrowState struct{}
+
+// lend is called every time a view of the Page data is handed out
+func (b *borrowState) lend() {}
+
+// release is called before the records in a Page are modified, it
+// returns the buffer the Page should use from now on
+func (b *borrowState) release(data []byte) []byte {
+	return data
+}
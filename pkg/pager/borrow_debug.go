@@ -0,0 +1,48 @@
+//go:build pagerdebug
+// +build pagerdebug
+
+package pager
+
+// poisonByte is used to fill the Page buffers that were released
+const poisonByte = 0xdb
+
+// borrowState tracks the views borrowed from a Page (see Page.BorrowRecord).
+// In debug builds, when the records in a Page are modified while views
+// of it are out, the Page moves to a fresh buffer and the old buffer
+// (which the borrowed views still point into) is filled with poison.
+// Reading through a stale view returns poison, and writing through one
+// is caught the next time the Page is modified.
+type borrowState struct {
+	lent     bool
+	poisoned [][]byte
+}
+
+// lend is called every time a view of the Page data is handed out
+func (b *borrowState) lend() {
+	b.lent = true
+}
+
+// release is called before the records in a Page are modified, it
+// returns the buffer the Page should use from now on. It panics if
+// anything was written to the buffers that were poisoned.
+func (b *borrowState) release(data []byte) []byte {
+	for _, old := range b.poisoned {
+		for i := range old {
+			if old[i] != poisonByte {
+				panic(ErrUseAfterModify)
+			}
+		}
+	}
+	b.poisoned = b.poisoned[:0]
+	if !b.lent {
+		return data
+	}
+	b.lent = false
+	fresh := make([]byte, len(data))
+	copy(fresh, data)
+	for i := range data {
+		data[i] = poisonByte
+	}
+	b.poisoned = append(b.poisoned, data)
+	return fresh
+}
@@ -0,0 +1,47 @@
+//go:build pagerdebug
+// +build pagerdebug
+
+package pager
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPage_BorrowAfterModify(t *testing.T) {
+	pg := NewPage(1)
+	recs := addRecords(pg)
+	b, err := pg.BorrowRecord(recs[2])
+	if err != nil {
+		t.Fatalf("[Page] borrowing record: %s", err)
+	}
+	err = pg.DelRecord(recs[1])
+	if err != nil {
+		t.Fatalf("[Page] deleting record: %s", err)
+	}
+	// reading through the stale view only sees poison
+	if !bytes.Equal(b, bytes.Repeat([]byte{poisonByte}, len(b))) {
+		t.Errorf("[Page] expected the stale view to be poisoned, got %q", b)
+	}
+	// but the Page itself still has its data
+	rec, err := pg.GetRecord(recs[2])
+	if err != nil || string(rec) != "this-is-record-000002" {
+		t.Errorf("[Page] expected the record to survive, got %q (%v)", rec, err)
+	}
+	b, err = pg.BorrowRecord(recs[2])
+	if err != nil {
+		t.Fatalf("[Page] borrowing record: %s", err)
+	}
+	err = pg.DelRecord(recs[3])
+	if err != nil {
+		t.Fatalf("[Page] deleting record: %s", err)
+	}
+	// writing through the stale view is caught on the next change
+	copy(b, "oops")
+	defer func() {
+		if r := recover(); r != ErrUseAfterModify {
+			t.Errorf("[Page] expected a %v panic, got %v", ErrUseAfterModify, r)
+		}
+	}()
+	_ = pg.DelRecord(recs[4])
+}
@@ -1,12 +1,25 @@
 package pager
 
+import "github.com/cagnosolutions/pager/pkg/internal/slotted"
+
+const (
+	// DefaultPageSize is the Page size used when one is not specified
+	DefaultPageSize = 8 << 10 // 8 KB
+	// MinPageSize is the smallest Page size supported
+	MinPageSize = 4 << 10 // 4 KB
+	// MaxPageSize is the largest Page size supported
+	MaxPageSize = 1 << 20 // 1 MB
+)
+
 const (
 	// used in Page
-	pageSize       = 8 << 10 // 8 KB
-	pageHeaderSize = 24      // 24 bytes
-	pageSlotSize   = 8       // 8 bytes
+	pageSize       = DefaultPageSize
+	pageHeaderSize = slotted.HeaderSize
+	pageSlotSize   = slotted.SlotSize
 	MinRecordSize  = pageSlotSize
-	MaxRecordSize  = pageSize - pageHeaderSize - pageSlotSize
+	// MaxRecordSize is the largest record that fits in a
+	// Page of the default size (see PageManager.MaxRecordSize)
+	MaxRecordSize = pageSize - pageHeaderSize - pageSlotSize
 
 	// used in Cache
 	cacheSize      = 2 << 20
@@ -16,31 +29,109 @@ const (
 	defaultBufferedPageCount = 8
 )
 
+const (
+	// pageFormatVersion is the current on disk format version
+	// of a Page. Pages written before the version field existed
+	// (version 0) have a 24 byte header and no checksum.
+	pageFormatVersion       = slotted.FormatVersion
+	legacyPageHeaderSize    = 24
+	legacyPageFormatVersion = 0
+
+	// widePageFormatVersion is the format version used by pages
+	// of widePageSize and larger, which are too large for 16-bit
+	// offsets. Wide pages keep the same header layout, but their
+	// free space bounds are stored as 32-bit values just past it
+	// (the 16-bit fields are left as zero), and each slot holds
+	// a 32-bit item offset and length.
+	widePageFormatVersion = slotted.WideFormatVersion
+	widePageSize          = slotted.WidePageSize
+	widePageHeaderSize    = slotted.WideHeaderSize
+	widePageSlotSize      = slotted.WideSlotSize
+)
+
+// validPageSize reports whether the provided Page size is supported
+func validPageSize(size int) bool {
+	return size >= MinPageSize && size <= MaxPageSize && size&(size-1) == 0
+}
+
+// pageFormatFor returns the format version used by pages of the
+// provided size
+func pageFormatFor(size int) uint16 {
+	if size >= widePageSize {
+		return widePageFormatVersion
+	}
+	return pageFormatVersion
+}
+
+// pageLayout returns the header size and the slot size used by
+// pages of the provided format version
+func pageLayout(version uint16) (int, int) {
+	switch version {
+	case legacyPageFormatVersion:
+		return legacyPageHeaderSize, pageSlotSize
+	case widePageFormatVersion:
+		return widePageHeaderSize, widePageSlotSize
+	}
+	return pageHeaderSize, pageSlotSize
+}
+
+// maxRecordSizeFor returns the largest record that fits in
+// a Page of the provided size
+func maxRecordSizeFor(size int) int {
+	hdr, slot := pageLayout(pageFormatFor(size))
+	return size - hdr - slot
+}
+
 const (
 	itemStatusFree uint16 = iota
 	itemStatusUsed
+	// itemStatusOverflow marks a slot holding the head of a
+	// record that has spilled over into a chain of overflow
+	// pages (see overflow.go)
+	itemStatusOverflow
+	// itemStatusForward marks a slot holding a forwarding pointer
+	// to a record that has been moved to another Page, because it
+	// outgrew the Page it was added to (see forward.go)
+	itemStatusForward
+)
+
+const (
+	// pageFlagRecords is set in the reserved field of the header of
+	// a Page holding records added through the PageManager, these
+	// pages can hold records belonging to many different owners
+	// (see PageManager.AddRecord)
+	pageFlagRecords uint32 = 1 << iota
 )
 
 func align(n int, size int) int {
 	return (n + size) &^ size
 }
 
+// The Page layout (offsets, sizes and format versions) is shared
+// with pkg/pagerv2, through the codec in pkg/internal/slotted.
 const (
 	// header offsets within page
-	offPageID         = 0  // +4
-	offNextPageID     = 4  // +4
-	offPrevPageID     = 8  // +4
-	offFreeSpaceLower = 12 // +2
-	offFreeSpaceUpper = 14 // +2
-	offSlotCount      = 16 // +2
-	offFreeSlotCount  = 18 // +2
-	offHasOverflow    = 20 // +2
-	offReserved       = 22 // +2
-	offStartSlots     = 24
+	offPageID         = slotted.OffPageID
+	offNextPageID     = slotted.OffNextPageID
+	offPrevPageID     = slotted.OffPrevPageID
+	offFreeSpaceLower = slotted.OffFreeSpaceLower
+	offFreeSpaceUpper = slotted.OffFreeSpaceUpper
+	offSlotCount      = slotted.OffSlotCount
+	offFreeSlotCount  = slotted.OffFreeSlotCount
+	offHasOverflow    = slotted.OffHasOverflow
+	offVersion        = slotted.OffVersion
+	offChecksum       = slotted.OffChecksum
+	offReserved       = slotted.OffReserved
+	offStartSlots     = slotted.OffStartSlots
+
+	// wide header offsets within page
+	offWideFreeSpaceLower = slotted.OffWideFreeSpaceLower
+	offWideFreeSpaceUpper = slotted.OffWideFreeSpaceUpper
+	offWideStartSlots     = slotted.OffWideStartSlots
 
 	// entry offsets within slot space
-	offSlotEntryID     = 0 // +2
-	offSlotEntryStatus = 2 // +2
-	offSlotEntryOffset = 4 // +2
-	offSlotEntryLength = 6 // +2
+	offSlotEntryID     = slotted.OffSlotEntryID
+	offSlotEntryStatus = slotted.OffSlotEntryStatus
+	offSlotEntryOffset = slotted.OffSlotEntryOffset
+	offSlotEntryLength = slotted.OffSlotEntryLength
 )
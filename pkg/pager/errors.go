@@ -16,4 +16,22 @@ var (
 	ErrMaxRecordSize           = errors.New("Page: record is larger than the max record size allowed")
 	ErrRecordMaxKeySize        = errors.New("record: record key is longer than max size allowed (255)")
 	ErrPageIsNotOverflow       = errors.New("pagemanager: error Page is not an overflow Page")
+	ErrPageChecksumMismatch    = errors.New("pageManagerFile: Page checksum mismatch")
+	ErrWritingLog              = errors.New("pageManagerFile: error writing to the write-ahead log")
+	ErrBadSuperblock           = errors.New("pageManagerFile: superblock is corrupt")
+	ErrUnsupportedVersion      = errors.New("pageManagerFile: unsupported superblock or Page format version")
+	ErrPageSizeMismatch        = errors.New("pageManagerFile: file was created with a different Page size")
+	ErrUnsupportedPageSize     = errors.New("pageManagerFile: Page size must be a power of two between 4 KB and 1 MB")
+	ErrNoSuperblock            = errors.New("pageManagerFile: file has no superblock (legacy layout)")
+	ErrBadRootName             = errors.New("pageManagerFile: root name is empty or too long")
+	ErrTooManyRoots            = errors.New("pageManagerFile: no more room for root slots")
+	ErrMmapUnsupported         = errors.New("pageManagerFile: memory mapped files are not supported on this platform")
+	ErrTxDone                  = errors.New("pageManagerFile: transaction has already been committed or rolled back")
+	ErrNoShadowPaging          = errors.New("pageManagerFile: file was not created with shadow paging")
+	ErrBadPageTable            = errors.New("pageManagerFile: shadow Page table is corrupt")
+	ErrSnapshotReleased        = errors.New("pageManagerFile: snapshot has been released")
+	ErrFileLocked              = errors.New("pageManagerFile: file is locked, it is already open elsewhere")
+	ErrReadOnly                = errors.New("pageManagerFile: file was opened read-only")
+	ErrUseAfterModify          = errors.New("pageManagerFile: borrowed record data was written after the Page was modified")
+	ErrReadOnlyRecovery        = errors.New("pageManagerFile: file needs recovery, which can not be done read-only")
 )
@@ -0,0 +1,125 @@
+package pager
+
+import (
+	"encoding/binary"
+)
+
+// Records updated through the PageManager keep their RecordID, even when
+// they grow too large to stay in the Page they were added to. When that
+// happens the record is added to another Page (or to a chain of overflow
+// pages, if it no longer fits in a Page at all), and the original slot
+// is left holding a forwarding pointer to it, marked with itemStatusForward:
+//
+//	slot:  [page id uint32][slot id uint16][padding]  ->  record
+//
+// Forwarding pointers are never chained, if a moved record has to move
+// again, the original slot is pointed at the new location, and the old
+// location is removed.
+
+// forwardRecordSize is the size of a forwarding pointer record (a
+// RecordID, padded out to the min record size)
+const forwardRecordSize = MinRecordSize
+
+// encodeForward encodes a forwarding pointer to the RecordID provided
+func encodeForward(rid *RecordID) []byte {
+	b := make([]byte, forwardRecordSize)
+	binary.LittleEndian.PutUint32(b[0:4], rid.PageID)
+	binary.LittleEndian.PutUint16(b[4:6], rid.SlotID)
+	return b
+}
+
+// decodeForward decodes the RecordID from a forwarding pointer
+func decodeForward(b []byte) *RecordID {
+	return &RecordID{
+		PageID: binary.LittleEndian.Uint32(b[0:4]),
+		SlotID: binary.LittleEndian.Uint16(b[4:6]),
+	}
+}
+
+// UpdateRecord replaces the record data for the RecordID provided, the
+// RecordID stays the same. The record is updated within its own Page if
+// there is room for it, otherwise it is moved to another Page (or spills
+// over into a chain of overflow pages) and a forwarding pointer is left
+// in its place.
+func (f *PageManager) UpdateRecord(rid *RecordID, r []byte) error {
+	f.records.Lock()
+	defer f.records.Unlock()
+	// read the Page the record lives in
+	p, err := f.ReadPage(rid.PageID)
+	if err != nil {
+		return err
+	}
+	rec, err := p.GetRecord(rid)
+	if err != nil {
+		return err
+	}
+	status := p.slotByID(rid.SlotID).itemStatus
+	// if it's the head of an overflow chain, find the rest of
+	// the chain, so it can be freed once the record is updated
+	var chain []uint32
+	if status == itemStatusOverflow {
+		chain, _, err = readOverflowChain(f.ReadPage, p, rec)
+		if err != nil {
+			return err
+		}
+		chain = chain[1:]
+	}
+	// try to update the record within its own Page first
+	if len(r) <= f.MaxRecordSize() {
+		err = p.UpdateRecord(rid, r)
+		if err == nil {
+			return f.finishUpdate(p, rid, itemStatusUsed, status, rec, chain)
+		}
+		if err != ErrNoMoreRoomInPage {
+			return err
+		}
+	}
+	// otherwise, add the record somewhere else. If we crash
+	// before the forwarding pointer is written, the new copy
+	// is orphaned, but the record itself is never lost.
+	nrid, err := f.addRecord(r)
+	if err != nil {
+		return err
+	}
+	// read the Page again (adding the record wrote pages)
+	// and replace the record with a forwarding pointer
+	p, err = f.ReadPage(rid.PageID)
+	if err != nil {
+		return err
+	}
+	err = p.UpdateRecord(rid, encodeForward(nrid))
+	if err != nil {
+		return err
+	}
+	return f.finishUpdate(p, rid, itemStatusForward, status, rec, chain)
+}
+
+// finishUpdate marks the updated record in Page p with the status
+// provided, and writes it along with any pages needed to remove what
+// the record used to hold (the overflow chain, or the record that the
+// old forwarding pointer pointed to), all in a single commit
+func (f *PageManager) finishUpdate(
+	p *Page, rid *RecordID, status, oldStatus uint16, old []byte, chain []uint32,
+) error {
+	ps := []*Page{p}
+	switch oldStatus {
+	case itemStatusOverflow:
+		// the Page no longer starts an overflow chain, so
+		// unlink it, and free the rest of the chain
+		p.header.nextPageID, p.header.hasOverflow = 0, 0
+		for _, pid := range chain {
+			ps = append(ps, f.NewPage(pid))
+		}
+	case itemStatusForward:
+		// remove the record the old pointer pointed to
+		moved, err := f.delRecordPages(decodeForward(old))
+		if err != nil {
+			return err
+		}
+		ps = append(ps, moved...)
+	}
+	p.slotByID(rid.SlotID).itemStatus = status
+	// mark the Page as one that can be shared
+	p.header.reserved |= pageFlagRecords
+	return f.WritePages(ps)
+}
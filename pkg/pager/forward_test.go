@@ -0,0 +1,104 @@
+package pager
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestPageManager_UpdateRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "update.db")
+	f, err := OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	// fill most of a Page up with records
+	var rids []*RecordID
+	for i := 0; i < 7; i++ {
+		rid, err := f.AddRecord(bytes.Repeat([]byte{byte('a' + i)}, 1000))
+		if err != nil {
+			t.Fatalf("[file] add record: %s", err)
+		}
+		rids = append(rids, rid)
+	}
+	check := func(rid *RecordID, want []byte) {
+		t.Helper()
+		rec, err := f.GetRecord(rid)
+		if err != nil {
+			t.Fatalf("[file] get record: %s", err)
+		}
+		if !bytes.Equal(rec, want) {
+			t.Errorf("[file] record %v: got %d bytes, want %d", rid, len(rec), len(want))
+		}
+	}
+	// an update that fits stays in the same Page
+	small := bytes.Repeat([]byte{'s'}, 500)
+	err = f.UpdateRecord(rids[0], small)
+	if err != nil {
+		t.Fatalf("[file] update record: %s", err)
+	}
+	check(rids[0], small)
+	// an update that no longer fits is moved to another Page
+	big := bytes.Repeat([]byte{'b'}, 3000)
+	err = f.UpdateRecord(rids[1], big)
+	if err != nil {
+		t.Fatalf("[file] update record: %s", err)
+	}
+	check(rids[1], big)
+	p, err := f.ReadPage(rids[1].PageID)
+	if err != nil {
+		t.Fatalf("[file] read page: %s", err)
+	}
+	if p.slotByID(rids[1].SlotID).itemStatus != itemStatusForward {
+		t.Errorf("[file] expected a forwarding pointer for %v", rids[1])
+	}
+	// moving it again (into an overflow chain) and back
+	// should never leave a chain of forwarding pointers
+	huge := makeRecord(3 * f.MaxRecordSize())
+	err = f.UpdateRecord(rids[1], huge)
+	if err != nil {
+		t.Fatalf("[file] update record: %s", err)
+	}
+	check(rids[1], huge)
+	err = f.UpdateRecord(rids[1], small)
+	if err != nil {
+		t.Fatalf("[file] update record: %s", err)
+	}
+	check(rids[1], small)
+	// the other records should be untouched
+	for i := 2; i < len(rids); i++ {
+		check(rids[i], bytes.Repeat([]byte{byte('a' + i)}, 1000))
+	}
+	// an overflow record can be updated in place too
+	rid, err := f.AddRecord(huge)
+	if err != nil {
+		t.Fatalf("[file] add record: %s", err)
+	}
+	err = f.UpdateRecord(rid, small)
+	if err != nil {
+		t.Fatalf("[file] update record: %s", err)
+	}
+	check(rid, small)
+	// all the moved and overflow pages should have been freed
+	if f.PageCount()-f.freePages != 2 {
+		t.Errorf("[file] expected 2 pages in use, got %d of %d free",
+			f.freePages, f.PageCount())
+	}
+	// deleting a moved record removes it from where it lives now
+	err = f.UpdateRecord(rids[2], big)
+	if err != nil {
+		t.Fatalf("[file] update record: %s", err)
+	}
+	err = f.DelRecord(rids[2])
+	if err != nil {
+		t.Fatalf("[file] delete record: %s", err)
+	}
+	_, err = f.GetRecord(rids[2])
+	if err != ErrRecordHasBeenMarkedFree {
+		t.Errorf("[file] expected %v, got %v", ErrRecordHasBeenMarkedFree, err)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatalf("[file] close: %s", err)
+	}
+}
@@ -0,0 +1,321 @@
+package pager
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// The free-space map (FSM) keeps track of how much room is left in every
+// Page of the PageManager, so a Page with enough room for a record can be
+// found without scanning every Page header. Each Page gets a 4 bit entry
+// holding its free-space category:
+//
+//	0     = the Page has no room (or is not a record Page)
+//	1-14  = the Page has at least n/16ths of the Page size free
+//	15    = the Page is free, and can be reused from scratch
+//
+// The entries are stored (two per byte) in dedicated FSM pages, which live
+// in a file alongside the PageManager. FSM pages are always fsmPageSize
+// bytes (no matter what size the PageManager pages are), and each FSM Page
+// has a small header:
+//
+//	bytes 0-4   = magic ("FSM1")
+//	bytes 4-8   = checksum (crc32c of the rest of the FSM Page)
+//	bytes 8-... = entries
+//
+// In memory, the entries make up the leaves of a max-tree (each node holds
+// the largest category found below it), so finding a Page with at least a
+// given category takes O(log n). The FSM is only ever used as a hint, a
+// Page is always checked before it is used. The FSM file is only written
+// when the PageManager is closed, and it is invalidated as soon as it has
+// been loaded, so it is only ever trusted if the PageManager was closed
+// cleanly the last time it was open. Otherwise (after a crash, or if it
+// is missing or fails verification) it is rebuilt from the Page headers.
+
+const (
+	fsmFileSuffix     = ".fsm"
+	fsmMagic          = 0x314d5346 // "FSM1"
+	fsmPageSize       = 8 << 10    // 8 KB
+	fsmPageHeaderSize = 8
+	fsmEntriesPerPage = (fsmPageSize - fsmPageHeaderSize) * 2
+
+	// fsmFull marks a Page that has no room for records
+	fsmFull uint8 = 0
+	// fsmMaxCategory is the largest free-space category
+	// a Page that is in use can have
+	fsmMaxCategory uint8 = 14
+	// fsmPageFree marks a Page that is free to be reused
+	fsmPageFree uint8 = 15
+)
+
+// category returns the free-space category for the Page header
+// provided. Only pages holding records added through the PageManager
+// (and that are not part of an overflow chain) can share their room.
+func (m *freeSpaceMap) category(h *pageHeader) uint8 {
+	if h.PageIsFree() {
+		return fsmPageFree
+	}
+	if h.reserved&pageFlagRecords == 0 || h.hasOverflow != 0 {
+		return fsmFull
+	}
+	c := int(h.FreeSpace()) / m.categorySize()
+	if c > int(fsmMaxCategory) {
+		return fsmMaxCategory
+	}
+	return uint8(c)
+}
+
+// categoryFor returns the smallest free-space category a Page must
+// have in order to fit a record of the provided size (along with the
+// slot for it). Records too large for any partially used Page can only
+// go in a free Page.
+func (m *freeSpaceMap) categoryFor(recordSize int) uint8 {
+	_, slot := pageLayout(pageFormatFor(m.pageSize))
+	c := (recordSize + slot + m.categorySize() - 1) / m.categorySize()
+	if c > int(fsmMaxCategory) {
+		return fsmPageFree
+	}
+	return uint8(c)
+}
+
+// freeSpaceMap is the in memory free-space map, backed by FSM pages
+// stored in its own file (unless it is kept in memory only)
+type freeSpaceMap struct {
+	fp       *os.File     // nil if the map is kept in memory only
+	tree     []uint8      // max-tree of categories, leaves start at tree[size]
+	size     int          // number of leaves in the tree (a power of two)
+	dirty    map[int]bool // FSM pages changed since the last flush
+	pageSize int          // size of the pages being tracked
+}
+
+// openFreeSpaceMap opens (or creates) the free-space map file
+// located at the path provided, for a PageManager using pages
+// of the default size (see setPageSize)
+func openFreeSpaceMap(path string) (*freeSpaceMap, error) {
+	fp, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return newFreeSpaceMap(fp), nil
+}
+
+// newFreeSpaceMap returns an empty free-space map backed by the file
+// provided. If the file is nil, the map is kept in memory only (it is
+// always rebuilt from the Page headers, and it is never written).
+func newFreeSpaceMap(fp *os.File) *freeSpaceMap {
+	return &freeSpaceMap{
+		fp:       fp,
+		tree:     make([]uint8, 2),
+		size:     1,
+		dirty:    make(map[int]bool),
+		pageSize: DefaultPageSize,
+	}
+}
+
+// setPageSize sets the size of the pages the free-space map is
+// tracking, which the free-space categories are relative to
+func (m *freeSpaceMap) setPageSize(size int) {
+	m.pageSize = size
+}
+
+// categorySize returns the number of bytes of free space
+// each free-space category stands for
+func (m *freeSpaceMap) categorySize() int {
+	return m.pageSize / 16
+}
+
+// load reads in the FSM pages covering the first n pages. It returns
+// false if the FSM pages are missing or fail verification, in which
+// case the map needs to be rebuilt.
+func (m *freeSpaceMap) load(n int) (bool, error) {
+	m.grow(n)
+	if m.fp == nil {
+		return false, nil
+	}
+	buf := make([]byte, fsmPageSize)
+	for i := 0; i*fsmEntriesPerPage < n; i++ {
+		_, err := m.fp.ReadAt(buf, int64(i)*fsmPageSize)
+		if err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return false, nil
+			}
+			return false, err
+		}
+		// verify the FSM Page
+		if binary.LittleEndian.Uint32(buf[0:4]) != fsmMagic ||
+			binary.LittleEndian.Uint32(buf[4:8]) != crc32.Checksum(buf[fsmPageHeaderSize:], crc32c) {
+			return false, nil
+		}
+		// and decode the entries into the leaves
+		for j := 0; j < fsmEntriesPerPage; j++ {
+			pid := i*fsmEntriesPerPage + j
+			if pid >= n {
+				break
+			}
+			b := buf[fsmPageHeaderSize+j/2]
+			if j%2 == 1 {
+				b >>= 4
+			}
+			m.tree[m.size+pid] = b & 0x0f
+		}
+	}
+	// fill in the rest of the tree
+	for i := m.size - 1; i > 0; i-- {
+		m.tree[i] = maxUint8(m.tree[2*i], m.tree[2*i+1])
+	}
+	return true, nil
+}
+
+// invalidate marks the FSM file as no good (by clearing the magic of the
+// first FSM Page) until it is flushed, so it is rebuilt if the PageManager
+// is not closed cleanly
+func (m *freeSpaceMap) invalidate() error {
+	if m.fp == nil {
+		return nil
+	}
+	_, err := m.fp.WriteAt(make([]byte, 4), 0)
+	if err != nil {
+		return err
+	}
+	m.dirty[0] = true
+	return m.fp.Sync()
+}
+
+// rebuild sets the category of every Page using the Page headers
+// provided, and marks every FSM Page as needing to be written
+func (m *freeSpaceMap) rebuild(hs []*pageHeader) {
+	for _, h := range hs {
+		m.set(h.pageID, m.category(h))
+	}
+	for i := 0; i*fsmEntriesPerPage < m.size; i++ {
+		m.dirty[i] = true
+	}
+}
+
+// grow makes sure there is room in the tree for n leaves
+func (m *freeSpaceMap) grow(n int) {
+	if n <= m.size {
+		return
+	}
+	size := m.size
+	for size < n {
+		size *= 2
+	}
+	tree := make([]uint8, 2*size)
+	copy(tree[size:], m.tree[m.size:])
+	for i := size - 1; i > 0; i-- {
+		tree[i] = maxUint8(tree[2*i], tree[2*i+1])
+	}
+	m.tree, m.size = tree, size
+}
+
+// get returns the free-space category for the Page provided
+func (m *freeSpaceMap) get(pid uint32) uint8 {
+	if int(pid) >= m.size {
+		return fsmFull
+	}
+	return m.tree[m.size+int(pid)]
+}
+
+// set updates the free-space category for the Page provided
+func (m *freeSpaceMap) set(pid uint32, c uint8) {
+	m.grow(int(pid) + 1)
+	i := m.size + int(pid)
+	if m.tree[i] == c {
+		return
+	}
+	m.tree[i] = c
+	m.dirty[int(pid)/fsmEntriesPerPage] = true
+	// update the max values on the way back up to the root
+	for i /= 2; i > 0; i /= 2 {
+		c = maxUint8(m.tree[2*i], m.tree[2*i+1])
+		if m.tree[i] == c {
+			break
+		}
+		m.tree[i] = c
+	}
+}
+
+// search returns the first Page (starting at the Page id provided)
+// that has a free-space category of at least c. It returns false if
+// there are no matching pages.
+func (m *freeSpaceMap) search(c uint8, from uint32) (uint32, bool) {
+	pid := m.find(1, 0, m.size, int(from), c)
+	if pid < 0 {
+		return 0, false
+	}
+	return uint32(pid), true
+}
+
+// find is called recursively by search, i is the tree node covering
+// the leaves in the range [lo, hi)
+func (m *freeSpaceMap) find(i, lo, hi, from int, c uint8) int {
+	// skip anything before from, or without enough room
+	if hi <= from || m.tree[i] < c {
+		return -1
+	}
+	if hi-lo == 1 {
+		return lo
+	}
+	mid := (lo + hi) / 2
+	if pid := m.find(2*i, lo, mid, from, c); pid >= 0 {
+		return pid
+	}
+	return m.find(2*i+1, mid, hi, from, c)
+}
+
+// flush writes any FSM pages that have changed since the last flush
+func (m *freeSpaceMap) flush() error {
+	if m.fp == nil {
+		m.dirty = make(map[int]bool)
+		return nil
+	}
+	if len(m.dirty) == 0 {
+		return nil
+	}
+	buf := make([]byte, fsmPageSize)
+	for i := range m.dirty {
+		// encode the entries
+		for j := range buf {
+			buf[j] = 0
+		}
+		for j := 0; j < fsmEntriesPerPage; j++ {
+			pid := i*fsmEntriesPerPage + j
+			if pid >= m.size {
+				break
+			}
+			c := m.tree[m.size+pid]
+			if j%2 == 1 {
+				c <<= 4
+			}
+			buf[fsmPageHeaderSize+j/2] |= c
+		}
+		// and the FSM Page header
+		binary.LittleEndian.PutUint32(buf[0:4], fsmMagic)
+		binary.LittleEndian.PutUint32(buf[4:8], crc32.Checksum(buf[fsmPageHeaderSize:], crc32c))
+		_, err := m.fp.WriteAt(buf, int64(i)*fsmPageSize)
+		if err != nil {
+			return err
+		}
+	}
+	m.dirty = make(map[int]bool)
+	return m.fp.Sync()
+}
+
+// close flushes and closes the free-space map
+func (m *freeSpaceMap) close() error {
+	err := m.flush()
+	if err != nil || m.fp == nil {
+		return err
+	}
+	return m.fp.Close()
+}
+
+func maxUint8(a, b uint8) uint8 {
+	if a > b {
+		return a
+	}
+	return b
+}
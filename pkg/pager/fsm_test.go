@@ -0,0 +1,200 @@
+package pager
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFreeSpaceMap_Search(t *testing.T) {
+	m := &freeSpaceMap{tree: make([]uint8, 2), size: 1, dirty: make(map[int]bool)}
+	for pid := uint32(0); pid < 1000; pid++ {
+		m.set(pid, uint8(pid%7))
+	}
+	m.set(500, fsmPageFree)
+	if pid, ok := m.search(6, 0); !ok || pid != 6 {
+		t.Errorf("search: expected 6, got %d, %v", pid, ok)
+	}
+	if pid, ok := m.search(6, 7); !ok || pid != 13 {
+		t.Errorf("search: expected 13, got %d, %v", pid, ok)
+	}
+	if pid, ok := m.search(fsmPageFree, 0); !ok || pid != 500 {
+		t.Errorf("search: expected 500, got %d, %v", pid, ok)
+	}
+	if pid, ok := m.search(fsmPageFree, 501); ok {
+		t.Errorf("search: expected nothing, got %d", pid)
+	}
+}
+
+func TestPageManager_FreeSpaceMap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fsm.db")
+	f, err := OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	makeRec := func(i int) []byte {
+		return []byte(fmt.Sprintf("record-%.6d-%s", i, bytes.Repeat([]byte("x"), 200)))
+	}
+	// small records should get packed into the same pages
+	var rids []*RecordID
+	for i := 0; i < 500; i++ {
+		rid, err := f.AddRecord(makeRec(i))
+		if err != nil {
+			t.Fatalf("[file] add record: %s", err)
+		}
+		rids = append(rids, rid)
+	}
+	if f.PageCount() > 500*(len(makeRec(0))+pageSlotSize)/MaxRecordSize+5 {
+		t.Fatalf("[file] expected records to be packed, got %d pages", f.PageCount())
+	}
+	// deleting records should make room for new ones
+	pages := f.PageCount()
+	for i := 0; i < 250; i++ {
+		err = f.DelRecord(rids[i])
+		if err != nil {
+			t.Fatalf("[file] del record: %s", err)
+		}
+	}
+	for i := 0; i < 250; i++ {
+		rids[i], err = f.AddRecord(makeRec(i))
+		if err != nil {
+			t.Fatalf("[file] add record: %s", err)
+		}
+	}
+	if f.PageCount() > pages+1 {
+		t.Fatalf("[file] expected pages to be reused, had %d pages, now %d", pages, f.PageCount())
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatalf("[file] close: %s", err)
+	}
+	// the free-space map should be loaded on open, and
+	// rebuilt if it is missing
+	for _, remove := range []bool{false, true} {
+		if remove {
+			err = os.Remove(path + fsmFileSuffix)
+			if err != nil {
+				t.Fatalf("[file] remove fsm: %s", err)
+			}
+		}
+		f, err = OpenPageManager(path)
+		if err != nil {
+			t.Fatalf("[file] open: %s", err)
+		}
+		for _, h := range f.pageHeaders {
+			if c := f.fsm.get(h.pageID); c != f.fsm.category(h) {
+				t.Errorf("[file] page %d: expected category %d, got %d", h.pageID, f.fsm.category(h), c)
+			}
+		}
+		for i, rid := range rids {
+			rec, err := f.GetRecord(rid)
+			if err != nil || !bytes.Equal(rec, makeRec(i)) {
+				t.Fatalf("[file] get record %d: got %q, %v", i, rec, err)
+			}
+		}
+		err = f.Close()
+		if err != nil {
+			t.Fatalf("[file] close: %s", err)
+		}
+	}
+}
+
+func TestFreeSpaceMap_Recover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fsm-recover.db")
+	f, err := OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	_, err = f.AddRecord([]byte("this-is-record-000001"))
+	if err != nil {
+		t.Fatalf("[file] add record: %s", err)
+	}
+	rid, err := f.AddRecord(bytes.Repeat([]byte{'x'}, 30000))
+	if err != nil {
+		t.Fatalf("[file] add record: %s", err)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatalf("[file] close: %s", err)
+	}
+	// free the overflow chain, and "crash" before the
+	// free-space map has been written out
+	f, err = OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	err = f.DelRecord(rid)
+	if err != nil {
+		t.Fatalf("[file] del record: %s", err)
+	}
+	crash(t, f)
+	// the freed pages should still be found once the
+	// deleted record has been replayed from the log
+	f, err = OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	defer f.Close()
+	free := f.GetFreePageIDs()
+	if len(free) == 0 || len(free) != f.freePages {
+		t.Fatalf("[file] expected %d free pages, got %v", f.freePages, free)
+	}
+	count := f.PageCount()
+	p := f.GetFreeOrAllocate()
+	if int(p.PageID()) >= count {
+		t.Errorf("[file] expected a free page to be reused, got new page %d", p.PageID())
+	}
+}
+
+func TestFreeSpaceMap_CleanClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fsm-clean.db")
+	f, err := OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	_, err = f.AddRecord([]byte("this-is-record-000001"))
+	if err != nil {
+		t.Fatalf("[file] add record: %s", err)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatalf("[file] close: %s", err)
+	}
+	// checkFile reports if the free-space map file checks out
+	checkFile := func() bool {
+		m, err := openFreeSpaceMap(path + fsmFileSuffix)
+		if err != nil {
+			t.Fatalf("[fsm] open: %s", err)
+		}
+		defer m.fp.Close()
+		ok, err := m.load(1)
+		if err != nil {
+			t.Fatalf("[fsm] load: %s", err)
+		}
+		return ok
+	}
+	// a clean close leaves a free-space map that can be trusted
+	if !checkFile() {
+		t.Fatalf("[fsm] expected the map to check out after a clean close")
+	}
+	// but it is no good while the PageManager is open, so
+	// it is not trusted after a crash
+	f, err = OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	_, err = f.AddRecord([]byte("this-is-record-000002"))
+	if err != nil {
+		t.Fatalf("[file] add record: %s", err)
+	}
+	err = f.checkpoint()
+	if err != nil {
+		t.Fatalf("[file] checkpoint: %s", err)
+	}
+	crash(t, f)
+	if checkFile() {
+		t.Fatalf("[fsm] expected the map not to check out after a crash")
+	}
+}
@@ -0,0 +1,114 @@
+package pager
+
+import (
+	"sort"
+	"sync"
+)
+
+// A PageManager is safe for concurrent use. Every Page has its own
+// reader/writer latch, so any number of goroutines can read a Page at the
+// same time, and goroutines reading and writing different pages never wait
+// on each other. The shared state of the PageManager is guarded by a few
+// more locks, which are always taken in this order (and never the other
+// way around):
+//
+//	Page latches        (lowest Page id first, see latchTable.lock)
+//	PageManager.mu      (Page headers, free-space map, superblock, Page table)
+//	versionStore        (commit timestamps and Page versions, see mvcc.go)
+//
+// Writers also hold the checkpoint lock (shared) from the time they log
+// their pages until the pages have been written to the data file, so the
+// write-ahead log is never truncated out from under them. The write-ahead
+// log and the storage backends have their own locks as well.
+//
+// Record level operations (AddRecord, UpdateRecord and DelRecord) read,
+// change and write back whole pages, and a record may be spread over many
+// pages, so they are serialized with one another. They can still run
+// alongside Page level reads and writes of other pages.
+
+// latchTable hands out a reader/writer latch for each Page id. Latches
+// are made the first time a Page is latched, and are thrown away once
+// nobody is holding (or waiting on) them.
+type latchTable struct {
+	sync.Mutex
+	latches map[uint32]*pageLatch
+}
+
+// pageLatch is the latch for a single Page, along with the
+// number of goroutines holding (or waiting on) it
+type pageLatch struct {
+	sync.RWMutex
+	refs int
+}
+
+// acquire returns the latch for the Page id provided, making
+// it if it does not exist yet
+func (lt *latchTable) acquire(pid uint32) *pageLatch {
+	lt.Lock()
+	defer lt.Unlock()
+	if lt.latches == nil {
+		lt.latches = make(map[uint32]*pageLatch)
+	}
+	l, ok := lt.latches[pid]
+	if !ok {
+		l = new(pageLatch)
+		lt.latches[pid] = l
+	}
+	l.refs++
+	return l
+}
+
+// release returns the latch for the Page id provided, throwing
+// it away if nobody else is holding (or waiting on) it
+func (lt *latchTable) release(pid uint32) *pageLatch {
+	lt.Lock()
+	defer lt.Unlock()
+	l := lt.latches[pid]
+	l.refs--
+	if l.refs == 0 {
+		delete(lt.latches, pid)
+	}
+	return l
+}
+
+// rlock latches the Page with the id provided for reading
+func (lt *latchTable) rlock(pid uint32) {
+	lt.acquire(pid).RLock()
+}
+
+// runlock releases a read latch taken using rlock
+func (lt *latchTable) runlock(pid uint32) {
+	lt.release(pid).RUnlock()
+}
+
+// lock latches every Page with an id provided for writing. The latches
+// are taken in Page id order (skipping any duplicate ids), so two writers
+// can never be left waiting on one another. It returns the ids that were
+// latched, which must be handed back to unlock.
+func (lt *latchTable) lock(pids ...uint32) []uint32 {
+	sorted := make([]uint32, 0, len(pids))
+	sorted = append(sorted, pids...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	n := 0
+	for i, pid := range sorted {
+		if i > 0 && pid == sorted[n-1] {
+			continue
+		}
+		sorted[n] = pid
+		n++
+	}
+	sorted = sorted[:n]
+	for _, pid := range sorted {
+		lt.acquire(pid).Lock()
+	}
+	return sorted
+}
+
+// unlock releases the write latches taken using lock
+func (lt *latchTable) unlock(pids []uint32) {
+	for i := len(pids) - 1; i >= 0; i-- {
+		lt.release(pids[i]).Unlock()
+	}
+}
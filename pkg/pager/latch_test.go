@@ -0,0 +1,136 @@
+package pager
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestPageManager_Concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "concurrent.db")
+	f, err := OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	defer f.Close()
+	const workers, writes = 8, 50
+	// every worker allocates its own page, and keeps writing and
+	// reading it back while the other workers do the same
+	var wg sync.WaitGroup
+	pids := make([]uint32, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			p := f.AllocatePage()
+			pids[w] = p.PageID()
+			for i := 0; i < writes; i++ {
+				want := fmt.Sprintf("worker-%.2d-write-%.4d", w, i)
+				p := f.NewPage(pids[w])
+				_, err := p.AddRecord([]byte(want))
+				if err != nil {
+					t.Errorf("[Page] add record: %s", err)
+					return
+				}
+				err = f.WritePage(p)
+				if err != nil {
+					t.Errorf("[file] write page: %s", err)
+					return
+				}
+				got, err := f.ReadPage(pids[w])
+				if err != nil {
+					t.Errorf("[file] read page: %s", err)
+					return
+				}
+				rec, err := got.GetRecord(&RecordID{PageID: pids[w], SlotID: 0})
+				if err != nil || string(rec) != want {
+					t.Errorf("[file] page %d: expected %q, got %q (%v)", pids[w], want, rec, err)
+					return
+				}
+			}
+		}(w)
+	}
+	// while records are being added from many goroutines
+	rids := make([][]*RecordID, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < writes; i++ {
+				rid, err := f.AddRecord([]byte(fmt.Sprintf("record-%.2d-%.4d", w, i)))
+				if err != nil {
+					t.Errorf("[file] add record: %s", err)
+					return
+				}
+				rids[w] = append(rids[w], rid)
+			}
+		}(w)
+	}
+	wg.Wait()
+	// every page id handed out should be different
+	seen := make(map[uint32]bool)
+	for _, pid := range pids {
+		if seen[pid] {
+			t.Fatalf("[file] page id %d handed out twice", pid)
+		}
+		seen[pid] = true
+	}
+	// and no record should have been lost
+	for w := range rids {
+		for i, rid := range rids[w] {
+			want := fmt.Sprintf("record-%.2d-%.4d", w, i)
+			rec, err := f.GetRecord(rid)
+			if err != nil || string(rec) != want {
+				t.Fatalf("[file] record %v: expected %q, got %q (%v)", rid, want, rec, err)
+			}
+		}
+	}
+}
+
+func TestPageManager_ConcurrentGetFreeOrAllocate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "concurrent.db")
+	f, err := OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	defer f.Close()
+	const n = 16
+	// write some pages, and free them
+	for i := 0; i < n; i++ {
+		p := f.AllocatePage()
+		_, err = p.AddRecord([]byte("some record data"))
+		if err != nil {
+			t.Fatalf("[Page] add record: %s", err)
+		}
+		err = f.WritePage(p)
+		if err != nil {
+			t.Fatalf("[file] write page: %s", err)
+		}
+		err = f.DeletePage(p.PageID())
+		if err != nil {
+			t.Fatalf("[file] delete page: %s", err)
+		}
+	}
+	// no free page should be handed out twice
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := make(map[uint32]bool)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := f.GetFreeOrAllocate()
+			mu.Lock()
+			defer mu.Unlock()
+			if seen[p.PageID()] {
+				t.Errorf("[file] page id %d handed out twice", p.PageID())
+			}
+			seen[p.PageID()] = true
+		}()
+	}
+	wg.Wait()
+	if f.PageCount() != n {
+		t.Errorf("[file] expected %d pages, got %d", n, f.PageCount())
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package pager
+
+import (
+	"os"
+)
+
+// lockFile is a no-op, advisory file locking is not supported on
+// this platform, so files are opened without a lock
+func lockFile(fp *os.File, shared bool) error {
+	return nil
+}
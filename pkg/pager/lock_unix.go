@@ -0,0 +1,31 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package pager
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an advisory lock (using flock) on the file provided. The
+// lock is exclusive, unless shared is true. It never waits for the lock,
+// if the file is already locked elsewhere it returns ErrFileLocked. The
+// lock is released once the file is closed.
+func lockFile(fp *os.File, shared bool) error {
+	how := syscall.LOCK_EX
+	if shared {
+		how = syscall.LOCK_SH
+	}
+	for {
+		err := syscall.Flock(int(fp.Fd()), how|syscall.LOCK_NB)
+		switch err {
+		case syscall.EINTR:
+			// interrupted, try again
+			continue
+		case syscall.EWOULDBLOCK:
+			return ErrFileLocked
+		}
+		return err
+	}
+}
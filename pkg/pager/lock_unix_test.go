@@ -0,0 +1,93 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package pager
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPageManager_FileLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock.db")
+	f, err := OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	rid, err := f.AddRecord([]byte("this-is-record-000001"))
+	if err != nil {
+		t.Fatalf("[file] add record: %s", err)
+	}
+	// nobody else can open it while it is open for writing
+	_, err = OpenPageManager(path)
+	if err != ErrFileLocked {
+		t.Fatalf("[file] expected %q, got %v", ErrFileLocked, err)
+	}
+	_, err = OpenPageManagerReadOnly(path)
+	if err != ErrFileLocked {
+		t.Fatalf("[file] expected %q (read-only), got %v", ErrFileLocked, err)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatalf("[file] close: %s", err)
+	}
+	// but once it is closed, it can be opened read-only more than once
+	r1, err := OpenPageManagerReadOnly(path)
+	if err != nil {
+		t.Fatalf("[file] open read-only: %s", err)
+	}
+	r2, err := OpenPageManagerReadOnly(path)
+	if err != nil {
+		t.Fatalf("[file] open read-only (again): %s", err)
+	}
+	rec, err := r2.GetRecord(rid)
+	if err != nil || string(rec) != "this-is-record-000001" {
+		t.Fatalf("[file] get record: got %q (%v)", rec, err)
+	}
+	_, err = r2.AddRecord([]byte("this-is-record-000002"))
+	if err != ErrReadOnly {
+		t.Fatalf("[file] expected %q, got %v", ErrReadOnly, err)
+	}
+	err = r2.SetRoot("root", 0)
+	if err != ErrReadOnly {
+		t.Fatalf("[file] expected %q, got %v", ErrReadOnly, err)
+	}
+	// and it can not be opened for writing until they are all closed
+	_, err = OpenPageManager(path)
+	if err != ErrFileLocked {
+		t.Fatalf("[file] expected %q, got %v", ErrFileLocked, err)
+	}
+	for _, r := range []*PageManager{r1, r2} {
+		err = r.Close()
+		if err != nil {
+			t.Fatalf("[file] close read-only: %s", err)
+		}
+	}
+	// a file that needs recovery can not be opened read-only
+	f, err = OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	_, err = f.AddRecord([]byte("this-is-record-000003"))
+	if err != nil {
+		t.Fatalf("[file] add record: %s", err)
+	}
+	crash(t, f)
+	_, err = OpenPageManagerReadOnly(path)
+	if err != ErrReadOnlyRecovery {
+		t.Fatalf("[file] expected %q, got %v", ErrReadOnlyRecovery, err)
+	}
+	f, err = OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open (recover): %s", err)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatalf("[file] close: %s", err)
+	}
+	// a read-only open never creates a file
+	_, err = OpenPageManagerReadOnly(filepath.Join(t.TempDir(), "missing.db"))
+	if err == nil {
+		t.Fatalf("[file] expected an error opening a missing file read-only")
+	}
+}
@@ -4,22 +4,114 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sync"
 )
 
-// PageManager is a slotted Page PageManager manager
+// PageManager is a slotted Page PageManager manager. It is safe
+// for concurrent use (see latch.go).
 type PageManager struct {
+	mu          sync.Mutex   // guards the Page headers, free-space map, superblock and Page table
+	ckpt        sync.RWMutex // held (shared) by writers, so a checkpoint waits for them
+	records     sync.Mutex   // serializes record level operations
+	latches     latchTable
+	claimed     map[uint32]struct{} // free pages handed out, but not written yet
 	name        string
 	fp          *os.File
+	store       pageStore
 	pageHeaders []*pageHeader
 	pageCache   *Page
 	freePages   int
 	pids        *autoPageID
+	wal         *writeAheadLog
+	fsm         *freeSpaceMap
+	sb          *superblock
+	shadow      *shadowTable
+	mvcc        *versionStore
+	readOnly    bool
+	base        int64
+	pageSize    int
 }
 
 // OpenPageManager opens an existing PageManager at the location
 // provided, or creates and returns a new PageManager at
 // the path provided.
 func OpenPageManager(path string) (*PageManager, error) {
+	return openPageManager(path, openOptions{})
+}
+
+// OpenPageManagerWithFlags opens an existing PageManager at the
+// location provided, or creates and returns a new PageManager at
+// the path provided. The flags are stored in the superblock of a
+// newly created PageManager, and are ignored otherwise.
+func OpenPageManagerWithFlags(path string, flags uint32) (*PageManager, error) {
+	return openPageManager(path, openOptions{flags: flags})
+}
+
+// OpenPageManagerWithPageSize opens an existing PageManager at the
+// location provided, or creates and returns a new PageManager at the
+// path provided using pages of the provided size. The Page size must
+// be a power of two between the MinPageSize and the MaxPageSize, and
+// an existing PageManager must have been created with the same size.
+// Pages of 64 KB and larger use a wide Page format (with 32-bit item
+// offsets and lengths).
+func OpenPageManagerWithPageSize(path string, size int) (*PageManager, error) {
+	if !validPageSize(size) {
+		return nil, ErrUnsupportedPageSize
+	}
+	return openPageManager(path, openOptions{size: size})
+}
+
+// OpenPageManagerWithMmap opens an existing PageManager at the location
+// provided, or creates and returns a new PageManager at the path provided,
+// using a memory mapping of the file to read and write pages instead of
+// making a system call for every Page. Pages are still written through
+// the write-ahead log, and the mapping is synced (using msync) whenever
+// the PageManager is checkpointed. It is only supported on linux.
+func OpenPageManagerWithMmap(path string) (*PageManager, error) {
+	return openPageManager(path, openOptions{mmap: true})
+}
+
+// OpenPageManagerWithShadowPaging opens an existing PageManager at the
+// location provided, or creates and returns a new PageManager at the path
+// provided that uses shadow paging (copy-on-write) instead of logging Page
+// writes (see shadow.go). An existing PageManager must have been created
+// with shadow paging.
+func OpenPageManagerWithShadowPaging(path string) (*PageManager, error) {
+	return openPageManager(path, openOptions{shadow: true})
+}
+
+// OpenPageManagerReadOnly opens an existing PageManager at the location
+// provided for reading only. It takes a shared lock on the file, so any
+// number of processes can have it open read-only at the same time, but
+// none can have it open for writing. Anything that would write to the
+// PageManager returns ErrReadOnly. A PageManager that was not closed
+// cleanly can not be opened read-only until it has been recovered (by
+// opening it for writing).
+func OpenPageManagerReadOnly(path string) (*PageManager, error) {
+	return openPageManager(path, openOptions{readOnly: true})
+}
+
+// openOptions are the options a PageManager is opened with
+type openOptions struct {
+	// size is the Page size to use, 0 uses the Page size the PageManager
+	// was created with (or the DefaultPageSize for a new PageManager)
+	size int
+	// flags are stored in the superblock of a new PageManager
+	flags uint32
+	// mmap reads and writes pages using a memory mapping
+	mmap bool
+	// shadow creates a new PageManager that uses shadow paging
+	shadow bool
+	// readOnly opens an existing PageManager for reading only
+	readOnly bool
+}
+
+// openPageManager opens (or creates) the PageManager at the path
+// provided, using the options provided. The underlying file is locked
+// (see lockFile) for as long as the PageManager is open, exclusively
+// unless it is opened read-only. If the file is already locked it
+// returns ErrFileLocked.
+func openPageManager(path string, opts openOptions) (*PageManager, error) {
 	// sanitize path
 	path, err := filepath.Abs(path)
 	if err != nil {
@@ -30,7 +122,7 @@ func OpenPageManager(path string) (*PageManager, error) {
 	// init PageManager and dirs
 	var fp *os.File
 	_, err = os.Stat(path)
-	if os.IsNotExist(err) {
+	if os.IsNotExist(err) && !opts.readOnly {
 		// create dir
 		err = os.MkdirAll(dir, os.ModeDir)
 		if err != nil {
@@ -48,22 +140,85 @@ func OpenPageManager(path string) (*PageManager, error) {
 		}
 	}
 	// open existing PageManager
-	fp, err = os.OpenFile(path, os.O_RDWR, 0666)
+	flag := os.O_RDWR
+	if opts.readOnly {
+		flag = os.O_RDONLY
+	}
+	fp, err = os.OpenFile(path, flag, 0666)
+	if err != nil {
+		return nil, err
+	}
+	// lock it before we look at anything else (closing
+	// the file, if something goes wrong, releases it)
+	err = lockFile(fp, opts.readOnly)
 	if err != nil {
+		_ = fp.Close()
+		return nil, err
+	}
+	// open the write-ahead log and the free-space map that sit
+	// alongside the PageManager (when opened read-only, the
+	// free-space map is rebuilt and kept in memory only)
+	var wal *writeAheadLog
+	var fsm *freeSpaceMap
+	if opts.readOnly {
+		wal, err = openWriteAheadLogReadOnly(path + walFileSuffix)
+		fsm = newFreeSpaceMap(nil)
+	} else {
+		wal, err = openWriteAheadLog(path + walFileSuffix)
+		if err == nil {
+			fsm, err = openFreeSpaceMap(path + fsmFileSuffix)
+			if err != nil {
+				_ = wal.close()
+			}
+		}
+	}
+	if err != nil {
+		_ = fp.Close()
 		return nil, err
 	}
 	// create Page PageManager
 	f := &PageManager{
 		name:        filepath.Join(dir, name),
 		fp:          fp,
+		store:       &fileStore{fp: fp},
 		pageHeaders: make([]*pageHeader, 0),
 		pids:        new(autoPageID),
+		claimed:     make(map[uint32]struct{}),
+		wal:         wal,
+		fsm:         fsm,
+		mvcc:        newVersionStore(),
+		pageSize:    opts.size,
+		readOnly:    opts.readOnly,
+	}
+	// a new PageManager gets this superblock
+	size := opts.size
+	if size == 0 {
+		size = DefaultPageSize
+	}
+	f.sb = newSuperblock(opts.flags, size)
+	if opts.shadow {
+		f.sb.version = superblockVersionShadow
 	}
 	// call load
 	err = f.load()
 	if err != nil {
+		f.closeFiles()
 		return nil, err
 	}
+	// an existing PageManager can not switch to shadow paging
+	if opts.shadow && f.shadow == nil {
+		f.closeFiles()
+		return nil, ErrNoShadowPaging
+	}
+	// map the PageManager, now that it has been recovered
+	// and loaded (and has a superblock, if it is new)
+	if opts.mmap {
+		f.store, err = openMmapStore(fp)
+		if err != nil {
+			f.closeFiles()
+			return nil, err
+		}
+	}
 	// return Page PageManager
 	return f, nil
 }
@@ -71,22 +226,81 @@ func OpenPageManager(path string) (*PageManager, error) {
 // load files out the meta ([]*pageHeader) slice
 // in the Page pageManagerFile for easier Page handling
 func (f *PageManager) load() error {
+	// redo any committed Page writes that are still
+	// sitting in the write-ahead log before we look
+	// at any of the Page headers
+	err := f.recover()
+	if err != nil {
+		return err
+	}
 	// get PageManager size info
 	fi, err := f.fp.Stat()
 	if err != nil {
 		return err
 	}
-	// if this is the first run
-	// not much to do, just return
+	// if this is the first run, write
+	// out a fresh superblock and return
+	// (unless we can not write anything)
 	if fi.Size() < 1 {
+		if f.sb == nil {
+			f.sb = newSuperblock(0, DefaultPageSize)
+		}
+		f.pageSize = int(f.sb.pageSize)
+		f.base = int64(f.pageSize)
+		f.fsm.setPageSize(f.pageSize)
+		if f.sb.version == superblockVersionShadow {
+			f.shadow = newShadowTable()
+		}
+		if f.readOnly {
+			return nil
+		}
+		return f.writeSuperblock()
+	}
+	// otherwise, read in the superblock (if there is one)
+	err = f.loadSuperblock()
+	if err != nil {
+		return err
+	}
+	f.fsm.setPageSize(f.pageSize)
+	// files using shadow paging find their Page headers
+	// using the Page table, instead of reading them in order
+	if f.sb != nil && f.sb.version == superblockVersionShadow {
+		err = f.loadShadow()
+	} else {
+		err = f.loadPageHeaders()
+	}
+	if err != nil {
+		return err
+	}
+	// load the free-space map. It is only written out when the
+	// PageManager is closed, so if it is missing or no good (we
+	// crashed, see fsm.go) it is rebuilt from the Page headers
+	ok, err := f.fsm.load(len(f.pageHeaders))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		f.fsm.rebuild(f.pageHeaders)
+	}
+	if f.readOnly {
 		return nil
 	}
-	// otherwise, there should be
-	// Page headers we can load in
+	// and it is no good on disk until the PageManager is closed
+	return f.fsm.invalidate()
+}
+
+// loadPageHeaders reads in the Page headers of every Page
+// in the underlying PageManager file, in order
+func (f *PageManager) loadPageHeaders() error {
+	// skip past the superblock to the Page headers
+	_, err := f.fp.Seek(f.base, io.SeekStart)
+	if err != nil {
+		return err
+	}
 	for {
 		// read Page header data
 		var h pageHeader
-		_, err := readPageHeader(f.fp, &h)
+		_, err := readPageHeader(f.fp, &h, f.pageSize)
 		// check for an error
 		if err != nil {
 			if err == io.EOF || err == io.ErrUnexpectedEOF {
@@ -105,16 +319,105 @@ func (f *PageManager) load() error {
 	}
 	// seek back to the start
 	_, err = f.fp.Seek(0, io.SeekStart)
+	return err
+}
+
+// recover replays the write-ahead log against the underlying
+// PageManager, redoing any committed Page writes that may not
+// have made it to (or been torn in) the data file. Once the
+// data file has been synced the log is truncated.
+func (f *PageManager) recover() error {
+	// nothing to do if the log is empty
+	if f.wal.size < 1 {
+		return nil
+	}
+	// the log can not be replayed (or truncated) read-only
+	if f.readOnly {
+		return ErrReadOnlyRecovery
+	}
+	// redo the committed Page images, which start after the
+	// superblock (unless this file is using the legacy layout)
+	n, err := f.wal.replay(f.fp, f.detectBase())
 	if err != nil {
 		return err
 	}
+	// make sure the redone pages are on disk
+	// before we throw away the log
+	if n > 0 {
+		err = f.fp.Sync()
+		if err != nil {
+			return err
+		}
+	}
+	return f.wal.reset()
+}
+
+// checkpoint syncs the underlying PageManager and truncates
+// the write-ahead log, since every Page image in the log has
+// now made it safely to the data file
+func (f *PageManager) checkpoint() error {
+	// wait for any writers that have logged pages, but
+	// have not written them to the data file yet
+	f.ckpt.Lock()
+	err := f.store.flush()
+	if err == nil {
+		err = f.wal.reset()
+	}
+	f.ckpt.Unlock()
+	return err
+}
+
+// checkpointIfFull checkpoints if the write-ahead
+// log is getting too large
+func (f *PageManager) checkpointIfFull() error {
+	if f.wal.full() {
+		return f.checkpoint()
+	}
 	return nil
 }
 
 // getPagePosition calculates the Page position based
-// on the pageID provided
-func getPagePosition(pid uint32) int64 {
-	return int64(align(int(pid*pageSize), pageSize-1))
+// on the pageID and the Page size provided
+func getPagePosition(pid uint32, size int) int64 {
+	return int64(pid) * int64(size)
+}
+
+// pagePosition calculates the position of the Page in the
+// underlying PageManager file, which is found just past the
+// superblock (if there is one)
+func (f *PageManager) pagePosition(pid uint32) int64 {
+	// with shadow paging, the Page is wherever the Page
+	// table says it is (pages that are not mapped yet
+	// can not be read)
+	if f.shadow != nil {
+		f.mu.Lock()
+		ppid, ok := f.shadow.physical(pid)
+		f.mu.Unlock()
+		if !ok {
+			return -1
+		}
+		return f.physicalPosition(ppid)
+	}
+	return f.base + getPagePosition(pid, f.pageSize)
+}
+
+// PageSize returns the size of the pages in the PageManager
+func (f *PageManager) PageSize() int {
+	return f.pageSize
+}
+
+// MaxRecordSize returns the largest record that fits in a single
+// Page of the PageManager (larger records added using AddRecord
+// spill over into a chain of overflow pages)
+func (f *PageManager) MaxRecordSize() int {
+	return maxRecordSizeFor(f.pageSize)
+}
+
+// NewPage creates and returns a new Page, using the Page size
+// of the PageManager. The Page is not persisted unless a call
+// to WritePage is made
+func (f *PageManager) NewPage(pid uint32) *Page {
+	return NewPageSize(pid, f.pageSize)
 }
 
 // AllocatePage allocates and returns a new Page. The
@@ -124,54 +427,138 @@ func (f *PageManager) AllocatePage() *Page {
 	// generate new atomic Page id
 	pid := f.pids.getNewPageID()
 	// create and return a new Page
-	return NewPage(pid)
+	return f.NewPage(pid)
 }
 
 // GetFreeOrAllocate attempts to find a free Page (a
 // Page that is not in use that can be reused) and if
 // one cannot be found, it will allocate and return a
 // new one. Any alterations to the returned Page are
-// not persisted unless a call to WritePage is made
+// not persisted unless a call to WritePage is made.
+// A free Page is held for the caller until it has
+// been written, so a Page that is not going to be
+// written must be handed back using ReleasePage.
 func (f *PageManager) GetFreeOrAllocate() *Page {
-	// first check the free Page count
-	if f.freePages > 0 {
-		// looks like we indeed have some free pageHeaders, so
-		// let's iterate all the Page headers that the
-		// PageManager has and try to find a free one
-		for _, h := range f.pageHeaders {
-			// checking if Page is free
-			if h.PageIsFree() {
-				// found one, return it!
-				p, err := f.ReadPage(h.pageID)
-				if err != nil {
-					// something went wrong
-					panic("get free or allocate: " + err.Error())
-				}
-				// we should be in the clear to decrement
-				// the freePages counter, and return our
-				// found Page
-				f.freePages--
-				return p
-			}
+	p, _ := f.getFreeOrAllocate()
+	return p
+}
+
+// getFreeOrAllocate is GetFreeOrAllocate, it also
+// reports if the Page returned is a claimed free
+// Page (see claimFreePageID)
+func (f *PageManager) getFreeOrAllocate() (*Page, bool) {
+	// ask the free-space map for a free Page (if
+	// there are any free pageHeaders)
+	pid, ok := f.claimFreePageID(0)
+	if ok {
+		// found one, return it!
+		p, err := f.ReadPage(pid)
+		if err != nil {
+			// something went wrong
+			f.unclaim(pid)
+			panic("get free or allocate: " + err.Error())
 		}
+		// return our found Page (the freePages
+		// counter is decremented once the Page
+		// has been written)
+		return p, true
 	}
 	// otherwise, found no free pages in out freePages
 	// count, so we must create and return a fresh one,
 	// but first we need a fresh pageID
-	pid := f.pids.getNewPageID()
+	pid = f.pids.getNewPageID()
 	// create and return a new Page with our fresh pageID
-	return NewPage(pid)
+	return f.NewPage(pid), false
+}
+
+// ReleasePage hands back a Page returned by GetFreeOrAllocate that
+// is not going to be written, so it can be handed out again. Pages
+// that have been written do not need to be released.
+func (f *PageManager) ReleasePage(pid uint32) {
+	f.unclaim(pid)
+}
+
+// claimFreePageID finds the first free Page (starting at the Page id
+// provided) that has not already been handed out, and claims it, so it
+// is not handed out again before it is written. It returns false if
+// there are no free pages left.
+func (f *PageManager) claimFreePageID(from uint32) (uint32, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.freePages < 1 {
+		return 0, false
+	}
+	for {
+		pid, ok := f.nextFreePageID(from)
+		if !ok {
+			return 0, false
+		}
+		if _, claimed := f.claimed[pid]; !claimed {
+			f.claimed[pid] = struct{}{}
+			return pid, true
+		}
+		from = pid + 1
+	}
+}
+
+// unclaim gives back free pages claimed using claimFreePageID (or
+// searchPageWithRoom) that are not going to be written, so they can
+// be handed out again
+func (f *PageManager) unclaim(pids ...uint32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, pid := range pids {
+		delete(f.claimed, pid)
+	}
+}
+
+// nextFreePageID uses the free-space map to find the first free Page
+// (starting at the Page id provided). It returns false if there are no
+// free pages left. It must be called holding the PageManager lock.
+func (f *PageManager) nextFreePageID(from uint32) (uint32, bool) {
+	for {
+		pid, ok := f.fsm.search(fsmPageFree, from)
+		if !ok {
+			return 0, false
+		}
+		// the free-space map is only a hint, so make sure
+		// the Page header agrees that the Page is free
+		if int(pid) < len(f.pageHeaders) && f.pageHeaders[pid].PageIsFree() {
+			return pid, true
+		}
+		f.fixPageCategory(pid)
+		from = pid + 1
+	}
+}
+
+// fixPageCategory corrects the free-space map entry for a Page that
+// the free-space map was out of date for. It must be called holding
+// the PageManager lock.
+func (f *PageManager) fixPageCategory(pid uint32) {
+	if int(pid) < len(f.pageHeaders) {
+		f.fsm.set(pid, f.fsm.category(f.pageHeaders[pid]))
+		return
+	}
+	f.fsm.set(pid, fsmFull)
 }
 
 // ReadPage attempts to read the Page located at the
 // offset calculated by the provided pageID. It returns
 // an error if a Page could not be located
 func (f *PageManager) ReadPage(pid uint32) (*Page, error) {
+	// latch the Page for reading, so we never
+	// see it while it is being written
+	f.latches.rlock(pid)
+	defer f.latches.runlock(pid)
 	// calc Page offset in PageManager
-	offset := getPagePosition(pid)
+	offset := f.pagePosition(pid)
 	// read data into new Page
-	p, err := readPageAt(f.fp, offset)
+	p, err := readPageAt(f.store, offset, f.pageSize, f.legacy())
 	if err != nil {
+		// Page failed verification
+		if err == ErrPageChecksumMismatch {
+			return nil, err
+		}
 		// Page not found
 		return nil, ErrPageNotFound
 	}
@@ -183,13 +570,10 @@ func (f *PageManager) ReadPage(pid uint32) (*Page, error) {
 // offset calculated by the provided pageID. It returns
 // an error if a Page could not be located
 func (f *PageManager) ReadPages(pid uint32) ([]*Page, error) {
-	// calc Page offset in PageManager
-	offset := getPagePosition(pid)
-	// read data into new Page
-	p, err := readPageAt(f.fp, offset)
+	// read the first Page
+	p, err := f.ReadPage(pid)
 	if err != nil {
-		// Page not found
-		return nil, ErrPageNotFound
+		return nil, err
 	}
 	// check to ensure it's an overflow Page
 	if p.header.hasOverflow == 0 {
@@ -201,13 +585,10 @@ func (f *PageManager) ReadPages(pid uint32) ([]*Page, error) {
 	var pages []*Page
 	pages = append(pages, p)
 	for p.header.nextPageID > 0 {
-		// calc Page offset in PageManager
-		offset = getPagePosition(p.header.nextPageID)
-		// read data into new Page
-		p, err = readPageAt(f.fp, offset)
+		// read the next Page
+		p, err = f.ReadPage(p.header.nextPageID)
 		if err != nil {
-			// Page not found
-			return nil, ErrPageNotFound
+			return nil, err
 		}
 		// append it to the Page set
 		pages = append(pages, p)
@@ -219,90 +600,203 @@ func (f *PageManager) ReadPages(pid uint32) ([]*Page, error) {
 // WritePage writes the provided Page to the underlying PageManager
 // on disk. If something goes wrong it returns a non-nil error
 func (f *PageManager) WritePage(p *Page) error {
-	// calc Page offset in PageManager
-	offset := getPagePosition(p.header.pageID)
-	// write provided Page to PageManager
-	_, err := writePageAt(f.fp, p, offset)
-	if err != nil {
-		// something happened
-		return ErrWritingPage
-	}
-	// otherwise, we're good
-	return nil
+	return f.WritePages([]*Page{p})
 }
 
 // WritePages writes the provided pages to the underlying PageManager
 // on disk. If something goes wrong it returns a non-nil error
 func (f *PageManager) WritePages(ps []*Page) error {
+	if f.readOnly {
+		return ErrReadOnly
+	}
+	// make sure the pages are the right size
+	pids := make([]uint32, 0, len(ps))
+	for _, p := range ps {
+		if len(p.data) != f.pageSize {
+			return ErrBadPageSize
+		}
+		pids = append(pids, p.header.pageID)
+	}
+	// latch every Page being written, so nobody
+	// sees them while they are half written
+	pids = f.latches.lock(pids...)
+	err := f.writePages(ps)
+	f.latches.unlock(pids)
+	if err != nil {
+		return err
+	}
+	// checkpoint if the log is getting too large
+	return f.checkpointIfFull()
+}
+
+// writePages writes the provided pages in a single commit. It must
+// be called holding the write latches for the pages.
+func (f *PageManager) writePages(ps []*Page) error {
+	// with shadow paging, the live pages are never touched
+	if f.shadow != nil {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.writeShadowPages(ps)
+	}
+	// keep the pages being replaced, if a Snapshot needs
+	// them, and give the commit its timestamp
+	pids := make([]uint32, 0, len(ps))
+	for _, p := range ps {
+		pids = append(pids, p.header.pageID)
+	}
+	f.commitVersions(pids...)
+	// log the pages and write them to the data file
+	err := f.logAndWritePages(ps)
+	if err != nil {
+		return err
+	}
+	// update the Page headers in the cache
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, p := range ps {
+		f.setPageHeader(p.header)
+	}
+	return nil
+}
+
+// logAndWritePages logs all the Page images (as a single commit), and
+// then writes them over the live pages. The checkpoint lock is held
+// until they have been written, so the log is not truncated before
+// they have made it to the data file.
+func (f *PageManager) logAndWritePages(ps []*Page) error {
+	f.ckpt.RLock()
+	defer f.ckpt.RUnlock()
+	// log all the Page images before we touch the live pages
+	err := f.wal.logPages(ps...)
+	if err != nil {
+		// something happened
+		return ErrWritingLog
+	}
 	// iterate the pages
 	for i := range ps {
 		// Page at index i
 		p := ps[i]
 		// calc Page offset in PageManager
-		offset := getPagePosition(p.header.pageID)
+		offset := f.pagePosition(p.header.pageID)
 		// write provided Page to PageManager
-		_, err := writePageAt(f.fp, p, offset)
+		_, err := writePageAt(f.store, p, offset)
 		if err != nil {
 			// something happened
 			return ErrWritingPage
 		}
-		// otherwise, we're good
 	}
 	return nil
 }
 
+// setPageHeader updates the cached Page header for a Page that has
+// just been written, keeping the free Page count in sync. Cached
+// Page headers are replaced, and never changed in place, so they can
+// be looked at without holding the PageManager lock once they have
+// been handed out (see headers). It must be called holding the
+// PageManager lock.
+func (f *PageManager) setPageHeader(h *pageHeader) {
+	// the Page is no longer waiting to be written
+	delete(f.claimed, h.pageID)
+	// check for an existing cached Page header
+	if int(h.pageID) < len(f.pageHeaders) {
+		ph := f.pageHeaders[h.pageID]
+		if ph.PageIsFree() && !h.PageIsFree() {
+			f.freePages--
+		}
+		if !ph.PageIsFree() && h.PageIsFree() {
+			f.freePages++
+		}
+		nh := *h
+		f.pageHeaders[h.pageID] = &nh
+		f.fsm.set(h.pageID, f.fsm.category(h))
+		return
+	}
+	// otherwise, grow the list out to cover the Page, pages can be
+	// written out of allocation order. Any pages skipped over have been
+	// allocated, but not written yet, so they get a fresh (free) header
+	// and are claimed, so they are not handed out to anyone else before
+	// they are written.
+	for pid := uint32(len(f.pageHeaders)); pid < h.pageID; pid++ {
+		gh := f.NewPage(pid).header
+		f.pageHeaders = append(f.pageHeaders, gh)
+		f.freePages++
+		f.claimed[pid] = struct{}{}
+		f.fsm.set(pid, f.fsm.category(gh))
+	}
+	ph := *h
+	f.pageHeaders = append(f.pageHeaders, &ph)
+	if ph.PageIsFree() {
+		f.freePages++
+	}
+	f.fsm.set(h.pageID, f.fsm.category(h))
+}
+
 // DeletePage marks the Page with the matching pageID provided
-// as "free" and writes zeros to the underlying Page on disk
+// as "free" and writes a fresh (empty) Page over it on disk
 func (f *PageManager) DeletePage(pid uint32) error {
-	// calc Page offset in PageManager
-	offset := getPagePosition(pid)
-	// write zeros to the Page found
-	// at "offset" on the underlying
-	// storage PageManager
-	_, err := deletePageAt(f.fp, pid, offset)
-	if err != nil {
-		// something happened
+	if f.readOnly {
+		return ErrReadOnly
+	}
+	// replace the Page with a fresh (free) one, written like any
+	// other Page (through the write-ahead log, or with shadow paging
+	// to a fresh physical Page), so a crash can never bring back
+	// what was deleted
+	err := f.WritePage(f.NewPage(pid))
+	if err == ErrWritingPage {
 		return ErrDeletingPage
 	}
-	// update the Page in the
-	// slotted PageManager's Page cache
-	for i := range f.pageHeaders {
-		if f.pageHeaders[i].pageID == pid {
-			// reset this matching Page header
-			// in the Page cache to default values
-			f.pageHeaders[i].freeSpaceLower = pageHeaderSize
-			f.pageHeaders[i].freeSpaceUpper = pageSize
-			f.pageHeaders[i].slotCount = 0
-			f.pageHeaders[i].freeSlotCount = 0
+	return err
+}
+
+// Upgrade rewrites any pages in the PageManager that are still
+// using the legacy Page format (no format version and no checksum)
+// using the current Page format version. Legacy pages can still be
+// read without upgrading, but they are not verified on read.
+func (f *PageManager) Upgrade() error {
+	for _, h := range f.headers() {
+		// skip pages that are current, or that have never been written
+		if h.version != legacyPageFormatVersion || h.freeSpaceUpper == 0 {
+			continue
+		}
+		// reading the Page upgrades it in memory
+		p, err := f.ReadPage(h.pageID)
+		if err != nil {
+			return err
+		}
+		// and writing it persists the upgrade
+		err = f.WritePage(p)
+		if err != nil {
+			return err
 		}
 	}
-	// otherwise, we're good
 	return nil
 }
 
 // GetFreePageIDs returns a list of any
 // Page id's that are marked "free"
 func (f *PageManager) GetFreePageIDs() []uint32 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	// create new empty set of Page id's
 	var pids []uint32
-	// range the Page headers in
-	// the slotted files Page cache
-	for i := range f.pageHeaders {
-		if f.pageHeaders[i].PageIsFree() {
-			pids = append(pids, f.pageHeaders[i].pageID)
-		}
+	// ask the free-space map for each free Page
+	pid, ok := f.nextFreePageID(0)
+	for ok {
+		pids = append(pids, pid)
+		pid, ok = f.nextFreePageID(pid + 1)
 	}
 	// return any free Page id's found
 	return pids
 }
 
 func (f *PageManager) Range(start uint32, fn func(rid *RecordID) bool) {
-	for i := range f.pageHeaders {
+	hs := f.headers()
+	for i := range hs {
 
-		if f.pageHeaders[i].hasOverflow == 0 {
+		if hs[i].hasOverflow == 0 {
 			break
 		}
-		p, err := f.ReadPage(f.pageHeaders[i].pageID)
+		p, err := f.ReadPage(hs[i].pageID)
 		if err != nil {
 			panic("something happend")
 		}
@@ -317,14 +811,64 @@ func (f *PageManager) Range(start uint32, fn func(rid *RecordID) bool) {
 // PageCount returns the total number of pages
 // in the PageManager (including "free" pages)
 func (f *PageManager) PageCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	return len(f.pageHeaders)
 }
 
+// headers returns a copy of the cached Page headers
+func (f *PageManager) headers() []*pageHeader {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	hs := make([]*pageHeader, len(f.pageHeaders))
+	copy(hs, f.pageHeaders)
+	return hs
+}
+
 // Close closes the underlying
 // PageManager, after flushing any
-// buffers to disk.
+// buffers to disk. It must not be
+// called while the PageManager is
+// still in use.
 func (f *PageManager) Close() error {
-	err := f.fp.Close()
+	// there is nothing to write out, if opened read-only
+	if f.readOnly {
+		return f.closeFiles()
+	}
+	// update the Page count in the superblock
+	if f.sb != nil {
+		f.mu.Lock()
+		err := f.writeSuperblock()
+		f.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	// checkpoint, so we are not left
+	// with anything to replay
+	err := f.checkpoint()
+	if err != nil {
+		return err
+	}
+	return f.closeFiles()
+}
+
+// closeFiles closes the write-ahead log, the free-space map, the
+// storage backend and the underlying file (releasing the lock on it)
+func (f *PageManager) closeFiles() error {
+	err := f.wal.close()
+	if err != nil {
+		return err
+	}
+	err = f.fsm.close()
+	if err != nil {
+		return err
+	}
+	err = f.store.close()
+	if err != nil {
+		return err
+	}
+	err = f.fp.Close()
 	if err != nil {
 		return err
 	}
@@ -347,6 +891,16 @@ func Remove(path string) error {
 	if err != nil {
 		return err
 	}
+	// remove write-ahead log (if there is one)
+	err = os.Remove(path + walFileSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// remove free-space map (if there is one)
+	err = os.Remove(path + fsmFileSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
 	return nil
 }
 
@@ -356,7 +910,9 @@ func (f *PageManager) grow(sizeToGrow int64) {
 		panic(err)
 	}
 	sizeToGrow += fi.Size()
-	err = f.fp.Truncate(sizeToGrow)
+	// grow the file through the storage backend, so
+	// it can remap the file if it needs to
+	err = f.store.grow(sizeToGrow)
 	if err != nil {
 		panic(err)
 	}
@@ -2,6 +2,7 @@ package pager
 
 import (
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -518,7 +519,8 @@ func TestRemove(t *testing.T) {
 
 func Test_getPagePosition(t *testing.T) {
 	type args struct {
-		pid uint32
+		pid  uint32
+		size int
 	}
 	tests := []struct {
 		name string
@@ -529,9 +531,109 @@ func Test_getPagePosition(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := getPagePosition(tt.args.pid); got != tt.want {
+			if got := getPagePosition(tt.args.pid, tt.args.size); got != tt.want {
 				t.Errorf("getPagePosition() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+// freePage writes a Page and deletes it, leaving a single free Page
+func freePage(t *testing.T, f *PageManager) uint32 {
+	p := f.AllocatePage()
+	_, err := p.AddRecord([]byte("some record data"))
+	if err != nil {
+		t.Fatalf("[Page] add record: %s", err)
+	}
+	err = f.WritePage(p)
+	if err != nil {
+		t.Fatalf("[file] write page: %s", err)
+	}
+	err = f.DeletePage(p.PageID())
+	if err != nil {
+		t.Fatalf("[file] delete page: %s", err)
+	}
+	return p.PageID()
+}
+
+func TestPageManager_ReleasePage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "release.db")
+	f, err := OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	defer f.Close()
+	pid := freePage(t, f)
+	// a free Page that is dropped can be handed out again once released
+	p := f.GetFreeOrAllocate()
+	if p.PageID() != pid {
+		t.Fatalf("[file] expected free page %d, got %d", pid, p.PageID())
+	}
+	f.ReleasePage(p.PageID())
+	p = f.GetFreeOrAllocate()
+	if p.PageID() != pid {
+		t.Fatalf("[file] expected released page %d, got %d", pid, p.PageID())
+	}
+	f.ReleasePage(p.PageID())
+	// a free Page claimed by a record that failed to be added is released
+	_, err = f.AddRecord([]byte("tiny"))
+	if err != ErrMinRecordSize {
+		t.Fatalf("[file] expected %q, got %v", ErrMinRecordSize, err)
+	}
+	p = f.GetFreeOrAllocate()
+	if p.PageID() != pid {
+		t.Fatalf("[file] expected page %d after a failed add, got %d", pid, p.PageID())
+	}
+	f.ReleasePage(p.PageID())
+}
+
+func TestPageManager_WriteOutOfOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out-of-order.db")
+	f, err := OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	a, b := f.AllocatePage(), f.AllocatePage()
+	_, err = b.AddRecord([]byte("this-is-record-000002"))
+	if err != nil {
+		t.Fatalf("[Page] add record: %s", err)
+	}
+	// write the second Page first
+	err = f.WritePage(b)
+	if err != nil {
+		t.Fatalf("[file] write page: %s", err)
+	}
+	if f.PageCount() != 2 {
+		t.Fatalf("[file] expected 2 pages, got %d", f.PageCount())
+	}
+	// the first Page has not been written yet, so it should
+	// not be handed out to anyone else in the meantime
+	p := f.GetFreeOrAllocate()
+	if p.PageID() == a.PageID() {
+		t.Fatalf("[file] page %d handed out before it was written", a.PageID())
+	}
+	_, err = a.AddRecord([]byte("this-is-record-000001"))
+	if err != nil {
+		t.Fatalf("[Page] add record: %s", err)
+	}
+	err = f.WritePage(a)
+	if err != nil {
+		t.Fatalf("[file] write page: %s", err)
+	}
+	if f.PageCount() != 2 || f.freePages != 0 {
+		t.Fatalf("[file] expected 2 pages (none free), got %d (%d free)", f.PageCount(), f.freePages)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatalf("[file] close: %s", err)
+	}
+	// and it should all be there after reopening
+	f, err = OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	defer f.Close()
+	if f.PageCount() != 2 || f.freePages != 0 {
+		t.Fatalf("[file] expected 2 pages after reopen (none free), got %d (%d free)", f.PageCount(), f.freePages)
+	}
+}
@@ -0,0 +1,148 @@
+//go:build linux
+// +build linux
+
+package pager
+
+import (
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// mmapMinSize is the smallest mapping made of the file
+const mmapMinSize = 1 << 20 // 1 MB
+
+// mmapStore is a pageStore that reads and writes pages by copying them
+// in and out of a shared memory mapping of the underlying file. The
+// mapping is allowed to run past the end of the file (so it does not
+// have to be remapped every time the file grows), but nothing past
+// the end of the file is ever touched. Pages are copied in and out of
+// the mapping holding the lock (shared), and the mapping is only ever
+// remapped holding it exclusively.
+type mmapStore struct {
+	sync.RWMutex
+	fp   *os.File
+	data []byte // the mapping
+	size int64  // the size of the underlying file
+}
+
+// openMmapStore maps the file provided and returns a mmapStore for it
+func openMmapStore(fp *os.File) (pageStore, error) {
+	fi, err := fp.Stat()
+	if err != nil {
+		return nil, err
+	}
+	s := &mmapStore{
+		fp:   fp,
+		size: fi.Size(),
+	}
+	err = s.remap(s.size)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// remap maps the underlying file again, with room for at least size
+// bytes. The mapping size is doubled until it is large enough, so the
+// file can keep growing for a while before it has to be remapped.
+func (s *mmapStore) remap(size int64) error {
+	n := int64(mmapMinSize)
+	for n < size {
+		n *= 2
+	}
+	// unmap the old mapping first
+	if s.data != nil {
+		err := syscall.Munmap(s.data)
+		if err != nil {
+			return err
+		}
+		s.data = nil
+	}
+	data, err := syscall.Mmap(
+		int(s.fp.Fd()), 0, int(n), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED,
+	)
+	if err != nil {
+		return err
+	}
+	s.data = data
+	return nil
+}
+
+// ReadPage copies a Page out of the mapping at offset off
+func (s *mmapStore) ReadPage(p []byte, off int64) (int, error) {
+	s.RLock()
+	defer s.RUnlock()
+	if off < 0 || off >= s.size {
+		return 0, io.EOF
+	}
+	n := copy(p, s.data[off:s.size])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// WritePage copies a Page into the mapping at offset off, growing
+// the underlying file (and the mapping) first if it needs to
+func (s *mmapStore) WritePage(p []byte, off int64) (int, error) {
+	err := s.grow(off + int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	s.RLock()
+	defer s.RUnlock()
+	return copy(s.data[off:], p), nil
+}
+
+// grow makes sure the underlying file is at least size bytes, and
+// remaps it if it has grown past the end of the mapping
+func (s *mmapStore) grow(size int64) error {
+	s.Lock()
+	defer s.Unlock()
+	if size <= s.size {
+		return nil
+	}
+	err := s.fp.Truncate(size)
+	if err != nil {
+		return err
+	}
+	s.size = size
+	if size > int64(len(s.data)) {
+		return s.remap(size)
+	}
+	return nil
+}
+
+// flush writes any modified pages in the mapping back to the
+// underlying file (using msync), and then syncs the file
+func (s *mmapStore) flush() error {
+	s.RLock()
+	defer s.RUnlock()
+	if s.size > 0 {
+		_, _, errno := syscall.Syscall(
+			syscall.SYS_MSYNC,
+			uintptr(unsafe.Pointer(&s.data[0])),
+			uintptr(s.size),
+			syscall.MS_SYNC,
+		)
+		if errno != 0 {
+			return errno
+		}
+	}
+	return s.fp.Sync()
+}
+
+// close unmaps the underlying file
+func (s *mmapStore) close() error {
+	s.Lock()
+	defer s.Unlock()
+	if s.data == nil {
+		return nil
+	}
+	err := syscall.Munmap(s.data)
+	s.data = nil
+	return err
+}
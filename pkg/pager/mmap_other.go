@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package pager
+
+import (
+	"os"
+)
+
+// openMmapStore is only supported on linux
+func openMmapStore(fp *os.File) (pageStore, error) {
+	return nil, ErrMmapUnsupported
+}
@@ -0,0 +1,268 @@
+package pager
+
+import (
+	"sync"
+)
+
+// Readers that need a consistent view of the PageManager while writers
+// carry on can take a Snapshot. Every commit (a call to WritePage,
+// WritePages or DeletePage, including the ones made by a Tx) is given
+// the next commit timestamp, and a Snapshot sees the pages as they were
+// as of the last commit before it was taken.
+//
+// When a Page is overwritten while there are live snapshots that can see
+// it, the image of the Page that is being replaced is kept in memory as an
+// older version of the Page, which is valid for the snapshots taken since
+// it was written and before the commit that replaced it. A Snapshot reading
+// a Page uses the oldest version of it that was replaced after the Snapshot
+// was taken, or the Page on disk if there is none. Versions that no live
+// Snapshot can use are garbage collected when snapshots are released.
+// With shadow paging pages are never overwritten, so a Snapshot simply
+// keeps a copy of the Page table instead (see shadow.go).
+//
+// Writers take the next commit timestamp (keeping any versions that are
+// needed) holding the write latches for the pages they are about to write,
+// and keep holding them until the pages are on disk. Snapshot readers
+// latch the Page they are reading, so a Snapshot never sees a Page that
+// is half written (see latch.go).
+
+// versionStore holds the older versions of pages that live snapshots
+// may still need
+type versionStore struct {
+	sync.RWMutex
+	ts        uint64 // the timestamp of the last commit
+	snapshots map[*Snapshot]struct{}
+	versions  map[uint32][]pageVersion
+}
+
+// pageVersion is an older version of a Page, it is valid for any
+// snapshot taken at a timestamp from start up to (but not including)
+// end. The start is only a lower bound, as the commit that wrote the
+// image is only known if an earlier version of the Page was kept.
+type pageVersion struct {
+	data  []byte // nil if the Page did not exist yet
+	start uint64
+	end   uint64
+}
+
+// newVersionStore returns an empty versionStore
+func newVersionStore() *versionStore {
+	return &versionStore{
+		snapshots: make(map[*Snapshot]struct{}),
+		versions:  make(map[uint32][]pageVersion),
+	}
+}
+
+// oldest returns the timestamp of the oldest live snapshot (or the
+// timestamp of the last commit, if there are no live snapshots)
+func (vs *versionStore) oldest() uint64 {
+	oldest := vs.ts
+	for snap := range vs.snapshots {
+		if snap.ts < oldest {
+			oldest = snap.ts
+		}
+	}
+	return oldest
+}
+
+// needed reports if any live snapshot was taken at a timestamp
+// from start up to (but not including) end
+func (vs *versionStore) needed(start, end uint64) bool {
+	for snap := range vs.snapshots {
+		if start <= snap.ts && snap.ts < end {
+			return true
+		}
+	}
+	return false
+}
+
+// collect garbage collects the versions no live snapshot can use
+func (vs *versionStore) collect() {
+	if len(vs.snapshots) == 0 {
+		vs.versions = make(map[uint32][]pageVersion)
+		return
+	}
+	for pid, versions := range vs.versions {
+		kept := versions[:0]
+		for _, v := range versions {
+			if vs.needed(v.start, v.end) {
+				kept = append(kept, v)
+			}
+		}
+		if len(kept) == 0 {
+			delete(vs.versions, pid)
+			continue
+		}
+		vs.versions[pid] = kept
+	}
+}
+
+// version returns the version of the Page that a snapshot taken at the
+// timestamp provided should see, or false if it should use the Page on disk
+func (vs *versionStore) version(pid uint32, ts uint64) (pageVersion, bool) {
+	for _, v := range vs.versions[pid] {
+		if v.end > ts {
+			return v, true
+		}
+	}
+	return pageVersion{}, false
+}
+
+// commitVersions gives the next commit timestamp to a commit writing
+// the pages with the provided ids, keeping the current images of them
+// if a Snapshot needs them. It must be called holding the write latches
+// for the pages, before they are overwritten.
+func (f *PageManager) commitVersions(pids ...uint32) {
+	f.mvcc.Lock()
+	defer f.mvcc.Unlock()
+	f.saveVersions(pids...)
+	f.mvcc.ts++
+}
+
+// saveVersions keeps the current images of the pages with the provided
+// ids as older versions, if there are any live snapshots that can see
+// them. It must be called holding the version lock, just before the
+// pages are overwritten by the next commit.
+func (f *PageManager) saveVersions(pids ...uint32) {
+	vs := f.mvcc
+	if len(vs.snapshots) == 0 {
+		return
+	}
+	end := vs.ts + 1
+	for _, pid := range pids {
+		// the image on disk was written by the commit that
+		// replaced the last version kept (or at some point
+		// before now, if there is no version kept)
+		versions := vs.versions[pid]
+		var start uint64
+		if n := len(versions); n > 0 {
+			start = versions[n-1].end
+		}
+		// skip it if this commit already replaced it (it
+		// is in the same commit twice), or if no Snapshot
+		// can see it
+		if start == end || !vs.needed(start, end) {
+			continue
+		}
+		data := make([]byte, f.pageSize)
+		_, err := f.store.ReadPage(data, f.pagePosition(pid))
+		if err != nil {
+			data = nil
+		}
+		vs.versions[pid] = append(versions, pageVersion{data: data, start: start, end: end})
+	}
+}
+
+// Snapshot is a read-only, point-in-time view of a PageManager. Pages
+// written after the Snapshot was taken are not seen by it. A Snapshot
+// can be read from while writers carry on, but it must be released once
+// it is no longer needed, so the older versions of pages it is holding
+// on to can be freed.
+type Snapshot struct {
+	f         *PageManager
+	ts        uint64
+	pageCount int
+	table     []uint32 // the Page table (shadow paging only)
+	released  bool
+}
+
+// Snapshot returns a Snapshot of the pages as they are right now
+func (f *PageManager) Snapshot() *Snapshot {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.mvcc.Lock()
+	defer f.mvcc.Unlock()
+	snap := &Snapshot{
+		f:         f,
+		ts:        f.mvcc.ts,
+		pageCount: len(f.pageHeaders),
+	}
+	if f.shadow != nil {
+		snap.table = make([]uint32, len(f.shadow.table))
+		copy(snap.table, f.shadow.table)
+	}
+	f.mvcc.snapshots[snap] = struct{}{}
+	return snap
+}
+
+// PageCount returns the number of pages in the Snapshot
+func (snap *Snapshot) PageCount() int {
+	return snap.pageCount
+}
+
+// ReadPage reads the Page for the provided pageID, as it
+// was when the Snapshot was taken
+func (snap *Snapshot) ReadPage(pid uint32) (*Page, error) {
+	f := snap.f
+	// latch the Page for reading, so it is not replaced while
+	// we are reading it (with shadow paging, pages are never
+	// replaced, so there is no need)
+	if f.shadow == nil {
+		f.latches.rlock(pid)
+		defer f.latches.runlock(pid)
+	}
+	f.mvcc.RLock()
+	defer f.mvcc.RUnlock()
+	if snap.released {
+		return nil, ErrSnapshotReleased
+	}
+	var p *Page
+	var err error
+	switch {
+	case f.shadow != nil:
+		// read the Page wherever the Page table said it was
+		if int(pid) >= len(snap.table) || snap.table[pid] == shadowUnmapped {
+			return nil, ErrPageNotFound
+		}
+		p, err = readPageAt(f.store, f.physicalPosition(snap.table[pid]), f.pageSize, f.legacy())
+	default:
+		// use an older version of the Page, if it has been
+		// replaced since the Snapshot was taken
+		v, ok := f.mvcc.version(pid, snap.ts)
+		if !ok {
+			p, err = readPageAt(f.store, f.pagePosition(pid), f.pageSize, f.legacy())
+			break
+		}
+		if v.data == nil {
+			return nil, ErrPageNotFound
+		}
+		p = &Page{data: make([]byte, len(v.data))}
+		copy(p.data, v.data)
+		err = decodePage(p, f.legacy())
+	}
+	if err != nil {
+		// Page failed verification
+		if err == ErrPageChecksumMismatch {
+			return nil, err
+		}
+		// Page not found
+		return nil, ErrPageNotFound
+	}
+	return p, nil
+}
+
+// GetRecord returns the record data for the RecordID provided, as it
+// was when the Snapshot was taken (see PageManager.GetRecord)
+func (snap *Snapshot) GetRecord(rid *RecordID) ([]byte, error) {
+	return getRecord(snap.ReadPage, rid)
+}
+
+// Release releases the Snapshot, so the older versions of pages that
+// only it can see can be freed
+func (snap *Snapshot) Release() {
+	f := snap.f
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.mvcc.Lock()
+	defer f.mvcc.Unlock()
+	if snap.released {
+		return
+	}
+	snap.released = true
+	snap.table = nil
+	delete(f.mvcc.snapshots, snap)
+	f.mvcc.collect()
+	if f.shadow != nil {
+		f.shadow.reclaim(f.mvcc.oldest())
+	}
+}
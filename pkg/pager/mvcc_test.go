@@ -0,0 +1,98 @@
+package pager
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestSnapshot_ConcurrentReaders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mvcc.db")
+	f, err := OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	defer f.Close()
+	const pages, versions = 8, 50
+	// write every page with the same version in a single commit
+	writeVersion := func(n int) error {
+		tx := f.Begin()
+		for pid := uint32(0); pid < pages; pid++ {
+			p := f.NewPage(pid)
+			_, err := p.AddRecord([]byte(fmt.Sprintf("version-%.6d", n)))
+			if err != nil {
+				return err
+			}
+			err = tx.WritePage(p)
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Commit()
+	}
+	err = writeVersion(0)
+	if err != nil {
+		t.Fatalf("[tx] write version: %s", err)
+	}
+	// a snapshot taken now should keep seeing version 0
+	first := f.Snapshot()
+	// keep writing new versions, while readers check that
+	// every snapshot sees the same version of every page
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for n := 1; n <= versions; n++ {
+			if err := writeVersion(n); err != nil {
+				t.Errorf("[tx] write version: %s", err)
+				return
+			}
+		}
+	}()
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < versions; i++ {
+				snap := f.Snapshot()
+				var want string
+				for pid := uint32(0); pid < pages; pid++ {
+					rec, err := snap.GetRecord(&RecordID{PageID: pid, SlotID: 0})
+					if err != nil {
+						t.Errorf("[snapshot] get record: %s", err)
+						break
+					}
+					if pid == 0 {
+						want = string(rec)
+					}
+					if string(rec) != want {
+						t.Errorf("[snapshot] page %d: got %q, want %q", pid, rec, want)
+					}
+				}
+				snap.Release()
+			}
+		}()
+	}
+	wg.Wait()
+	for pid := uint32(0); pid < pages; pid++ {
+		rec, err := first.GetRecord(&RecordID{PageID: pid, SlotID: 0})
+		if err != nil || string(rec) != "version-000000" {
+			t.Errorf("[snapshot] page %d: got %q, %v", pid, rec, err)
+		}
+	}
+	// only the first version of each page should have been kept
+	for pid, vs := range f.mvcc.versions {
+		if len(vs) != 1 {
+			t.Errorf("[file] expected 1 version of page %d, got %d", pid, len(vs))
+		}
+	}
+	// and they should all be collected once the snapshot is released
+	first.Release()
+	if len(f.mvcc.versions) != 0 {
+		t.Errorf("[file] expected no versions left, got %d", len(f.mvcc.versions))
+	}
+	if _, err = first.ReadPage(0); err != ErrSnapshotReleased {
+		t.Errorf("[snapshot] expected %v, got %v", ErrSnapshotReleased, err)
+	}
+}
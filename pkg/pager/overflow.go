@@ -0,0 +1,356 @@
+package pager
+
+import (
+	"encoding/binary"
+)
+
+// Records written through the PageManager that are larger than the
+// max record size (see PageManager.MaxRecordSize) spill over into a
+// chain of overflow pages. The record is split into chunks, one chunk
+// per Page, and the pages are linked together (see Page.Link) in order.
+// The first chunk is stored in the head Page in a slot marked with
+// itemStatusOverflow, and is prefixed with the total length of the
+// record:
+//
+//	head:  [total uint32][chunk 0] -> [chunk 1] -> ... -> [chunk n]
+//
+// The RecordID returned refers to the slot in the head Page, so large
+// records are addressed the same way as records that fit in a Page.
+
+// overflowPrefixSize is the size of the record length
+// prefix stored in the head of an overflow chain
+const overflowPrefixSize = 4
+
+// overflowChunkSizes returns the max chunk size that can be stored in
+// the head Page of an overflow chain, along with the max chunk size that
+// can be stored in any of the other pages in an overflow chain
+func (f *PageManager) overflowChunkSizes() (int, int) {
+	max := f.MaxRecordSize()
+	return max - overflowPrefixSize, max
+}
+
+// overflowPageCount returns the number of pages it takes to store
+// a record of the provided size in an overflow chain
+func (f *PageManager) overflowPageCount(recordSize int) int {
+	headChunkSize, chunkSize := f.overflowChunkSizes()
+	rest := recordSize - headChunkSize
+	return 1 + (rest+chunkSize-1)/chunkSize
+}
+
+// allocatePages returns n fresh pages, reusing any free pages
+// first. None of the pages are persisted until they are written.
+func (f *PageManager) allocatePages(n int) []*Page {
+	ps := make([]*Page, 0, n)
+	// reuse free pages first, they have nothing in
+	// them, so we can start with a fresh Page
+	var from uint32
+	for len(ps) < n {
+		pid, ok := f.claimFreePageID(from)
+		if !ok {
+			break
+		}
+		ps = append(ps, f.NewPage(pid))
+		from = pid + 1
+	}
+	// and allocate the rest
+	for len(ps) < n {
+		ps = append(ps, f.AllocatePage())
+	}
+	return ps
+}
+
+// findPageWithRoom returns a Page that has enough room left in it to
+// fit a record of the provided size. It uses the free-space map to find
+// a record Page with room (or a free Page) before allocating a new one.
+func (f *PageManager) findPageWithRoom(recordSize int) (*Page, error) {
+	var from uint32
+	for {
+		pid, free, ok := f.searchPageWithRoom(recordSize, from)
+		if !ok {
+			break
+		}
+		from = pid + 1
+		// free pages have nothing in them, so we
+		// can start with a fresh Page
+		if free {
+			return f.NewPage(pid), nil
+		}
+		p, err := f.ReadPage(pid)
+		if err != nil {
+			return nil, err
+		}
+		// the free-space map is only a hint, so make
+		// sure the Page really does have room (once
+		// it has been compacted, if it needs to be)
+		if p.header.reserved&pageFlagRecords != 0 &&
+			p.header.hasOverflow == 0 &&
+			p.hasRoomCompacted(uint32(recordSize)) {
+			return p, nil
+		}
+		f.mu.Lock()
+		f.fsm.set(pid, f.fsm.category(p.header))
+		f.mu.Unlock()
+	}
+	// otherwise, allocate a new Page
+	return f.AllocatePage(), nil
+}
+
+// searchPageWithRoom asks the free-space map for the first Page (starting
+// at the Page id provided) that may have room for a record of the provided
+// size. Free pages are claimed (see claimFreePageID), and reported as free.
+func (f *PageManager) searchPageWithRoom(recordSize int, from uint32) (uint32, bool, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.fsm.categoryFor(recordSize)
+	for {
+		pid, ok := f.fsm.search(c, from)
+		if !ok {
+			return 0, false, false
+		}
+		from = pid + 1
+		if f.fsm.get(pid) != fsmPageFree {
+			return pid, false, true
+		}
+		// skip free pages that have been handed out already
+		if _, claimed := f.claimed[pid]; claimed {
+			continue
+		}
+		if int(pid) < len(f.pageHeaders) && f.pageHeaders[pid].PageIsFree() {
+			f.claimed[pid] = struct{}{}
+			return pid, true, true
+		}
+		f.fixPageCategory(pid)
+	}
+}
+
+// AddRecord adds a new record to the PageManager and returns the
+// RecordID for it. Records that fit in a Page are packed into any
+// record Page with enough room (found using the free-space map).
+// Records larger than the max record size are split up and spill over
+// into a chain of overflow pages. All the pages for the record are
+// written in a single commit.
+func (f *PageManager) AddRecord(r []byte) (*RecordID, error) {
+	f.records.Lock()
+	defer f.records.Unlock()
+	return f.addRecord(r)
+}
+
+// addRecord adds a new record to the PageManager, it must be
+// called holding the record lock
+func (f *PageManager) addRecord(r []byte) (*RecordID, error) {
+	// if the record fits in a Page, no need to chain
+	if len(r) <= f.MaxRecordSize() {
+		p, err := f.findPageWithRoom(len(r))
+		if err != nil {
+			return nil, err
+		}
+		// mark the Page as one that can be shared
+		p.header.reserved |= pageFlagRecords
+		rid, err := p.AddRecord(r)
+		if err == nil {
+			err = f.WritePage(p)
+		}
+		if err != nil {
+			// the Page was not written, so if it is a free
+			// Page it can be handed out again
+			f.unclaim(p.PageID())
+			return nil, err
+		}
+		return rid, nil
+	}
+	// otherwise, get enough pages to hold the whole record
+	// and link them together
+	ps := f.allocatePages(f.overflowPageCount(len(r)))
+	rid, err := f.addOverflowRecord(ps, r)
+	if err != nil {
+		// the pages were not written, so any free
+		// pages can be handed out again
+		pids := make([]uint32, 0, len(ps))
+		for _, p := range ps {
+			pids = append(pids, p.PageID())
+		}
+		f.unclaim(pids...)
+		return nil, err
+	}
+	return rid, nil
+}
+
+// addOverflowRecord splits the record up into the pages provided, chains
+// them together and writes them
+func (f *PageManager) addOverflowRecord(ps []*Page, r []byte) (*RecordID, error) {
+	for i := 1; i < len(ps); i++ {
+		ps[i-1].Link(ps[i])
+	}
+	// add the head chunk, prefixed with the total record length
+	headChunkSize, chunkSize := f.overflowChunkSizes()
+	head := make([]byte, overflowPrefixSize+headChunkSize)
+	binary.LittleEndian.PutUint32(head[0:overflowPrefixSize], uint32(len(r)))
+	n := copy(head[overflowPrefixSize:], r)
+	rid, err := ps[0].AddRecord(head)
+	if err != nil {
+		return nil, err
+	}
+	ps[0].slotByID(rid.SlotID).itemStatus = itemStatusOverflow
+	// add the rest of the chunks, one per Page
+	for _, p := range ps[1:] {
+		chunk := r[n:]
+		if len(chunk) > chunkSize {
+			chunk = chunk[:chunkSize]
+		}
+		n += len(chunk)
+		// the last chunk may be smaller than the min record
+		// size, so we pad it out (the total record length
+		// tells us where the record actually ends)
+		if len(chunk) < MinRecordSize {
+			pad := make([]byte, MinRecordSize)
+			copy(pad, chunk)
+			chunk = pad
+		}
+		_, err = p.AddRecord(chunk)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// and write the whole chain
+	err = f.WritePages(ps)
+	if err != nil {
+		return nil, err
+	}
+	return rid, nil
+}
+
+// GetRecord returns the record data for the RecordID provided. If the
+// record has spilled over into a chain of overflow pages, it will be
+// reassembled.
+func (f *PageManager) GetRecord(rid *RecordID) ([]byte, error) {
+	return getRecord(f.ReadPage, rid)
+}
+
+// getRecord returns the record data for the RecordID provided, reading
+// pages using the provided read function
+func getRecord(read func(uint32) (*Page, error), rid *RecordID) ([]byte, error) {
+	// read the Page the record lives in
+	p, err := read(rid.PageID)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := p.GetRecord(rid)
+	if err != nil {
+		return nil, err
+	}
+	switch p.slotByID(rid.SlotID).itemStatus {
+	case itemStatusForward:
+		// the record has been moved, so follow the forwarding
+		// pointer to where it lives now (see forward.go)
+		return getRecord(read, decodeForward(rec))
+	case itemStatusOverflow:
+		// it's the head of an overflow chain (see below)
+	default:
+		// it's not the head of an overflow chain, we are done
+		return rec, nil
+	}
+	// otherwise, reassemble the record by following the chain
+	_, data, err := readOverflowChain(read, p, rec)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// readOverflowChain follows an overflow chain starting at the head Page
+// (and the head record) provided, reading pages using the provided read
+// function. It returns the Page ids in the chain along with the
+// reassembled record data.
+func readOverflowChain(
+	read func(uint32) (*Page, error), head *Page, rec []byte,
+) ([]uint32, []byte, error) {
+	if len(rec) < overflowPrefixSize {
+		return nil, nil, ErrPageIsNotOverflow
+	}
+	// get the total record length
+	size := int(binary.LittleEndian.Uint32(rec[0:overflowPrefixSize]))
+	data := make([]byte, 0, size)
+	data = append(data, rec[overflowPrefixSize:]...)
+	pids := []uint32{head.PageID()}
+	// follow the chain until we have the whole record
+	for p := head; len(data) < size; {
+		if p.header.hasOverflow == 0 {
+			return nil, nil, ErrPageIsNotOverflow
+		}
+		next, err := read(p.NextID())
+		if err != nil {
+			return nil, nil, err
+		}
+		chunk, err := next.GetRecord(&RecordID{PageID: next.PageID(), SlotID: 0})
+		if err != nil {
+			return nil, nil, err
+		}
+		data = append(data, chunk...)
+		pids = append(pids, next.PageID())
+		p = next
+	}
+	// trim any padding off of the last chunk
+	return pids, data[:size], nil
+}
+
+// DelRecord removes the record for the RecordID provided. If the record
+// has spilled over into a chain of overflow pages, every Page in the
+// chain is freed so that it can be reused. If the record has been moved
+// to another Page, it is removed from there as well.
+func (f *PageManager) DelRecord(rid *RecordID) error {
+	f.records.Lock()
+	defer f.records.Unlock()
+	ps, err := f.delRecordPages(rid)
+	if err != nil {
+		return err
+	}
+	// write all the pages in a single commit, so we
+	// can never be left with half a record
+	return f.WritePages(ps)
+}
+
+// delRecordPages removes the record for the RecordID provided from the
+// pages it lives in, and returns the pages that need to be written
+func (f *PageManager) delRecordPages(rid *RecordID) ([]*Page, error) {
+	// read the Page the record lives in
+	p, err := f.ReadPage(rid.PageID)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := p.GetRecord(rid)
+	if err != nil {
+		return nil, err
+	}
+	switch p.slotByID(rid.SlotID).itemStatus {
+	case itemStatusOverflow:
+		// find all the pages in the chain, and free them
+		// all by writing fresh pages over the top of them
+		pids, _, err := readOverflowChain(f.ReadPage, p, rec)
+		if err != nil {
+			return nil, err
+		}
+		ps := make([]*Page, 0, len(pids))
+		for _, pid := range pids {
+			ps = append(ps, f.NewPage(pid))
+		}
+		return ps, nil
+	case itemStatusForward:
+		// remove the record where it lives now, along
+		// with the forwarding pointer to it
+		ps, err := f.delRecordPages(decodeForward(rec))
+		if err != nil {
+			return nil, err
+		}
+		err = p.DelRecord(rid)
+		if err != nil {
+			return nil, err
+		}
+		return append(ps, p), nil
+	}
+	// otherwise, just remove the record from the Page
+	err = p.DelRecord(rid)
+	if err != nil {
+		return nil, err
+	}
+	return []*Page{p}, nil
+}
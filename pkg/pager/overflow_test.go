@@ -0,0 +1,101 @@
+package pager
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func makeRecord(size int) []byte {
+	b := make([]byte, size)
+	for i := range b {
+		b[i] = byte(i % 251)
+	}
+	return b
+}
+
+func TestPageManager_OverflowRecords(t *testing.T) {
+	for _, size := range []int{MinPageSize, DefaultPageSize, widePageSize} {
+		t.Run(fmt.Sprintf("%dKB", size>>10), func(t *testing.T) {
+			testOverflowRecords(t, size)
+		})
+	}
+}
+
+func testOverflowRecords(t *testing.T, pageSize int) {
+	path := filepath.Join(t.TempDir(), "overflow.db")
+	f, err := OpenPageManagerWithPageSize(path, pageSize)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	headChunkSize, chunkSize := f.overflowChunkSizes()
+	sizes := []int{
+		32,
+		f.MaxRecordSize(),
+		f.MaxRecordSize() + 1,
+		headChunkSize + chunkSize + 3,
+		4*f.MaxRecordSize() + 1234,
+	}
+	var rids []*RecordID
+	for _, size := range sizes {
+		rid, err := f.AddRecord(makeRecord(size))
+		if err != nil {
+			t.Fatalf("[file] add record (size=%d): %s", size, err)
+		}
+		rids = append(rids, rid)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatalf("[file] close: %s", err)
+	}
+	// reopening with a different page size should fail
+	_, err = OpenPageManagerWithPageSize(path, 2*pageSize)
+	if err != ErrPageSizeMismatch {
+		t.Fatalf("[file] expected %v, got %v", ErrPageSizeMismatch, err)
+	}
+	// reopen, and read the records back
+	f, err = OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	defer f.Close()
+	if f.PageSize() != pageSize {
+		t.Fatalf("[file] expected page size %d, got %d", pageSize, f.PageSize())
+	}
+	for i, rid := range rids {
+		rec, err := f.GetRecord(rid)
+		if err != nil {
+			t.Fatalf("[file] get record (size=%d): %s", sizes[i], err)
+		}
+		if !bytes.Equal(rec, makeRecord(sizes[i])) {
+			t.Errorf("[file] record (size=%d) did not match, got len=%d", sizes[i], len(rec))
+		}
+	}
+	// delete the largest record, the whole chain should be freed
+	last := len(rids) - 1
+	pages := f.PageCount()
+	err = f.DelRecord(rids[last])
+	if err != nil {
+		t.Fatalf("[file] del record: %s", err)
+	}
+	if got, want := len(f.GetFreePageIDs()), f.overflowPageCount(sizes[last]); got != want {
+		t.Errorf("[file] expected %d free pages, got %d", want, got)
+	}
+	_, err = f.GetRecord(rids[last])
+	if err == nil {
+		t.Errorf("[file] expected an error getting a deleted record")
+	}
+	// and adding it back again should reuse the freed pages
+	rid, err := f.AddRecord(makeRecord(sizes[last]))
+	if err != nil {
+		t.Fatalf("[file] add record: %s", err)
+	}
+	if f.PageCount() != pages {
+		t.Errorf("[file] expected %d pages, got %d", pages, f.PageCount())
+	}
+	rec, err := f.GetRecord(rid)
+	if err != nil || !bytes.Equal(rec, makeRecord(sizes[last])) {
+		t.Errorf("[file] record did not match after reuse: %v", err)
+	}
+}
@@ -21,13 +21,13 @@ type RecordID struct {
 type pageSlot struct {
 	itemID     uint16
 	itemStatus uint16
-	itemOffset uint16
-	itemLength uint16
+	itemOffset uint32
+	itemLength uint32
 }
 
 // itemBounds returns the beginning and ending offset
 // positions for the location of this item within the Page
-func (s *pageSlot) itemBounds() (uint16, uint16) {
+func (s *pageSlot) itemBounds() (uint32, uint32) {
 	return s.itemOffset, s.itemOffset + s.itemLength
 }
 
@@ -71,17 +71,19 @@ type pageHeader struct {
 	pageID         uint32
 	nextPageID     uint32
 	prevPageID     uint32
-	freeSpaceLower uint16
-	freeSpaceUpper uint16
+	freeSpaceLower uint32
+	freeSpaceUpper uint32
 	slotCount      uint16
 	freeSlotCount  uint16
 	hasOverflow    uint16
-	reserved       uint16
+	version        uint16
+	checksum       uint32
+	reserved       uint32
 }
 
 // FreeSpace returns the total (contiguous) free
 // space in bytes that is left in this Page
-func (h *pageHeader) FreeSpace() uint16 {
+func (h *pageHeader) FreeSpace() uint32 {
 	return h.freeSpaceUpper - h.freeSpaceLower // - (pageSlotSize * 1 * h.slotCount)
 }
 
@@ -95,28 +97,45 @@ func (h *pageHeader) PageIsFree() bool {
 // structure that may contain
 // one or more data records
 type Page struct {
-	header *pageHeader
-	slots  []*pageSlot
-	data   []byte
+	header  *pageHeader
+	slots   []*pageSlot
+	data    []byte
+	borrows borrowState
 }
 
 // NewPage is a new Page constructor
 // that creates and returns a new *Page
+// using the default Page size
 func NewPage(pid uint32) *Page {
+	return NewPageSize(pid, DefaultPageSize)
+}
+
+// NewPageSize is a new Page constructor that creates
+// and returns a new *Page of the provided size. The
+// size must be a power of two between the MinPageSize
+// and the MaxPageSize.
+func NewPageSize(pid uint32, size int) *Page {
+	if !validPageSize(size) {
+		panic(ErrUnsupportedPageSize)
+	}
+	version := pageFormatFor(size)
+	hdr, _ := pageLayout(version)
 	return &Page{
 		header: &pageHeader{
 			pageID:         pid,
 			nextPageID:     0,
 			prevPageID:     0,
-			freeSpaceLower: pageHeaderSize,
-			freeSpaceUpper: pageSize,
+			freeSpaceLower: uint32(hdr),
+			freeSpaceUpper: uint32(size),
 			slotCount:      0,
 			freeSlotCount:  0,
 			hasOverflow:    0,
+			version:        version,
+			checksum:       0,
 			reserved:       0,
 		},
 		slots: make([]*pageSlot, 0),
-		data:  make([]byte, pageSize),
+		data:  make([]byte, size),
 	}
 }
 
@@ -145,6 +164,18 @@ func (p *Page) Link(next *Page) *Page {
 	return p
 }
 
+// Size returns the size of the Page in bytes
+func (p *Page) Size() int {
+	return len(p.data)
+}
+
+// slotSize returns the size of a single Page slot,
+// which depends on the format version of the Page
+func (p *Page) slotSize() uint32 {
+	_, slot := pageLayout(p.header.version)
+	return uint32(slot)
+}
+
 // PageID returns the current pageID
 func (p *Page) PageID() uint32 {
 	return p.header.pageID
@@ -164,11 +195,11 @@ func (p *Page) NextID() uint32 {
 // but, it also checks if the recordSize is outside
 // the bounds of the minimum or maximum record size
 // and returns an applicable error if so
-func (p *Page) CheckRecord(recordSize uint16) error {
+func (p *Page) CheckRecord(recordSize uint32) error {
 	if recordSize < MinRecordSize {
 		return ErrMinRecordSize
 	}
-	if recordSize > MaxRecordSize {
+	if recordSize > uint32(maxRecordSizeFor(len(p.data))) {
 		return ErrMaxRecordSize
 	}
 	if !p.hasRoom(recordSize) {
@@ -180,15 +211,22 @@ func (p *Page) CheckRecord(recordSize uint16) error {
 // hasRoom does a simple check to see if there is enough
 // room left in the Page to accommodate a recordSized size
 // data record
-func (p *Page) hasRoom(recordSize uint16) bool {
-	return recordSize < p.header.FreeSpace()
+func (p *Page) hasRoom(recordSize uint32) bool {
+	return recordSize+p.slotSize() <= p.header.FreeSpace()
+}
+
+// hasRoomCompacted checks to see if there would be enough
+// room left in the Page to accommodate a recordSized size
+// data record once the Page has been compacted
+func (p *Page) hasRoomCompacted(recordSize uint32) bool {
+	return recordSize+p.slotSize() <= p.compactedFreeSpace()
 }
 
 // getAvailableSlot returns a free Page slot if there is
 // one already existing that can be used, otherwise it
 // adds a new pageSlot. It returns a *pageSlot to use
 // for inserting a new record.
-func (p *Page) getAvailableSlot(recordSize uint16) *pageSlot {
+func (p *Page) getAvailableSlot(recordSize uint32) *pageSlot {
 	// first check the Page header to see if
 	// the freeSlotCount is reporting any
 	if p.header.freeSlotCount > 0 {
@@ -215,7 +253,7 @@ func (p *Page) getAvailableSlot(recordSize uint16) *pageSlot {
 // attempts to use the same record offset (if it will fit) otherwise, it
 // will find another location in the Page and update the header accordingly
 func (p *Page) useFreePageSlotRecord(
-	slot *pageSlot, recordSize uint16,
+	slot *pageSlot, recordSize uint32,
 ) *pageSlot {
 	// no need to increment the slotCount however
 	// we do need to decrement the freeSlotCount
@@ -254,12 +292,12 @@ func (p *Page) useFreePageSlotRecord(
 // the slotCount, growing the freeSpaceLower bound and
 // shrinking the freeSpaceUpper bound. addNewPageSlot returns
 // a pointer to the newly added pageSlot.
-func (p *Page) addNewPageSlotRecord(recordSize uint16) *pageSlot {
+func (p *Page) addNewPageSlotRecord(recordSize uint32) *pageSlot {
 	// increment the slot count
 	p.header.slotCount++
 	// raise the free space lower bound
 	// because we are adding a new slot
-	p.header.freeSpaceLower += pageSlotSize
+	p.header.freeSpaceLower += p.slotSize()
 	// lower the free space upper bound
 	// because we are adding record data
 	p.header.freeSpaceUpper -= recordSize
@@ -296,10 +334,16 @@ func (p *Page) SortRecords() {
 // data that they point to.
 func (p *Page) AddRecord(r []byte) (*RecordID, error) {
 	// get record size for check
-	recordSize := uint16(len(r))
+	recordSize := uint32(len(r))
 	// run the necessary checks on the record
 	// to make sure we are good to go
 	err := p.CheckRecord(recordSize)
+	// if there is not enough contiguous room left, but there
+	// would be once the Page is compacted, compact and retry
+	if err == ErrNoMoreRoomInPage && p.hasRoomCompacted(recordSize) {
+		p.Compact()
+		err = p.CheckRecord(recordSize)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -308,7 +352,9 @@ func (p *Page) AddRecord(r []byte) (*RecordID, error) {
 	s := p.getAvailableSlot(recordSize)
 	// get the new record offsets
 	beg, end := s.itemBounds()
-	// copy the record to the Page
+	// copy the record to the Page (any borrowed
+	// views of it are no longer valid)
+	p.data = p.borrows.release(p.data)
 	copy(p.data[beg:end], r)
 	// before we return (this does not affect
 	// the slotID) we should sort the slot
@@ -323,10 +369,27 @@ func (p *Page) AddRecord(r []byte) (*RecordID, error) {
 	}, nil
 }
 
+// slotByID returns the Page slot for the provided slot id. The slots
+// are kept sorted by record prefix, so a slot is not necessarily found
+// at the index matching its id.
+func (p *Page) slotByID(id uint16) *pageSlot {
+	// check the index matching the id first
+	if int(id) < len(p.slots) && p.slots[id].itemID == id {
+		return p.slots[id]
+	}
+	// otherwise, search for it
+	for _, s := range p.slots {
+		if s.itemID == id {
+			return s
+		}
+	}
+	return nil
+}
+
 // recordIDIsValid reports whether the
 // provided *RecordID is valid or invalid
 func (p *Page) recordIDIsValid(rid *RecordID) bool {
-	return rid.PageID == p.header.pageID && int(rid.SlotID) < len(p.slots)
+	return rid.PageID == p.header.pageID && p.slotByID(rid.SlotID) != nil
 }
 
 // GetRecord attempts to return the record data
@@ -334,6 +397,28 @@ func (p *Page) recordIDIsValid(rid *RecordID) bool {
 // provided *RecordID. If the record cannot be
 // located, nil data and an error will be returned
 func (p *Page) GetRecord(rid *RecordID) ([]byte, error) {
+	// borrow the record data first
+	rec, err := p.BorrowRecord(rid)
+	if err != nil {
+		return nil, err
+	}
+	// create a new buffer to copy the
+	// record data into (so we are not
+	// returning a pointer to the base
+	// data, which would be unsafe)
+	data := make([]byte, len(rec))
+	copy(data, rec)
+	// return the record data along
+	// with a nil error
+	return data, nil
+}
+
+// BorrowRecord is like GetRecord, but it returns a view of the
+// record data pointing directly into the Page instead of a copy, so
+// it does not allocate. The view is only valid until the Page is next
+// modified (or reused), and it must never be written to. Building with
+// the pagerdebug tag detects views being used after they went stale.
+func (p *Page) BorrowRecord(rid *RecordID) ([]byte, error) {
 	// check to make sure the RecordID
 	// is not an invalid record id
 	if !p.recordIDIsValid(rid) {
@@ -341,7 +426,7 @@ func (p *Page) GetRecord(rid *RecordID) ([]byte, error) {
 	}
 	// locate the proper slot in the
 	// Page using the supplied *RecordID
-	slot := p.slots[rid.SlotID]
+	slot := p.slotByID(rid.SlotID)
 	// check the item status in the found slot
 	// to ensure it has not already been marked
 	// as a free slot (aka, can still be used)
@@ -351,20 +436,12 @@ func (p *Page) GetRecord(rid *RecordID) ([]byte, error) {
 		// or removed
 		return nil, ErrRecordHasBeenMarkedFree
 	}
-	// create a new buffer to copy the
-	// record data into (so we are not
-	// returning a pointer to the base
-	// data, which would be unsafe)
-	data := make([]byte, slot.itemLength)
-	// get the record offsets for
-	// an easier time copying
+	// get the record offsets, and cap the
+	// view so appending to it can never
+	// write over the rest of the Page
 	beg, end := slot.itemBounds()
-	// copy the record data into the
-	// newly created buffer, and return
-	copy(data, p.data[beg:end])
-	// return the record data along
-	// with a nil error
-	return data, nil
+	p.borrows.lend()
+	return p.data[beg:end:end], nil
 }
 
 // DelRecord removes a record from a Page. It will
@@ -377,7 +454,7 @@ func (p *Page) DelRecord(rid *RecordID) error {
 	}
 	// locate the proper slot in the
 	// Page using the supplied *RecordID
-	slot := p.slots[rid.SlotID]
+	slot := p.slotByID(rid.SlotID)
 	// check the item status in the found slot
 	// to ensure it has not already been marked
 	// as a free slot (aka, can still be used)
@@ -391,6 +468,9 @@ func (p *Page) DelRecord(rid *RecordID) error {
 	// otherwise, we must now mark the found
 	// slot as a free item which is now the
 	// in pool to be re-used at a later date.
+	// Any borrowed views of the Page are no
+	// longer valid.
+	p.data = p.borrows.release(p.data)
 	slot.itemStatus = itemStatusFree
 	// next, we should overwrite the item
 	// record with zero's to minimize the
@@ -411,6 +491,153 @@ func (p *Page) DelRecord(rid *RecordID) error {
 	return nil
 }
 
+// UpdateRecord replaces the data of a record in the Page, keeping
+// the same RecordID. The record is rewritten in place if the new data
+// fits in the space the record already has, otherwise it is moved to
+// the free space in the Page (compacting the Page first, if it needs
+// to be). If there is not enough room left in the Page for the record
+// ErrNoMoreRoomInPage is returned, and the Page is left untouched.
+func (p *Page) UpdateRecord(rid *RecordID, r []byte) error {
+	// check to make sure the RecordID
+	// is not an invalid record id
+	if !p.recordIDIsValid(rid) {
+		return ErrInvalidRecordID
+	}
+	// locate the proper slot in the
+	// Page using the supplied *RecordID
+	slot := p.slotByID(rid.SlotID)
+	if slot.itemStatus == itemStatusFree {
+		return ErrRecordHasBeenMarkedFree
+	}
+	// get record size for check
+	recordSize := uint32(len(r))
+	if recordSize < MinRecordSize {
+		return ErrMinRecordSize
+	}
+	if recordSize > uint32(maxRecordSizeFor(len(p.data))) {
+		return ErrMaxRecordSize
+	}
+	beg, end := slot.itemBounds()
+	// any borrowed views of the Page are no longer valid
+	p.data = p.borrows.release(p.data)
+	switch {
+	case recordSize <= slot.itemLength:
+		// it fits where it is, so we just zero out
+		// the tail that is no longer being used
+		for i := beg + recordSize; i < end; i++ {
+			p.data[i] = 0
+		}
+	case recordSize <= p.header.FreeSpace():
+		// it fits in the free space, so zero out the
+		// old record and take the room from there
+		copy(p.data[beg:end], make([]byte, slot.itemLength))
+		p.header.freeSpaceUpper -= recordSize
+		slot.itemOffset = p.header.freeSpaceUpper
+	case recordSize <= p.compactedFreeSpace()+slot.itemLength:
+		// it only fits if the old record space is given
+		// up and the Page is compacted, the record keeps
+		// its slot (with no room) while we compact
+		copy(p.data[beg:end], make([]byte, slot.itemLength))
+		slot.itemLength = 0
+		p.Compact()
+		p.header.freeSpaceUpper -= recordSize
+		slot.itemOffset = p.header.freeSpaceUpper
+	default:
+		return ErrNoMoreRoomInPage
+	}
+	// copy the record to the Page
+	slot.itemLength = recordSize
+	beg, end = slot.itemBounds()
+	copy(p.data[beg:end], r)
+	// the record prefix may have changed, so
+	// sort the slot pointers again
+	p.sortSlotsByRecordPrefix()
+	return nil
+}
+
+// liveSlots returns the slots currently holding records, along
+// with the highest slot id in use (or -1 if there are none)
+func (p *Page) liveSlots() ([]*pageSlot, int) {
+	var live []*pageSlot
+	last := -1
+	for _, s := range p.slots {
+		if s.itemStatus == itemStatusFree {
+			continue
+		}
+		live = append(live, s)
+		if int(s.itemID) > last {
+			last = int(s.itemID)
+		}
+	}
+	return live, last
+}
+
+// compactedFreeSpace returns the (contiguous) free space that
+// would be left in the Page once it has been compacted
+func (p *Page) compactedFreeSpace() uint32 {
+	live, last := p.liveSlots()
+	hdr, _ := pageLayout(p.header.version)
+	lower := uint32(hdr) + uint32(last+1)*p.slotSize()
+	upper := uint32(len(p.data))
+	for _, s := range live {
+		upper -= s.itemLength
+	}
+	return upper - lower
+}
+
+// Compact defragments the Page. Deleted records leave holes in the
+// Page that can only be reused by a record that fits in them, so the
+// live records are slid together at the end of the Page (rewriting
+// their slot offsets) leaving all the free space in a single block.
+// Free slots at the end of the slot list are reclaimed, any other free
+// slots are kept (with no room) so every RecordID stays the same.
+func (p *Page) Compact() {
+	// any borrowed views of the Page are no longer valid
+	p.data = p.borrows.release(p.data)
+	live, last := p.liveSlots()
+	// slide the live records towards the end of the Page, starting
+	// with the record closest to the end so nothing gets overwritten
+	sort.Slice(live, func(i, j int) bool {
+		return live[i].itemOffset > live[j].itemOffset
+	})
+	upper := uint32(len(p.data))
+	for _, s := range live {
+		upper -= s.itemLength
+		beg, end := s.itemBounds()
+		copy(p.data[upper:], p.data[beg:end])
+		s.itemOffset = upper
+	}
+	// drop the free slots past the last slot in use, and empty
+	// out the rest of them (they no longer own any room)
+	slots := p.slots[:0]
+	var free uint16
+	for _, s := range p.slots {
+		if s.itemStatus == itemStatusFree {
+			if int(s.itemID) > last {
+				continue
+			}
+			s.itemOffset, s.itemLength = 0, 0
+			free++
+		}
+		slots = append(slots, s)
+	}
+	for i := len(slots); i < len(p.slots); i++ {
+		p.slots[i] = nil
+	}
+	p.slots = slots
+	// update the Page header
+	hdr, _ := pageLayout(p.header.version)
+	p.header.slotCount = uint16(last + 1)
+	p.header.freeSlotCount = free
+	p.header.freeSpaceLower = uint32(hdr) + uint32(p.header.slotCount)*p.slotSize()
+	p.header.freeSpaceUpper = upper
+	// and zero out the free space, so none of the old slots or
+	// record data is left lying around in it
+	for i := p.header.freeSpaceLower; i < upper; i++ {
+		p.data[i] = 0
+	}
+}
+
 // Range is a record iterator method for a Page's records
 func (p *Page) Range(fn func(rid *RecordID) bool) {
 	for i := range p.slots {
@@ -420,7 +647,7 @@ func (p *Page) Range(fn func(rid *RecordID) bool) {
 		if !fn(
 			&RecordID{
 				PageID: p.header.pageID,
-				SlotID: uint16(i),
+				SlotID: p.slots[i].itemID,
 			},
 		) {
 			break
@@ -439,8 +666,8 @@ func (p *Page) String() string {
 	printHeader := struct {
 		PageID         uint32 `json:"page_id"`
 		NextPageID     uint32 `json:"next_page_id"`
-		FreeSpaceLower uint16 `json:"free_space_lower"`
-		FreeSpaceUpper uint16 `json:"free_space_upper"`
+		FreeSpaceLower uint32 `json:"free_space_lower"`
+		FreeSpaceUpper uint32 `json:"free_space_upper"`
 		SlotCount      uint16 `json:"slot_count"`
 		FreeSlotCount  uint16 `json:"free_slot_count"`
 	}{
@@ -1,6 +1,7 @@
 package pager
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"testing"
@@ -125,3 +126,130 @@ func Test_pageHeader_FreeSpace(t *testing.T) {
 	}
 
 }
+
+func TestPage_Compact(t *testing.T) {
+	pg := NewPage(1)
+	// fill the Page up with records
+	var recs []*RecordID
+	for i := 0; i < 8; i++ {
+		rec := bytes.Repeat([]byte{byte('a' + i)}, 900)
+		rid, err := pg.AddRecord(rec)
+		if err != nil {
+			t.Fatalf("[Page] adding record: %s", err)
+		}
+		recs = append(recs, rid)
+	}
+	// punch some holes in it, and free the last slot
+	for _, i := range []int{0, 2, 4, 7} {
+		err := pg.DelRecord(recs[i])
+		if err != nil {
+			t.Fatalf("[Page] deleting record: %s", err)
+		}
+	}
+	// a record larger than any of the holes should only
+	// fit once the Page has been compacted
+	big := bytes.Repeat([]byte{'z'}, 2000)
+	if pg.hasRoom(uint32(len(big))) {
+		t.Fatalf("[Page] expected no contiguous room for the record")
+	}
+	rid, err := pg.AddRecord(big)
+	if err != nil {
+		t.Fatalf("[Page] adding record: %s", err)
+	}
+	rec, err := pg.GetRecord(rid)
+	if err != nil || !bytes.Equal(rec, big) {
+		t.Errorf("[Page] getting record: got %d bytes, %v", len(rec), err)
+	}
+	// the records that were left should not have moved
+	for _, i := range []int{1, 3, 5, 6} {
+		rec, err := pg.GetRecord(recs[i])
+		if err != nil {
+			t.Errorf("[Page] getting record: %s", err)
+			continue
+		}
+		if !bytes.Equal(rec, bytes.Repeat([]byte{byte('a' + i)}, 900)) {
+			t.Errorf("[Page] record %v changed after compaction", recs[i])
+		}
+	}
+	// the trailing free slot should have been reclaimed
+	if pg.header.slotCount != 7 {
+		t.Errorf("[Page] expected 7 slots, got %d", pg.header.slotCount)
+	}
+	// and the Page should still survive an encode/decode
+	encodePage(pg)
+	pg2 := NewPage(1)
+	copy(pg2.data, pg.data)
+	err = decodePage(pg2, false)
+	if err != nil {
+		t.Fatalf("[Page] decoding page: %s", err)
+	}
+	rec, err = pg2.GetRecord(rid)
+	if err != nil || !bytes.Equal(rec, big) {
+		t.Errorf("[Page] getting record: got %d bytes, %v", len(rec), err)
+	}
+}
+
+func TestPage_UpdateRecord(t *testing.T) {
+	pg := NewPage(1)
+	recs := addRecords(pg)
+	// shrink a record in place
+	err := pg.UpdateRecord(recs[3], []byte("short-03"))
+	if err != nil {
+		t.Fatalf("[Page] updating record: %s", err)
+	}
+	// grow a record, so it has to move within the Page
+	grown := bytes.Repeat([]byte{'g'}, 100)
+	err = pg.UpdateRecord(recs[5], grown)
+	if err != nil {
+		t.Fatalf("[Page] updating record: %s", err)
+	}
+	rec, err := pg.GetRecord(recs[3])
+	if err != nil || string(rec) != "short-03" {
+		t.Errorf("[Page] getting record: got %q, %v", rec, err)
+	}
+	rec, err = pg.GetRecord(recs[5])
+	if err != nil || !bytes.Equal(rec, grown) {
+		t.Errorf("[Page] getting record: got %q, %v", rec, err)
+	}
+	// the other records should not have changed
+	rec, err = pg.GetRecord(recs[4])
+	if err != nil || string(rec) != "this-is-record-000004" {
+		t.Errorf("[Page] getting record: got %q, %v", rec, err)
+	}
+	// a record too large for the Page should leave it untouched
+	err = pg.UpdateRecord(recs[5], make([]byte, pg.header.FreeSpace()+200))
+	if err != ErrNoMoreRoomInPage {
+		t.Errorf("[Page] expected %v, got %v", ErrNoMoreRoomInPage, err)
+	}
+	rec, err = pg.GetRecord(recs[5])
+	if err != nil || !bytes.Equal(rec, grown) {
+		t.Errorf("[Page] getting record: got %q, %v", rec, err)
+	}
+}
+
+func TestPage_BorrowRecord(t *testing.T) {
+	pg := NewPage(1)
+	recs := addRecords(pg)
+	rec, err := pg.BorrowRecord(recs[2])
+	if err != nil {
+		t.Fatalf("[Page] borrowing record: %s", err)
+	}
+	if string(rec) != "this-is-record-000002" {
+		t.Errorf("[Page] borrowing record: got %q", rec)
+	}
+	// borrowing should not allocate
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = pg.BorrowRecord(recs[2])
+	})
+	if allocs != 0 {
+		t.Errorf("[Page] expected no allocations, got %.1f", allocs)
+	}
+	err = pg.DelRecord(recs[2])
+	if err != nil {
+		t.Fatalf("[Page] deleting record: %s", err)
+	}
+	_, err = pg.BorrowRecord(recs[2])
+	if err != ErrRecordHasBeenMarkedFree {
+		t.Errorf("[Page] expected %v, got %v", ErrRecordHasBeenMarkedFree, err)
+	}
+}
@@ -177,6 +177,27 @@ func (p page) writeRecord(rec []byte) (uint32, error) {
 }
 
 func (p page) readRecord(rid uint) ([]byte, error) {
+	// borrow the record data first
+	rec, err := p.borrowRecord(rid)
+	if err != nil {
+		return nil, err
+	}
+	// create a new buffer to copy the
+	// record data into (so we are not
+	// returning a pointer to the base
+	// data, which would be unsafe)
+	data := make([]byte, len(rec))
+	copy(data, rec)
+	// return the record data along
+	// with a nil error
+	return data, nil
+}
+
+// borrowRecord is like readRecord, but it returns a view of the
+// record data pointing directly into the page instead of a copy, so
+// it does not allocate. The view is only valid until the page is next
+// modified (or reused), and it must never be written to.
+func (p page) borrowRecord(rid uint) ([]byte, error) {
 	// check to make sure the RecordID
 	// is not an invalid record id
 	if rid <= 0 || rid > uint(p.getSlotCount()) {
@@ -194,20 +215,11 @@ func (p page) readRecord(rid uint) ([]byte, error) {
 		// or removed
 		return nil, ErrRecordHasBeenMarkedFree
 	}
-	// create a new buffer to copy the
-	// record data into (so we are not
-	// returning a pointer to the base
-	// data, which would be unsafe)
-	data := make([]byte, getSlotLength(p, sid))
-	// get the record offsets for
-	// an easier time copying
+	// get the record offsets, and cap the
+	// view so appending to it can never
+	// write over the rest of the page
 	beg, end := p.slotEntryBounds(sid)
-	// copy the record data into the
-	// newly created buffer, and return
-	copy(data, p[beg:end])
-	// return the record data along
-	// with a nil error
-	return data, nil
+	return p[beg:end:end], nil
 }
 
 func (p page) removeRecord(rid uint) error {
@@ -402,18 +414,40 @@ func (p page) setHasOverflow(hasOverflow uint16) {
 	setHasOverflow(p[n:n+2], hasOverflow)
 }
 
-func (p page) getReserved() uint16 {
+func (p page) getVersion() uint16 {
 	// bounds check hint to compiler; see golang.org/issue/14808
 	_ = p[pageSize-1]
-	n := offReserved
-	return getReserved(p[n : n+2])
+	return getVersion(p)
 }
 
-func (p page) setReserved(reserved uint16) {
+func (p page) setVersion(version uint16) {
 	// early bounds check to guarantee safety of writes below
 	_ = p[pageSize-1]
-	n := offReserved
-	setReserved(p[n:n+2], reserved)
+	setVersion(p, version)
+}
+
+func (p page) getChecksum() uint32 {
+	// bounds check hint to compiler; see golang.org/issue/14808
+	_ = p[pageSize-1]
+	return getChecksum(p)
+}
+
+func (p page) setChecksum(checksum uint32) {
+	// early bounds check to guarantee safety of writes below
+	_ = p[pageSize-1]
+	setChecksum(p, checksum)
+}
+
+func (p page) getReserved() uint32 {
+	// bounds check hint to compiler; see golang.org/issue/14808
+	_ = p[pageSize-1]
+	return getReserved(p)
+}
+
+func (p page) setReserved(reserved uint32) {
+	// early bounds check to guarantee safety of writes below
+	_ = p[pageSize-1]
+	setReserved(p, reserved)
 }
 
 func (p page) getPageHeader() []byte {
@@ -446,6 +480,11 @@ func (p page) GetRecord(rid uint) ([]byte, error) {
 	return rec, err
 }
 
+func (p page) BorrowRecord(rid uint) ([]byte, error) {
+	rec, err := p.borrowRecord(rid)
+	return rec, err
+}
+
 func (p page) DelRecord(rid uint) error {
 	err := p.removeRecord(rid)
 	return err
@@ -497,9 +536,15 @@ func (p page) writeNewPageHeader(pid uint32) {
 	// encode hasOverflow
 	binary.LittleEndian.PutUint16(p[n:n+2], 0)
 	n += 2
-	// encode reserved
-	binary.LittleEndian.PutUint16(p[n:n+2], 0)
+	// encode version
+	binary.LittleEndian.PutUint16(p[n:n+2], pageFormatVersion)
 	n += 2
+	// encode checksum
+	binary.LittleEndian.PutUint32(p[n:n+4], 0)
+	n += 4
+	// encode reserved
+	binary.LittleEndian.PutUint32(p[n:n+4], 0)
+	n += 4
 	// return bytes encoded
 	// return n
 }
@@ -2,7 +2,10 @@ package pager
 
 import (
 	"encoding/binary"
+	"hash/crc32"
 	"io"
+
+	"github.com/cagnosolutions/pager/pkg/internal/slotted"
 )
 
 /*
@@ -14,7 +17,9 @@ import (
 	slotCount      uint16
 	freeSlotCount  uint16
 	hasOverflow    uint16
-	reserved       uint16
+	version        uint16
+	checksum       uint32
+	reserved       uint32
 */
 
 // PageReader reads a pageSize of bytes into p starting at
@@ -41,34 +46,54 @@ type PageWriter interface {
 	WritePage(p []byte, off int64) (n int, err error)
 }
 
-func readPageHeader(r io.ReadSeeker, h *pageHeader) (int, error) {
-	// make header buffer to read data into
-	buf := make([]byte, pageHeaderSize)
+// crc32c is the (Castagnoli) table used for Page checksums
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// pageChecksum calculates the checksum of the raw Page data
+// provided. The checksum covers the whole Page, except for
+// the checksum field itself.
+func pageChecksum(b []byte) uint32 {
+	return slotted.Checksum(b)
+}
+
+// setPageChecksum calculates and encodes the checksum of the
+// raw Page data provided into the Page header
+func setPageChecksum(b []byte) {
+	binary.LittleEndian.PutUint32(b[offChecksum:offChecksum+4], pageChecksum(b))
+}
+
+// verifyPageChecksum checks the checksum encoded in the Page
+// header against the raw Page data provided. Legacy pages (and
+// pages that have never been written) do not carry a checksum,
+// they are only accepted from a file using the legacy layout
+// (one with no superblock), and only if the checksum field is
+// zero. The legacy header is shorter, so in a legacy Page with
+// any slots the checksum field holds the first slot instead.
+func verifyPageChecksum(b []byte, legacy bool) error {
+	checksum := binary.LittleEndian.Uint32(b[offChecksum : offChecksum+4])
+	if legacy && binary.LittleEndian.Uint16(b[offVersion:offVersion+2]) == legacyPageFormatVersion &&
+		(checksum == 0 || binary.LittleEndian.Uint16(b[offSlotCount:offSlotCount+2]) > 0) {
+		return nil
+	}
+	if checksum != pageChecksum(b) {
+		return ErrPageChecksumMismatch
+	}
+	return nil
+}
+
+func readPageHeader(r io.ReadSeeker, h *pageHeader, size int) (int, error) {
+	// make header buffer to read data into (large
+	// enough to hold the header of any Page format)
+	buf := make([]byte, widePageHeaderSize)
 	// read the header from the underlying reader into the buffer
 	n, err := r.Read(buf)
 	if err != nil {
 		return n, err
 	}
-	// decode PageID
-	h.pageID = binary.LittleEndian.Uint32(buf[0:4])
-	// decode nextPageID
-	h.nextPageID = binary.LittleEndian.Uint32(buf[4:8])
-	// decode prevPageID
-	h.prevPageID = binary.LittleEndian.Uint32(buf[8:12])
-	// decode freeSpaceLower
-	h.freeSpaceLower = binary.LittleEndian.Uint16(buf[12:14])
-	// decode freeSpaceUpper
-	h.freeSpaceUpper = binary.LittleEndian.Uint16(buf[14:16])
-	// decode slotCount
-	h.slotCount = binary.LittleEndian.Uint16(buf[16:18])
-	// decode freeSlotCount
-	h.freeSlotCount = binary.LittleEndian.Uint16(buf[18:20])
-	// decode hasOverflow
-	h.hasOverflow = binary.LittleEndian.Uint16(buf[20:22])
-	// decode reserved
-	h.reserved = binary.LittleEndian.Uint16(buf[22:24])
+	// decode Page header
+	decodePageHeader(buf, h)
 	// seek to the start of the next Page header
-	nn, err := r.Seek(int64(pageSize-n), io.SeekCurrent)
+	nn, err := r.Seek(int64(size-n), io.SeekCurrent)
 	if err != nil {
 		return int(nn) + n, err
 	}
@@ -76,6 +101,9 @@ func readPageHeader(r io.ReadSeeker, h *pageHeader) (int, error) {
 	return int(nn) + n, nil
 }
 
+// decodePageHeader decodes the Page header found in b into h. It
+// returns the offset the Page slots start at, which depends on
+// the format version of the Page.
 func decodePageHeader(b []byte, h *pageHeader) int {
 	// get offset to decode Page header directly into Page data
 	var n int
@@ -89,10 +117,10 @@ func decodePageHeader(b []byte, h *pageHeader) int {
 	h.prevPageID = binary.LittleEndian.Uint32(b[n : n+4])
 	n += 4
 	// decode freeSpaceLower
-	h.freeSpaceLower = binary.LittleEndian.Uint16(b[n : n+2])
+	h.freeSpaceLower = uint32(binary.LittleEndian.Uint16(b[n : n+2]))
 	n += 2
 	// decode freeSpaceUpper
-	h.freeSpaceUpper = binary.LittleEndian.Uint16(b[n : n+2])
+	h.freeSpaceUpper = uint32(binary.LittleEndian.Uint16(b[n : n+2]))
 	n += 2
 	// decode slotCount
 	h.slotCount = binary.LittleEndian.Uint16(b[n : n+2])
@@ -103,9 +131,27 @@ func decodePageHeader(b []byte, h *pageHeader) int {
 	// decode hasOverflow
 	h.hasOverflow = binary.LittleEndian.Uint16(b[n : n+2])
 	n += 2
-	// decode reserved
-	h.hasOverflow = binary.LittleEndian.Uint16(b[n : n+2])
+	// decode version
+	h.version = binary.LittleEndian.Uint16(b[n : n+2])
 	n += 2
+	// legacy pages end here, and have no checksum
+	if h.version == legacyPageFormatVersion {
+		h.checksum, h.reserved = 0, 0
+		return n
+	}
+	// decode checksum
+	h.checksum = binary.LittleEndian.Uint32(b[n : n+4])
+	n += 4
+	// decode reserved
+	h.reserved = binary.LittleEndian.Uint32(b[n : n+4])
+	n += 4
+	// wide pages keep their free space bounds past the header
+	if h.version == widePageFormatVersion {
+		h.freeSpaceLower = binary.LittleEndian.Uint32(b[n : n+4])
+		n += 4
+		h.freeSpaceUpper = binary.LittleEndian.Uint32(b[n : n+4])
+		n += 4
+	}
 	// return
 	return n
 }
@@ -122,11 +168,15 @@ func encodePageHeader(b []byte, h *pageHeader) int {
 	// encode prevPageID
 	binary.LittleEndian.PutUint32(b[n:n+4], h.prevPageID)
 	n += 4
-	// encode freeSpaceLower
-	binary.LittleEndian.PutUint16(b[n:n+2], h.freeSpaceLower)
+	// encode freeSpaceLower and freeSpaceUpper (wide
+	// pages encode them past the header instead)
+	lower, upper := uint16(h.freeSpaceLower), uint16(h.freeSpaceUpper)
+	if h.version == widePageFormatVersion {
+		lower, upper = 0, 0
+	}
+	binary.LittleEndian.PutUint16(b[n:n+2], lower)
 	n += 2
-	// encode freeSpaceUpper
-	binary.LittleEndian.PutUint16(b[n:n+2], h.freeSpaceUpper)
+	binary.LittleEndian.PutUint16(b[n:n+2], upper)
 	n += 2
 	// encode slotCount
 	binary.LittleEndian.PutUint16(b[n:n+2], h.slotCount)
@@ -137,134 +187,164 @@ func encodePageHeader(b []byte, h *pageHeader) int {
 	// encode hasOverflow
 	binary.LittleEndian.PutUint16(b[n:n+2], h.hasOverflow)
 	n += 2
-	// encode reserved
-	binary.LittleEndian.PutUint16(b[n:n+2], h.reserved)
+	// encode version
+	binary.LittleEndian.PutUint16(b[n:n+2], h.version)
 	n += 2
+	// encode checksum
+	binary.LittleEndian.PutUint32(b[n:n+4], h.checksum)
+	n += 4
+	// encode reserved
+	binary.LittleEndian.PutUint32(b[n:n+4], h.reserved)
+	n += 4
+	// wide pages keep their free space bounds past the header
+	if h.version == widePageFormatVersion {
+		binary.LittleEndian.PutUint32(b[n:n+4], h.freeSpaceLower)
+		n += 4
+		binary.LittleEndian.PutUint32(b[n:n+4], h.freeSpaceUpper)
+		n += 4
+	}
 	// return bytes encoded
 	return n
 }
 
-func readPage(r io.Reader, p *Page) (int, error) {
-	// init Page data
-	p.data = make([]byte, pageSize)
-	// read Page data
-	nn, err := r.Read(p.data)
-	if err != nil {
-		return -1, err
-	}
-	// init Page header
-	p.header = new(pageHeader)
-	// decode Page header
-	n := decodePageHeader(p.data[0:pageHeaderSize], p.header)
-	// init Page slots
-	p.slots = make([]*pageSlot, p.header.slotCount)
-	// decode Page slots
+// encodePage encodes the Page header and slots directly into
+// the Page data, and then calculates and encodes the Page
+// checksum. It returns the bytes encoded.
+func encodePage(p *Page) int {
+	// encode Page header
+	n := encodePageHeader(p.data, p.header)
+	wide := p.header.version == widePageFormatVersion
+	// encode Page slots
 	for i := range p.slots {
-		// create a new pageSlot pointer
-		p.slots[i] = new(pageSlot)
 		// encode slot item prefix
-		p.slots[i].itemID = binary.LittleEndian.Uint16(p.data[n : n+2])
+		binary.LittleEndian.PutUint16(p.data[n:n+2], p.slots[i].itemID)
 		n += 2
 		// encode slot item status
-		p.slots[i].itemStatus = binary.LittleEndian.Uint16(p.data[n : n+2])
+		binary.LittleEndian.PutUint16(p.data[n:n+2], p.slots[i].itemStatus)
 		n += 2
+		// wide pages use 32-bit item offsets and lengths
+		if wide {
+			binary.LittleEndian.PutUint32(p.data[n:n+4], p.slots[i].itemOffset)
+			n += 4
+			binary.LittleEndian.PutUint32(p.data[n:n+4], p.slots[i].itemLength)
+			n += 4
+			continue
+		}
 		// encode slot item offset
-		p.slots[i].itemOffset = binary.LittleEndian.Uint16(p.data[n : n+2])
+		binary.LittleEndian.PutUint16(p.data[n:n+2], uint16(p.slots[i].itemOffset))
 		n += 2
 		// encode slot item length
-		p.slots[i].itemLength = binary.LittleEndian.Uint16(p.data[n : n+2])
+		binary.LittleEndian.PutUint16(p.data[n:n+2], uint16(p.slots[i].itemLength))
 		n += 2
 	}
-	// return bytes read
-	return nn, nil
+	// calculate and encode the checksum
+	setPageChecksum(p.data)
+	p.header.checksum = binary.LittleEndian.Uint32(p.data[offChecksum : offChecksum+4])
+	// return bytes encoded
+	return n
 }
 
-func readPageAt(r io.ReaderAt, offset int64) (*Page, error) {
-	// init new Page
-	p := new(Page)
-	// init new Page data
-	p.data = make([]byte, pageSize)
-	// read Page data into Page from the
-	// underlying pageManagerFile at the offset provided
-	_, err := r.ReadAt(p.data, offset)
+// decodePage verifies the checksum of the Page data and then
+// decodes the Page header and slots. Legacy pages (which are only
+// accepted if legacy is set, see verifyPageChecksum) are upgraded
+// to the current format version in memory.
+func decodePage(p *Page, legacy bool) error {
+	// verify the Page checksum
+	err := verifyPageChecksum(p.data, legacy)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	// init Page header
 	p.header = new(pageHeader)
 	// decode Page header
-	n := decodePageHeader(p.data[0:pageHeaderSize], p.header)
+	n := decodePageHeader(p.data, p.header)
+	wide := p.header.version == widePageFormatVersion
+	// make sure the Page format matches the Page size
+	if p.header.freeSpaceUpper != 0 && wide != (len(p.data) >= widePageSize) {
+		return ErrBadPageSize
+	}
 	// init Page slots
 	p.slots = make([]*pageSlot, p.header.slotCount)
 	// decode Page slots
 	for i := range p.slots {
 		// create a new pageSlot pointer
 		p.slots[i] = new(pageSlot)
-		// encode slot item prefix
+		// decode slot item prefix
 		p.slots[i].itemID = binary.LittleEndian.Uint16(p.data[n : n+2])
 		n += 2
-		// encode slot item status
+		// decode slot item status
 		p.slots[i].itemStatus = binary.LittleEndian.Uint16(p.data[n : n+2])
 		n += 2
-		// encode slot item offset
-		p.slots[i].itemOffset = binary.LittleEndian.Uint16(p.data[n : n+2])
+		// wide pages use 32-bit item offsets and lengths
+		if wide {
+			p.slots[i].itemOffset = binary.LittleEndian.Uint32(p.data[n : n+4])
+			n += 4
+			p.slots[i].itemLength = binary.LittleEndian.Uint32(p.data[n : n+4])
+			n += 4
+			continue
+		}
+		// decode slot item offset
+		p.slots[i].itemOffset = uint32(binary.LittleEndian.Uint16(p.data[n : n+2]))
 		n += 2
-		// encode slot item length
-		p.slots[i].itemLength = binary.LittleEndian.Uint16(p.data[n : n+2])
+		// decode slot item length
+		p.slots[i].itemLength = uint32(binary.LittleEndian.Uint16(p.data[n : n+2]))
 		n += 2
 	}
-	// return read Page
-	return p, nil
+	// upgrade legacy pages
+	return upgradePage(p)
 }
 
-func writePage(w io.Writer, p *Page) (int, error) {
-	// encode Page header
-	n := encodePageHeader(p.data[0:pageHeaderSize], p.header)
-	// encode Page slots
-	for i := range p.slots {
-		// encode slot item prefix
-		binary.LittleEndian.PutUint16(p.data[n:n+2], p.slots[i].itemID)
-		n += 2
-		// encode slot item status
-		binary.LittleEndian.PutUint16(p.data[n:n+2], p.slots[i].itemStatus)
-		n += 2
-		// encode slot item offset
-		binary.LittleEndian.PutUint16(p.data[n:n+2], p.slots[i].itemOffset)
-		n += 2
-		// encode slot item length
-		binary.LittleEndian.PutUint16(p.data[n:n+2], p.slots[i].itemLength)
-		n += 2
+// upgradePage upgrades a legacy Page (one with a 24 byte header
+// and no checksum) to the current Page format version. The slots
+// get moved to make room for the larger header, so the Page must
+// have at least that much free space left. Pages that have never
+// been written (all zeros) are left alone.
+func upgradePage(p *Page) error {
+	// check if there is anything to do
+	if p.header.version != legacyPageFormatVersion || p.header.freeSpaceUpper == 0 {
+		return nil
 	}
-	// write Page data
-	nn, err := w.Write(p.data)
-	if err != nil {
-		return nn, err
+	// make sure there is room for the larger header
+	grow := uint32(pageHeaderSize - legacyPageHeaderSize)
+	if p.header.FreeSpace() < grow {
+		return ErrNoMoreRoomInPage
 	}
-	// return bytes written and possible error
-	return nn, nil
+	// clear out the old slots, they will get encoded in
+	// their new location the next time the Page is written
+	for i := legacyPageHeaderSize; i < int(p.header.freeSpaceLower); i++ {
+		p.data[i] = 0
+	}
+	// update the Page header
+	p.header.freeSpaceLower += grow
+	p.header.version = pageFormatVersion
+	return nil
 }
 
-func writePageAt(w io.WriterAt, p *Page, offset int64) (int, error) {
-	// encode Page header
-	n := encodePageHeader(p.data[0:pageHeaderSize], p.header)
-	// encode Page slots
-	for i := range p.slots {
-		// encode slot item prefix
-		binary.LittleEndian.PutUint16(p.data[n:n+2], p.slots[i].itemID)
-		n += 2
-		// encode slot item status
-		binary.LittleEndian.PutUint16(p.data[n:n+2], p.slots[i].itemStatus)
-		n += 2
-		// encode slot item offset
-		binary.LittleEndian.PutUint16(p.data[n:n+2], p.slots[i].itemOffset)
-		n += 2
-		// encode slot item length
-		binary.LittleEndian.PutUint16(p.data[n:n+2], p.slots[i].itemLength)
-		n += 2
+func readPageAt(r PageReader, offset int64, size int, legacy bool) (*Page, error) {
+	// init new Page
+	p := new(Page)
+	// init new Page data
+	p.data = make([]byte, size)
+	// read Page data into Page from the
+	// underlying pageManagerFile at the offset provided
+	_, err := r.ReadPage(p.data, offset)
+	if err != nil {
+		return nil, err
 	}
-	// write Page data to the underlying
-	// pageManagerFile at the offset provided
-	nn, err := w.WriteAt(p.data, offset)
+	// verify and decode Page
+	err = decodePage(p, legacy)
+	if err != nil {
+		return nil, err
+	}
+	// return read Page
+	return p, nil
+}
+
+func writePage(w io.Writer, p *Page) (int, error) {
+	// encode Page header, slots and checksum
+	encodePage(p)
+	// write Page data
+	nn, err := w.Write(p.data)
 	if err != nil {
 		return nn, err
 	}
@@ -272,29 +352,12 @@ func writePageAt(w io.WriterAt, p *Page, offset int64) (int, error) {
 	return nn, nil
 }
 
-func deletePageAt(w io.WriterAt, pid uint32, offset int64) (int, error) {
-	// create a new "empty" Page
-	p := NewPage(pid)
-	// encode Page header
-	n := encodePageHeader(p.data[0:pageHeaderSize], p.header)
-	// encode Page slots
-	for i := range p.slots {
-		// encode slot item prefix
-		binary.LittleEndian.PutUint16(p.data[n:n+2], p.slots[i].itemID)
-		n += 2
-		// encode slot item status
-		binary.LittleEndian.PutUint16(p.data[n:n+2], p.slots[i].itemStatus)
-		n += 2
-		// encode slot item offset
-		binary.LittleEndian.PutUint16(p.data[n:n+2], p.slots[i].itemOffset)
-		n += 2
-		// encode slot item length
-		binary.LittleEndian.PutUint16(p.data[n:n+2], p.slots[i].itemLength)
-		n += 2
-	}
+func writePageAt(w PageWriter, p *Page, offset int64) (int, error) {
+	// encode Page header, slots and checksum
+	encodePage(p)
 	// write Page data to the underlying
 	// pageManagerFile at the offset provided
-	nn, err := w.WriteAt(p.data, offset)
+	nn, err := w.WritePage(p.data, offset)
 	if err != nil {
 		return nn, err
 	}
@@ -0,0 +1,144 @@
+package pager
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadPageAt_ChecksumMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checksum.db")
+	f, err := OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	p := f.AllocatePage()
+	_, err = p.AddRecord([]byte("this-is-record-000001"))
+	if err != nil {
+		t.Fatalf("[Page] adding record: %s", err)
+	}
+	err = f.WritePage(p)
+	if err != nil {
+		t.Fatalf("[file] write page: %s", err)
+	}
+	// a fresh read should verify
+	_, err = f.ReadPage(p.PageID())
+	if err != nil {
+		t.Fatalf("[file] read page: %s", err)
+	}
+	// flip a bit in the record data on disk
+	off := f.pagePosition(p.PageID()) + pageSize - 1
+	b := make([]byte, 1)
+	_, err = f.fp.ReadAt(b, off)
+	if err != nil {
+		t.Fatalf("[file] read: %s", err)
+	}
+	b[0] ^= 0x01
+	_, err = f.fp.WriteAt(b, off)
+	if err != nil {
+		t.Fatalf("[file] write: %s", err)
+	}
+	_, err = f.ReadPage(p.PageID())
+	if err != ErrPageChecksumMismatch {
+		t.Errorf("[file] expected %v, got %v", ErrPageChecksumMismatch, err)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatalf("[file] close: %s", err)
+	}
+}
+
+func TestReadPageAt_ZeroedVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checksum.db")
+	f, err := OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	defer f.Close()
+	p := f.AllocatePage()
+	_, err = p.AddRecord([]byte("this-is-record-000001"))
+	if err != nil {
+		t.Fatalf("[Page] adding record: %s", err)
+	}
+	err = f.WritePage(p)
+	if err != nil {
+		t.Fatalf("[file] write page: %s", err)
+	}
+	// zero out the version and the checksum on disk, so the
+	// Page looks like a legacy Page
+	off := f.pagePosition(p.PageID()) + offVersion
+	_, err = f.fp.WriteAt(make([]byte, offChecksum+4-offVersion), off)
+	if err != nil {
+		t.Fatalf("[file] write: %s", err)
+	}
+	// a file with a superblock has no legacy pages in it
+	_, err = f.ReadPage(p.PageID())
+	if err != ErrPageChecksumMismatch {
+		t.Errorf("[file] expected %v, got %v", ErrPageChecksumMismatch, err)
+	}
+}
+
+// makeLegacyPage encodes a Page using the legacy (version 0) format
+// with a single record in it
+func makeLegacyPage(pid uint32, rec []byte) []byte {
+	b := make([]byte, pageSize)
+	upper := uint16(pageSize - len(rec))
+	binary.LittleEndian.PutUint32(b[0:4], pid)
+	binary.LittleEndian.PutUint16(b[12:14], legacyPageHeaderSize+pageSlotSize)
+	binary.LittleEndian.PutUint16(b[14:16], upper)
+	binary.LittleEndian.PutUint16(b[16:18], 1)
+	// slot 0
+	binary.LittleEndian.PutUint16(b[24:26], 0)
+	binary.LittleEndian.PutUint16(b[26:28], itemStatusUsed)
+	binary.LittleEndian.PutUint16(b[28:30], upper)
+	binary.LittleEndian.PutUint16(b[30:32], uint16(len(rec)))
+	copy(b[upper:], rec)
+	return b
+}
+
+func TestPageManager_Upgrade(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	rec := []byte("this-is-a-legacy-record")
+	// write a legacy file by hand
+	err := os.WriteFile(path, makeLegacyPage(0, rec), 0666)
+	if err != nil {
+		t.Fatalf("[file] write legacy file: %s", err)
+	}
+	f, err := OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	defer f.Close()
+	rid := &RecordID{PageID: 0, SlotID: 0}
+	// legacy pages can be read before upgrading
+	p, err := f.ReadPage(0)
+	if err != nil {
+		t.Fatalf("[file] read page: %s", err)
+	}
+	got, err := p.GetRecord(rid)
+	if err != nil || string(got) != string(rec) {
+		t.Fatalf("[Page] get record: got %q, %v", got, err)
+	}
+	err = f.Upgrade()
+	if err != nil {
+		t.Fatalf("[file] upgrade: %s", err)
+	}
+	// the Page on disk should now be the current version
+	b := make([]byte, pageSize)
+	_, err = f.fp.ReadAt(b, 0)
+	if err != nil {
+		t.Fatalf("[file] read: %s", err)
+	}
+	if v := binary.LittleEndian.Uint16(b[offVersion:]); v != pageFormatVersion {
+		t.Errorf("[file] expected version %d, got %d", pageFormatVersion, v)
+	}
+	p, err = f.ReadPage(0)
+	if err != nil {
+		t.Fatalf("[file] read page: %s", err)
+	}
+	got, err = p.GetRecord(rid)
+	if err != nil || string(got) != string(rec) {
+		t.Errorf("[Page] get record after upgrade: got %q, %v", got, err)
+	}
+}
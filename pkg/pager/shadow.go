@@ -0,0 +1,296 @@
+package pager
+
+import (
+	"encoding/binary"
+)
+
+// A PageManager created with shadow paging (see OpenPageManagerWithShadowPaging)
+// never overwrites a live Page. Instead, every Page id handed out by the
+// PageManager is a logical Page id, which is mapped to a physical Page in
+// the underlying file by a Page table. Writing a Page writes it to a fresh
+// physical Page (reusing free physical pages first), and then writes a new
+// copy of the Page table to fresh physical pages as well. Once everything
+// has been synced, the new Page table is published by pointing the
+// superblock at it. The superblock is only a few bytes, and is written
+// through the write-ahead log so it is never torn, but the pages themselves
+// are never logged. A crash at any point leaves the file as it was after
+// the last published Page table.
+//
+// The physical pages that were replaced are freed once the new Page table
+// has been published, unless a Snapshot that can still see them exists
+// (see mvcc.go).
+//
+// The Page table is stored as a chain of Page table pages, each holding a
+// single record:
+//
+//	bytes 0-4   = next Page table Page (physical id), or shadowUnmapped
+//	bytes 4-8   = number of entries in this Page
+//	bytes 8-..  = entries, the physical Page id for each logical Page id
+//
+// Every commit currently rewrites the whole Page table, which is fine for
+// the files we are using it for, but it is something to keep an eye on.
+
+// shadowUnmapped marks a logical Page with no physical Page (and the
+// end of the Page table chain)
+const shadowUnmapped = ^uint32(0)
+
+// shadowTablePageHeaderSize is the size of the header of the record
+// stored in each Page table Page
+const shadowTablePageHeaderSize = 8
+
+// shadowTable is the in memory state of a PageManager using shadow paging
+type shadowTable struct {
+	table      []uint32 // physical Page id for each logical Page id
+	tablePages []uint32 // physical pages holding the published Page table
+	free       []uint32 // physical pages that can be reused
+	retired    []retiredPage
+	next       uint32 // the next physical Page id past the end of the file
+}
+
+// retiredPage is a physical Page that was replaced by the commit
+// with the provided commit timestamp
+type retiredPage struct {
+	pid uint32
+	ts  uint64
+}
+
+// newShadowTable returns an empty shadowTable
+func newShadowTable() *shadowTable {
+	return &shadowTable{}
+}
+
+// physical returns the physical Page id for the logical Page id provided
+func (s *shadowTable) physical(pid uint32) (uint32, bool) {
+	if int(pid) >= len(s.table) || s.table[pid] == shadowUnmapped {
+		return 0, false
+	}
+	return s.table[pid], true
+}
+
+// alloc returns a physical Page id to write to, reusing free pages first
+func (s *shadowTable) alloc() uint32 {
+	if n := len(s.free); n > 0 {
+		pid := s.free[n-1]
+		s.free = s.free[:n-1]
+		return pid
+	}
+	pid := s.next
+	s.next++
+	return pid
+}
+
+// reclaim frees any retired pages that none of the live snapshots can
+// see, given the commit timestamp of the oldest live snapshot
+func (s *shadowTable) reclaim(oldest uint64) {
+	retired := s.retired[:0]
+	for _, r := range s.retired {
+		// a snapshot taken at ts can see the Page if ts < r.ts
+		if r.ts <= oldest {
+			s.free = append(s.free, r.pid)
+			continue
+		}
+		retired = append(retired, r)
+	}
+	s.retired = retired
+}
+
+// physicalPosition calculates the position of a physical Page
+// in the underlying PageManager file
+func (f *PageManager) physicalPosition(pid uint32) int64 {
+	return f.base + getPagePosition(pid, f.pageSize)
+}
+
+// tableEntriesPerPage returns the number of Page table entries
+// that fit in a single Page table Page
+func (f *PageManager) tableEntriesPerPage() int {
+	return (f.MaxRecordSize() - shadowTablePageHeaderSize) / 4
+}
+
+// loadShadow reads in the published Page table, along with the Page
+// header of every Page it maps, and works out which physical pages
+// are free
+func (f *PageManager) loadShadow() error {
+	s := newShadowTable()
+	// every physical Page in the file is free, unless
+	// it is in use by the Page table (or a Page)
+	fi, err := f.fp.Stat()
+	if err != nil {
+		return err
+	}
+	if size := fi.Size() - f.base; size > 0 {
+		s.next = uint32((size + int64(f.pageSize) - 1) / int64(f.pageSize))
+	}
+	used := make([]bool, s.next)
+	// follow the Page table chain
+	for pid := f.sb.tableRoot; pid != shadowUnmapped; {
+		if pid >= s.next || used[pid] {
+			return ErrBadPageTable
+		}
+		used[pid] = true
+		s.tablePages = append(s.tablePages, pid)
+		p, err := readPageAt(f.store, f.physicalPosition(pid), f.pageSize, f.legacy())
+		if err != nil {
+			return err
+		}
+		rec, err := p.BorrowRecord(&RecordID{PageID: p.PageID(), SlotID: 0})
+		if err != nil || len(rec) < shadowTablePageHeaderSize {
+			return ErrBadPageTable
+		}
+		n := int(binary.LittleEndian.Uint32(rec[4:8]))
+		if shadowTablePageHeaderSize+4*n > len(rec) {
+			return ErrBadPageTable
+		}
+		for i := 0; i < n; i++ {
+			off := shadowTablePageHeaderSize + 4*i
+			s.table = append(s.table, binary.LittleEndian.Uint32(rec[off:off+4]))
+		}
+		pid = binary.LittleEndian.Uint32(rec[0:4])
+	}
+	// read in the Page headers using the Page table
+	for lpid, pid := range s.table {
+		if pid == shadowUnmapped {
+			// a Page that was allocated, but never written
+			f.pageHeaders = append(f.pageHeaders, f.NewPage(uint32(lpid)).header)
+			f.pids.getNewPageID()
+			continue
+		}
+		if pid >= s.next || used[pid] {
+			return ErrBadPageTable
+		}
+		used[pid] = true
+		p, err := readPageAt(f.store, f.physicalPosition(pid), f.pageSize, f.legacy())
+		if err != nil {
+			return err
+		}
+		f.pageHeaders = append(f.pageHeaders, p.header)
+		f.pids.getNewPageID()
+		if p.header.PageIsFree() {
+			f.freePages++
+		}
+	}
+	for pid := range used {
+		if !used[pid] {
+			s.free = append(s.free, uint32(pid))
+		}
+	}
+	f.shadow = s
+	return nil
+}
+
+// writeShadowPages writes the provided pages to fresh physical pages,
+// writes a new copy of the Page table, and publishes it. If something
+// goes wrong before the Page table is published, nothing changes. It
+// must be called holding the PageManager lock (and the write latches
+// for the pages).
+func (f *PageManager) writeShadowPages(ps []*Page) error {
+	s := f.shadow
+	// remember where we started, so we can put things back
+	table := make([]uint32, len(s.table))
+	copy(table, s.table)
+	free, next := len(s.free), s.next
+	undo := func() {
+		// pages taken from the free list are popped off the
+		// end, and nothing is added to it until we publish,
+		// so they are all still there past the end of it
+		s.table = table
+		s.free = s.free[:free]
+		s.next = next
+	}
+	var retired []uint32
+	// write each Page to a fresh physical Page
+	for _, p := range ps {
+		pid := s.alloc()
+		_, err := writePageAt(f.store, p, f.physicalPosition(pid))
+		if err != nil {
+			undo()
+			return ErrWritingPage
+		}
+		lpid := p.PageID()
+		for int(lpid) >= len(s.table) {
+			s.table = append(s.table, shadowUnmapped)
+		}
+		if old := s.table[lpid]; old != shadowUnmapped {
+			retired = append(retired, old)
+		}
+		s.table[lpid] = pid
+	}
+	// write the new Page table
+	tablePages, err := f.writePageTable()
+	if err != nil {
+		undo()
+		return err
+	}
+	// make sure everything is on disk before we publish it
+	err = f.store.flush()
+	if err != nil {
+		undo()
+		return err
+	}
+	// publish the new Page table
+	root := f.sb.tableRoot
+	f.sb.tableRoot = tablePages[0]
+	err = f.writeSuperblock()
+	if err != nil {
+		f.sb.tableRoot = root
+		undo()
+		return err
+	}
+	// the old Page table and the replaced pages can be
+	// freed, once no snapshot can see them anymore
+	retired = append(retired, s.tablePages...)
+	s.tablePages = tablePages
+	f.mvcc.Lock()
+	f.mvcc.ts++
+	for _, pid := range retired {
+		s.retired = append(s.retired, retiredPage{pid: pid, ts: f.mvcc.ts})
+	}
+	s.reclaim(f.mvcc.oldest())
+	f.mvcc.Unlock()
+	// update the Page headers in the cache
+	for _, p := range ps {
+		f.setPageHeader(p.header)
+	}
+	return nil
+}
+
+// writePageTable writes the Page table out to fresh physical pages,
+// and returns them (in order)
+func (f *PageManager) writePageTable() ([]uint32, error) {
+	s := f.shadow
+	per := f.tableEntriesPerPage()
+	n := (len(s.table) + per - 1) / per
+	if n == 0 {
+		n = 1
+	}
+	pids := make([]uint32, n)
+	for i := range pids {
+		pids[i] = s.alloc()
+	}
+	for i, pid := range pids {
+		entries := s.table[i*per:]
+		if len(entries) > per {
+			entries = entries[:per]
+		}
+		rec := make([]byte, shadowTablePageHeaderSize+4*len(entries))
+		next := shadowUnmapped
+		if i+1 < len(pids) {
+			next = pids[i+1]
+		}
+		binary.LittleEndian.PutUint32(rec[0:4], next)
+		binary.LittleEndian.PutUint32(rec[4:8], uint32(len(entries)))
+		for j, e := range entries {
+			off := shadowTablePageHeaderSize + 4*j
+			binary.LittleEndian.PutUint32(rec[off:off+4], e)
+		}
+		p := f.NewPage(pid)
+		_, err := p.AddRecord(rec)
+		if err != nil {
+			return nil, err
+		}
+		_, err = writePageAt(f.store, p, f.physicalPosition(pid))
+		if err != nil {
+			return nil, ErrWritingPage
+		}
+	}
+	return pids, nil
+}
@@ -0,0 +1,108 @@
+package pager
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPageManager_ShadowPaging(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shadow.db")
+	f, err := OpenPageManagerWithShadowPaging(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	rid, err := f.AddRecord([]byte("this-is-record-000001"))
+	if err != nil {
+		t.Fatalf("[file] add record: %s", err)
+	}
+	// writing the page again should move it to a new physical page
+	before, _ := f.shadow.physical(rid.PageID)
+	err = f.UpdateRecord(rid, []byte("this-is-record-000002"))
+	if err != nil {
+		t.Fatalf("[file] update record: %s", err)
+	}
+	after, _ := f.shadow.physical(rid.PageID)
+	if before == after {
+		t.Errorf("[file] expected page %d to move, it stayed at %d", rid.PageID, before)
+	}
+	// a snapshot keeps seeing the page as it was
+	snap := f.Snapshot()
+	err = f.UpdateRecord(rid, []byte("this-is-record-000003"))
+	if err != nil {
+		t.Fatalf("[file] update record: %s", err)
+	}
+	p, err := snap.ReadPage(rid.PageID)
+	if err != nil {
+		t.Fatalf("[snapshot] read page: %s", err)
+	}
+	rec, err := p.GetRecord(rid)
+	if err != nil || string(rec) != "this-is-record-000002" {
+		t.Errorf("[snapshot] get record: got %q, %v", rec, err)
+	}
+	rec, err = f.GetRecord(rid)
+	if err != nil || string(rec) != "this-is-record-000003" {
+		t.Errorf("[file] get record: got %q, %v", rec, err)
+	}
+	// the pages the snapshot can see are not reused until it is released
+	seen := snap.table[rid.PageID]
+	for _, pid := range f.shadow.free {
+		if pid == seen {
+			t.Errorf("[file] physical page %d was freed while a snapshot can see it", pid)
+		}
+	}
+	snap.Release()
+	if _, err = snap.ReadPage(rid.PageID); err != ErrSnapshotReleased {
+		t.Errorf("[snapshot] expected %v, got %v", ErrSnapshotReleased, err)
+	}
+	found := false
+	for _, pid := range f.shadow.free {
+		found = found || pid == seen
+	}
+	if !found {
+		t.Errorf("[file] expected physical page %d to be freed", seen)
+	}
+	// "crash", and the last commit should still be there
+	crash(t, f)
+	f, err = OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	if f.shadow == nil {
+		t.Fatalf("[file] expected the file to be using shadow paging")
+	}
+	rec, err = f.GetRecord(rid)
+	if err != nil || string(rec) != "this-is-record-000003" {
+		t.Errorf("[file] get record: got %q, %v", rec, err)
+	}
+	// writing more pages should reuse the free physical pages
+	free := len(f.shadow.free)
+	if free == 0 {
+		t.Errorf("[file] expected some free physical pages after reopening")
+	}
+	next := f.shadow.next
+	err = f.UpdateRecord(rid, []byte("this-is-record-000004"))
+	if err != nil {
+		t.Fatalf("[file] update record: %s", err)
+	}
+	if f.shadow.next != next {
+		t.Errorf("[file] expected the file not to grow, next went from %d to %d", next, f.shadow.next)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatalf("[file] close: %s", err)
+	}
+	// a file without shadow paging can not be opened with it
+	path = filepath.Join(t.TempDir(), "logged.db")
+	f, err = OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatalf("[file] close: %s", err)
+	}
+	_, err = OpenPageManagerWithShadowPaging(path)
+	if err != ErrNoShadowPaging {
+		t.Errorf("[file] expected %v, got %v", ErrNoShadowPaging, err)
+	}
+}
@@ -0,0 +1,64 @@
+package pager
+
+import (
+	"os"
+	"sync"
+)
+
+// pageStore is the storage backend a PageManager reads and writes its
+// pages through. The default backend makes a ReadAt or WriteAt call on
+// the underlying file for every Page (see fileStore), while the mmap
+// backend copies pages in and out of a shared memory mapping of the
+// file instead, so reading a Page does not need a system call (see
+// OpenPageManagerWithMmap).
+type pageStore interface {
+	PageReader
+	PageWriter
+	// grow makes sure the underlying file is at least size bytes
+	grow(size int64) error
+	// flush makes sure every Page written has made it to disk
+	flush() error
+	// close releases the backend (but not the underlying file)
+	close() error
+}
+
+// fileStore is the default pageStore, it reads and writes
+// pages using the underlying file directly
+type fileStore struct {
+	mu sync.Mutex // serializes growing the file
+	fp *os.File
+}
+
+// ReadPage reads a Page from the underlying file at offset off
+func (s *fileStore) ReadPage(p []byte, off int64) (int, error) {
+	return s.fp.ReadAt(p, off)
+}
+
+// WritePage writes a Page to the underlying file at offset off
+func (s *fileStore) WritePage(p []byte, off int64) (int, error) {
+	return s.fp.WriteAt(p, off)
+}
+
+// grow makes sure the underlying file is at least size bytes
+func (s *fileStore) grow(size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fi, err := s.fp.Stat()
+	if err != nil {
+		return err
+	}
+	if size <= fi.Size() {
+		return nil
+	}
+	return s.fp.Truncate(size)
+}
+
+// flush syncs the underlying file
+func (s *fileStore) flush() error {
+	return s.fp.Sync()
+}
+
+// close is a no-op, there is nothing to release
+func (s *fileStore) close() error {
+	return nil
+}
@@ -0,0 +1,68 @@
+package pager
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestPageManager_Mmap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mmap.db")
+	f, err := OpenPageManagerWithMmap(path)
+	if err == ErrMmapUnsupported {
+		t.Skip("[file] mmap is not supported on this platform")
+	}
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	// add enough records to grow the file past the
+	// initial mapping (1 MB), so it has to be remapped
+	var rids []*RecordID
+	for i := 0; i < 256; i++ {
+		rec := bytes.Repeat([]byte(fmt.Sprintf("%.6d", i)), f.MaxRecordSize()/6)
+		rid, err := f.AddRecord(rec)
+		if err != nil {
+			t.Fatalf("[file] add record: %s", err)
+		}
+		rids = append(rids, rid)
+	}
+	check := func(f *PageManager) {
+		t.Helper()
+		for i, rid := range rids {
+			rec, err := f.GetRecord(rid)
+			if err != nil {
+				t.Fatalf("[file] get record: %s", err)
+			}
+			if !bytes.HasPrefix(rec, []byte(fmt.Sprintf("%.6d", i))) {
+				t.Fatalf("[file] record %v: got %q", rid, rec[:6])
+			}
+		}
+	}
+	check(f)
+	err = f.Close()
+	if err != nil {
+		t.Fatalf("[file] close: %s", err)
+	}
+	// the pages should be readable without the mapping
+	f, err = OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	check(f)
+	err = f.Close()
+	if err != nil {
+		t.Fatalf("[file] close: %s", err)
+	}
+	// and with it
+	f, err = OpenPageManagerWithMmap(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	defer f.Close()
+	check(f)
+	_, err = f.ReadPage(uint32(f.PageCount() + 10))
+	if err != ErrPageNotFound {
+		t.Errorf("[file] expected %v, got %v", ErrPageNotFound, err)
+	}
+}
@@ -0,0 +1,280 @@
+package pager
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"sort"
+)
+
+// The superblock is stored in the first Page of the PageManager file, so
+// every Page is found one Page further into the file than its pageID would
+// suggest. It records how the file was created (including the Page size,
+// which can not be changed once the file exists), and holds a small set of
+// named root slots, which can be used to find the root pages of any
+// structures stored in the file (the root of a B+tree, for example). The
+// superblock only makes use of the first superblockSize bytes of the Page.
+//
+//	bytes 0-4   = magic ("PGSB")
+//	bytes 4-6   = superblock version
+//	bytes 6-8   = Page format version
+//	bytes 8-12  = Page size
+//	bytes 12-16 = creation flags
+//	bytes 16-20 = Page count (as of the last time it was written)
+//	bytes 20-24 = checksum (crc32c of the superblockSize bytes, minus the checksum)
+//	bytes 24-26 = root slot count
+//	bytes 26-28 = reserved
+//	bytes 28-32 = Page table root (shadow paging only, see shadow.go)
+//	bytes 32-.. = root slots, each root slot is
+//	  byte  0     = name length
+//	  bytes 1-28  = name
+//	  bytes 28-32 = root Page id
+//
+// The superblock is always written through the write-ahead log, so it is
+// updated atomically. Files that were written before the superblock existed
+// start with Page 0 instead, they are still read (and written) using the
+// old layout, but they have no root slots. Files using shadow paging have
+// their own superblock version, so they are never opened by older code.
+
+const (
+	superblockMagic         = 0x42534750 // "PGSB"
+	superblockVersion       = 1
+	superblockVersionShadow = 2
+	superblockSize          = MinPageSize
+	superblockHeaderSize    = 32
+	rootSlotSize            = 32
+	maxRootNameSize         = rootSlotSize - 5
+	maxRootSlots            = (superblockSize - superblockHeaderSize) / rootSlotSize
+)
+
+// superblock is the decoded superblock of a PageManager file
+type superblock struct {
+	version    uint16
+	pageFormat uint16
+	pageSize   uint32
+	flags      uint32
+	pageCount  uint32
+	tableRoot  uint32
+	roots      map[string]uint32
+}
+
+// newSuperblock returns a superblock for a brand new file
+// using pages of the provided size
+func newSuperblock(flags uint32, size int) *superblock {
+	return &superblock{
+		version:    superblockVersion,
+		pageFormat: pageFormatFor(size),
+		pageSize:   uint32(size),
+		flags:      flags,
+		tableRoot:  shadowUnmapped,
+		roots:      make(map[string]uint32),
+	}
+}
+
+// superblockChecksum calculates the checksum of the encoded superblock
+func superblockChecksum(b []byte) uint32 {
+	crc := crc32.Checksum(b[:20], crc32c)
+	return crc32.Update(crc, crc32c, b[24:])
+}
+
+// encodeSuperblock encodes the superblock into a superblockSize buffer
+func encodeSuperblock(sb *superblock) []byte {
+	b := make([]byte, superblockSize)
+	binary.LittleEndian.PutUint32(b[0:4], superblockMagic)
+	binary.LittleEndian.PutUint16(b[4:6], sb.version)
+	binary.LittleEndian.PutUint16(b[6:8], sb.pageFormat)
+	binary.LittleEndian.PutUint32(b[8:12], sb.pageSize)
+	binary.LittleEndian.PutUint32(b[12:16], sb.flags)
+	binary.LittleEndian.PutUint32(b[16:20], sb.pageCount)
+	binary.LittleEndian.PutUint16(b[24:26], uint16(len(sb.roots)))
+	if sb.version == superblockVersionShadow {
+		binary.LittleEndian.PutUint32(b[28:32], sb.tableRoot)
+	}
+	// encode the root slots, sorted by name
+	names := make([]string, 0, len(sb.roots))
+	for name := range sb.roots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	n := superblockHeaderSize
+	for _, name := range names {
+		b[n] = uint8(len(name))
+		copy(b[n+1:n+1+maxRootNameSize], name)
+		binary.LittleEndian.PutUint32(b[n+28:n+32], sb.roots[name])
+		n += rootSlotSize
+	}
+	binary.LittleEndian.PutUint32(b[20:24], superblockChecksum(b))
+	return b
+}
+
+// decodeSuperblock decodes and validates the superblock found in b
+func decodeSuperblock(b []byte) (*superblock, error) {
+	if len(b) != superblockSize || binary.LittleEndian.Uint32(b[0:4]) != superblockMagic {
+		return nil, ErrBadSuperblock
+	}
+	if binary.LittleEndian.Uint32(b[20:24]) != superblockChecksum(b) {
+		return nil, ErrBadSuperblock
+	}
+	sb := &superblock{
+		version:    binary.LittleEndian.Uint16(b[4:6]),
+		pageFormat: binary.LittleEndian.Uint16(b[6:8]),
+		pageSize:   binary.LittleEndian.Uint32(b[8:12]),
+		flags:      binary.LittleEndian.Uint32(b[12:16]),
+		pageCount:  binary.LittleEndian.Uint32(b[16:20]),
+		tableRoot:  shadowUnmapped,
+		roots:      make(map[string]uint32),
+	}
+	if sb.version > superblockVersionShadow || sb.pageFormat > widePageFormatVersion {
+		return nil, ErrUnsupportedVersion
+	}
+	if sb.version == superblockVersionShadow {
+		sb.tableRoot = binary.LittleEndian.Uint32(b[28:32])
+	}
+	if !validPageSize(int(sb.pageSize)) {
+		return nil, ErrUnsupportedPageSize
+	}
+	count := int(binary.LittleEndian.Uint16(b[24:26]))
+	if count > maxRootSlots {
+		return nil, ErrBadSuperblock
+	}
+	n := superblockHeaderSize
+	for i := 0; i < count; i++ {
+		size := int(b[n])
+		if size > maxRootNameSize {
+			return nil, ErrBadSuperblock
+		}
+		name := string(b[n+1 : n+1+size])
+		sb.roots[name] = binary.LittleEndian.Uint32(b[n+28 : n+32])
+		n += rootSlotSize
+	}
+	return sb, nil
+}
+
+// superblockPageSize returns the Page size recorded in the (possibly
+// not yet verified) encoded superblock provided, which is also the
+// offset of Page 0 in the file
+func superblockPageSize(b []byte) int64 {
+	size := int(binary.LittleEndian.Uint32(b[8:12]))
+	if !validPageSize(size) {
+		return DefaultPageSize
+	}
+	return int64(size)
+}
+
+// loadSuperblock reads in the superblock from the start of the file. If
+// the file was written before the superblock existed, the file is left
+// using the old layout (with no superblock, and default sized pages).
+func (f *PageManager) loadSuperblock() error {
+	b := make([]byte, superblockSize)
+	_, err := f.fp.ReadAt(b, 0)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	// legacy files start with Page 0 (and a Page id of 0)
+	if binary.LittleEndian.Uint32(b[0:4]) != superblockMagic {
+		if f.pageSize != 0 && f.pageSize != DefaultPageSize {
+			return ErrPageSizeMismatch
+		}
+		f.sb, f.base, f.pageSize = nil, 0, DefaultPageSize
+		return nil
+	}
+	sb, err := decodeSuperblock(b)
+	if err != nil {
+		return err
+	}
+	// the file must be opened using the Page size it was
+	// created with (or without asking for a Page size)
+	if f.pageSize != 0 && f.pageSize != int(sb.pageSize) {
+		return ErrPageSizeMismatch
+	}
+	f.sb, f.base, f.pageSize = sb, int64(sb.pageSize), int(sb.pageSize)
+	return nil
+}
+
+// detectBase returns the offset of Page 0 in the underlying file, before
+// the superblock has been loaded (or replayed from the write-ahead log).
+// Only files starting with the superblock magic keep their pages past it.
+func (f *PageManager) detectBase() int64 {
+	b := make([]byte, superblockHeaderSize)
+	_, err := f.fp.ReadAt(b, 0)
+	if err != nil || binary.LittleEndian.Uint32(b[0:4]) != superblockMagic {
+		return 0
+	}
+	return superblockPageSize(b)
+}
+
+// writeSuperblock writes the superblock to the start of the file,
+// logging it in the write-ahead log first so it is never torn. It
+// must be called holding the PageManager lock.
+func (f *PageManager) writeSuperblock() error {
+	f.sb.pageCount = uint32(len(f.pageHeaders))
+	b := encodeSuperblock(f.sb)
+	// hold the checkpoint lock until it is written
+	f.ckpt.RLock()
+	defer f.ckpt.RUnlock()
+	err := f.wal.logSuperblock(b)
+	if err != nil {
+		return ErrWritingLog
+	}
+	_, err = f.fp.WriteAt(b, 0)
+	return err
+}
+
+// legacy reports whether the PageManager file is using the legacy
+// layout (with no superblock), whose pages may carry no checksum
+func (f *PageManager) legacy() bool {
+	return f.sb == nil
+}
+
+// Flags returns the flags the PageManager file was created with
+func (f *PageManager) Flags() uint32 {
+	if f.sb == nil {
+		return 0
+	}
+	return f.sb.flags
+}
+
+// Root returns the Page id stored in the named root slot, along with a
+// boolean reporting if the root slot exists
+func (f *PageManager) Root(name string) (uint32, bool) {
+	if f.sb == nil {
+		return 0, false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pid, ok := f.sb.roots[name]
+	return pid, ok
+}
+
+// SetRoot stores the Page id provided in the named root slot, creating
+// the root slot if it does not exist yet. The superblock is updated
+// atomically, and is durable once SetRoot returns.
+func (f *PageManager) SetRoot(name string, pid uint32) error {
+	if f.sb == nil {
+		return ErrNoSuperblock
+	}
+	if len(name) == 0 || len(name) > maxRootNameSize {
+		return ErrBadRootName
+	}
+	if f.readOnly {
+		return ErrReadOnly
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	old, ok := f.sb.roots[name]
+	if !ok && len(f.sb.roots) >= maxRootSlots {
+		return ErrTooManyRoots
+	}
+	f.sb.roots[name] = pid
+	err := f.writeSuperblock()
+	if err != nil {
+		// put things back the way they were
+		if ok {
+			f.sb.roots[name] = old
+		} else {
+			delete(f.sb.roots, name)
+		}
+		return err
+	}
+	return nil
+}
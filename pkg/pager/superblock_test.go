@@ -0,0 +1,109 @@
+package pager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPageManager_Superblock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "superblock.db")
+	f, err := OpenPageManagerWithFlags(path, 0x2a)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	p := f.AllocatePage()
+	rid, err := p.AddRecord([]byte("this-is-record-000001"))
+	if err != nil {
+		t.Fatalf("[Page] adding record: %s", err)
+	}
+	err = f.WritePage(p)
+	if err != nil {
+		t.Fatalf("[file] write page: %s", err)
+	}
+	err = f.SetRoot("tree", p.PageID())
+	if err != nil {
+		t.Fatalf("[file] set root: %s", err)
+	}
+	err = f.SetRoot("", 1)
+	if err != ErrBadRootName {
+		t.Errorf("[file] expected %v, got %v", ErrBadRootName, err)
+	}
+	// update a root, and "crash" before the superblock is checkpointed
+	err = f.SetRoot("index", 7)
+	if err != nil {
+		t.Fatalf("[file] set root: %s", err)
+	}
+	crash(t, f)
+	// the roots and flags should be there after reopening
+	f, err = OpenPageManagerWithFlags(path, 0)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	if f.Flags() != 0x2a {
+		t.Errorf("[file] expected flags %#x, got %#x", 0x2a, f.Flags())
+	}
+	if pid, ok := f.Root("tree"); !ok || pid != p.PageID() {
+		t.Errorf("[file] root %q: got %d, %v", "tree", pid, ok)
+	}
+	if pid, ok := f.Root("index"); !ok || pid != 7 {
+		t.Errorf("[file] root %q: got %d, %v", "index", pid, ok)
+	}
+	if _, ok := f.Root("missing"); ok {
+		t.Errorf("[file] root %q: expected it to be missing", "missing")
+	}
+	// and the pages should still be found past the superblock
+	pg, err := f.ReadPage(rid.PageID)
+	if err != nil {
+		t.Fatalf("[file] read page: %s", err)
+	}
+	rec, err := pg.GetRecord(rid)
+	if err != nil || string(rec) != "this-is-record-000001" {
+		t.Errorf("[Page] get record: got %q, %v", rec, err)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatalf("[file] close: %s", err)
+	}
+	// a corrupt superblock should fail verification
+	fp, err := os.OpenFile(path, os.O_RDWR, 0666)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	_, err = fp.WriteAt([]byte{0xff}, superblockHeaderSize+1)
+	if err != nil {
+		t.Fatalf("[file] write: %s", err)
+	}
+	err = fp.Close()
+	if err != nil {
+		t.Fatalf("[file] close: %s", err)
+	}
+	_, err = OpenPageManager(path)
+	if err != ErrBadSuperblock {
+		t.Errorf("[file] expected %v, got %v", ErrBadSuperblock, err)
+	}
+}
+
+func TestPageManager_LegacyLayout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	// write a file without a superblock by hand
+	err := os.WriteFile(path, makeLegacyPage(0, []byte("this-is-a-legacy-record")), 0666)
+	if err != nil {
+		t.Fatalf("[file] write legacy file: %s", err)
+	}
+	f, err := OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	defer f.Close()
+	if f.PageCount() != 1 {
+		t.Errorf("[file] expected 1 page, got %d", f.PageCount())
+	}
+	if _, ok := f.Root("tree"); ok {
+		t.Errorf("[file] expected no roots in a legacy file")
+	}
+	err = f.SetRoot("tree", 0)
+	if err != ErrNoSuperblock {
+		t.Errorf("[file] expected %v, got %v", ErrNoSuperblock, err)
+	}
+}
@@ -0,0 +1,173 @@
+package pager
+
+import (
+	"sort"
+)
+
+// Tx is a transaction over a PageManager. Pages written (or deleted)
+// through a Tx are staged in memory and are not written to the
+// PageManager until Commit is called, at which point every staged Page
+// is written in a single commit of the write-ahead log. Either all of
+// them make it to disk, or (after a crash) none of them do. Rollback
+// discards the staged pages. A Tx can not be used once it has been
+// committed or rolled back.
+type Tx struct {
+	f         *PageManager
+	dirty     map[uint32]*Page
+	allocated []uint32
+	claimed   []uint32
+	done      bool
+}
+
+// Begin starts a new transaction
+func (f *PageManager) Begin() *Tx {
+	return &Tx{
+		f:     f,
+		dirty: make(map[uint32]*Page),
+	}
+}
+
+// clonePage returns a copy of the Page provided, which shares
+// nothing with it, so the copy can be staged safely
+func clonePage(p *Page) (*Page, error) {
+	encodePage(p)
+	c := &Page{data: make([]byte, len(p.data))}
+	copy(c.data, p.data)
+	err := decodePage(c, false)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// AllocatePage allocates and returns a new Page. The Page is not
+// persisted unless it is written using the Tx, and the Tx commits.
+func (tx *Tx) AllocatePage() *Page {
+	p := tx.f.AllocatePage()
+	tx.allocated = append(tx.allocated, p.PageID())
+	return p
+}
+
+// GetFreeOrAllocate returns a free Page, or allocates a new one if there
+// are none (see PageManager.GetFreeOrAllocate). The Page is not persisted
+// unless it is written using the Tx, and the Tx commits. A free Page is
+// held for the Tx until it commits or rolls back.
+func (tx *Tx) GetFreeOrAllocate() *Page {
+	p, claimed := tx.f.getFreeOrAllocate()
+	if claimed {
+		tx.claimed = append(tx.claimed, p.PageID())
+	} else {
+		tx.allocated = append(tx.allocated, p.PageID())
+	}
+	return p
+}
+
+// ReadPage reads the Page for the provided pageID, including any
+// changes staged in the Tx. The Page returned is a copy, changes made
+// to it are only staged once it is written using the Tx.
+func (tx *Tx) ReadPage(pid uint32) (*Page, error) {
+	if tx.done {
+		return nil, ErrTxDone
+	}
+	// check the pages staged in the Tx
+	if p, ok := tx.dirty[pid]; ok {
+		return clonePage(p)
+	}
+	// otherwise, read it from the PageManager
+	return tx.f.ReadPage(pid)
+}
+
+// WritePage stages the provided Page in the Tx
+func (tx *Tx) WritePage(p *Page) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	// make sure the Page is the right size
+	if len(p.data) != tx.f.pageSize {
+		return ErrBadPageSize
+	}
+	// stage a copy, so any changes made to the Page
+	// after this are not picked up by the commit
+	c, err := clonePage(p)
+	if err != nil {
+		return err
+	}
+	tx.dirty[c.PageID()] = c
+	return nil
+}
+
+// WritePages stages the provided pages in the Tx
+func (tx *Tx) WritePages(ps []*Page) error {
+	for _, p := range ps {
+		err := tx.WritePage(p)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeletePage stages the Page with the matching pageID provided as
+// "free" in the Tx, it is overwritten with a fresh Page on commit
+func (tx *Tx) DeletePage(pid uint32) error {
+	return tx.WritePage(tx.f.NewPage(pid))
+}
+
+// Commit writes every Page staged in the Tx to the PageManager in a
+// single commit, and ends the Tx
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+	// hand back any free pages that are not being written
+	var unused []uint32
+	for _, pid := range tx.claimed {
+		if _, ok := tx.dirty[pid]; !ok {
+			unused = append(unused, pid)
+		}
+	}
+	tx.f.unclaim(unused...)
+	if len(tx.dirty) == 0 {
+		return nil
+	}
+	// write the pages in Page id order
+	ps := make([]*Page, 0, len(tx.dirty))
+	for _, p := range tx.dirty {
+		ps = append(ps, p)
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		return ps[i].PageID() < ps[j].PageID()
+	})
+	tx.dirty = nil
+	err := tx.f.WritePages(ps)
+	if err != nil {
+		// nothing was written, so the rest of
+		// the free pages can be handed out again
+		tx.f.unclaim(tx.claimed...)
+	}
+	return err
+}
+
+// Rollback discards every Page staged in the Tx, and ends the Tx. Any
+// free pages held for the Tx are handed back, and any Page ids allocated
+// by the Tx are given back, as long as nothing else has allocated a Page
+// since.
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+	tx.dirty = nil
+	// hand back the free pages held for the Tx
+	tx.f.unclaim(tx.claimed...)
+	tx.claimed = nil
+	// give back the Page ids allocated by the Tx, newest first
+	for i := len(tx.allocated) - 1; i >= 0; i-- {
+		if !tx.f.pids.undoGetNewPageID(tx.allocated[i]) {
+			break
+		}
+	}
+	tx.allocated = nil
+	return nil
+}
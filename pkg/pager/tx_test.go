@@ -0,0 +1,158 @@
+package pager
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTx_CommitAndRollback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tx.db")
+	f, err := OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	// write a page the normal way
+	p := f.AllocatePage()
+	rid, err := p.AddRecord([]byte("this-is-record-000001"))
+	if err != nil {
+		t.Fatalf("[Page] adding record: %s", err)
+	}
+	err = f.WritePage(p)
+	if err != nil {
+		t.Fatalf("[file] write page: %s", err)
+	}
+	// stage a change to it, and a new page, in a Tx
+	tx := f.Begin()
+	p, err = tx.ReadPage(rid.PageID)
+	if err != nil {
+		t.Fatalf("[tx] read page: %s", err)
+	}
+	err = p.DelRecord(rid)
+	if err != nil {
+		t.Fatalf("[Page] deleting record: %s", err)
+	}
+	err = tx.WritePage(p)
+	if err != nil {
+		t.Fatalf("[tx] write page: %s", err)
+	}
+	p2 := tx.AllocatePage()
+	rid2, err := p2.AddRecord([]byte("this-is-record-000002"))
+	if err != nil {
+		t.Fatalf("[Page] adding record: %s", err)
+	}
+	err = tx.WritePage(p2)
+	if err != nil {
+		t.Fatalf("[tx] write page: %s", err)
+	}
+	// the Tx sees the changes, the PageManager does not
+	pg, err := tx.ReadPage(rid.PageID)
+	if err != nil {
+		t.Fatalf("[tx] read page: %s", err)
+	}
+	if _, err = pg.GetRecord(rid); err != ErrRecordHasBeenMarkedFree {
+		t.Errorf("[tx] expected %v, got %v", ErrRecordHasBeenMarkedFree, err)
+	}
+	if _, err = f.GetRecord(rid); err != nil {
+		t.Errorf("[file] expected record to be there before commit, got: %s", err)
+	}
+	if f.PageCount() != 1 {
+		t.Errorf("[file] expected 1 page before commit, got %d", f.PageCount())
+	}
+	// commit, and the changes show up
+	err = tx.Commit()
+	if err != nil {
+		t.Fatalf("[tx] commit: %s", err)
+	}
+	if _, err = f.GetRecord(rid); err != ErrRecordHasBeenMarkedFree {
+		t.Errorf("[file] expected %v, got %v", ErrRecordHasBeenMarkedFree, err)
+	}
+	if _, err = f.GetRecord(rid2); err != nil {
+		t.Errorf("[file] get record: %s", err)
+	}
+	if err = tx.Commit(); err != ErrTxDone {
+		t.Errorf("[tx] expected %v, got %v", ErrTxDone, err)
+	}
+	// roll back a Tx, and nothing changes
+	tx = f.Begin()
+	err = tx.DeletePage(rid2.PageID)
+	if err != nil {
+		t.Fatalf("[tx] delete page: %s", err)
+	}
+	p3 := tx.AllocatePage()
+	err = tx.WritePage(p3)
+	if err != nil {
+		t.Fatalf("[tx] write page: %s", err)
+	}
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatalf("[tx] rollback: %s", err)
+	}
+	if _, err = tx.ReadPage(rid2.PageID); err != ErrTxDone {
+		t.Errorf("[tx] expected %v, got %v", ErrTxDone, err)
+	}
+	if _, err = f.GetRecord(rid2); err != nil {
+		t.Errorf("[file] expected record to survive rollback, got: %s", err)
+	}
+	// the page id allocated by the Tx should have been given back
+	if pid := f.AllocatePage().PageID(); pid != p3.PageID() {
+		t.Errorf("[file] expected page id %d to be reused, got %d", p3.PageID(), pid)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatalf("[file] close: %s", err)
+	}
+}
+
+func TestTx_ReleaseFreePages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tx-release.db")
+	f, err := OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	defer f.Close()
+	pid := freePage(t, f)
+	// a free Page held for a Tx is handed back on rollback
+	tx := f.Begin()
+	p := tx.GetFreeOrAllocate()
+	if p.PageID() != pid {
+		t.Fatalf("[tx] expected free page %d, got %d", pid, p.PageID())
+	}
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatalf("[tx] rollback: %s", err)
+	}
+	// and when the Tx commits without writing it
+	tx = f.Begin()
+	p = tx.GetFreeOrAllocate()
+	if p.PageID() != pid {
+		t.Fatalf("[tx] expected released page %d, got %d", pid, p.PageID())
+	}
+	err = tx.Commit()
+	if err != nil {
+		t.Fatalf("[tx] commit: %s", err)
+	}
+	p = f.GetFreeOrAllocate()
+	if p.PageID() != pid {
+		t.Fatalf("[file] expected released page %d, got %d", pid, p.PageID())
+	}
+	f.ReleasePage(p.PageID())
+	// but not once the Tx has written it
+	tx = f.Begin()
+	p = tx.GetFreeOrAllocate()
+	_, err = p.AddRecord([]byte("this-is-record-000001"))
+	if err != nil {
+		t.Fatalf("[Page] add record: %s", err)
+	}
+	err = tx.WritePage(p)
+	if err != nil {
+		t.Fatalf("[tx] write page: %s", err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		t.Fatalf("[tx] commit: %s", err)
+	}
+	p = f.GetFreeOrAllocate()
+	if p.PageID() == pid {
+		t.Fatalf("[file] page %d handed out while in use", pid)
+	}
+}
@@ -0,0 +1,303 @@
+package pager
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+const (
+	// used in the write-ahead log
+	walEntryHeaderSize = 24       // 24 bytes
+	walCheckpointSize  = 64 << 20 // 64 MB
+	walFileSuffix      = ".wal"
+)
+
+const (
+	walEntryPage uint16 = iota + 1
+	walEntryCommit
+	walEntrySuperblock
+)
+
+/*
+	lsn    uint64
+	kind   uint16
+	_      uint16
+	pageID uint32
+	length uint32
+	crc    uint32
+*/
+
+// walEntry is a single entry in the write-ahead log. An entry is either
+// a full page image, a superblock image, or a commit marker that closes out
+// every entry written before it (and after the previous commit marker).
+type walEntry struct {
+	lsn    uint64
+	kind   uint16
+	pageID uint32
+	data   []byte
+}
+
+// writeAheadLog is an append only log of page images. Every page written
+// through the PageManager is first appended to the log (and fsynced) before
+// it is written over the live page in the data file. On startup any
+// committed entries found in the log are redone against the data file.
+type writeAheadLog struct {
+	sync.Mutex
+	fp   *os.File
+	lsn  uint64
+	size int64
+}
+
+// openWriteAheadLog opens (or creates) the write-ahead log located at the
+// path provided.
+func openWriteAheadLog(path string) (*writeAheadLog, error) {
+	// open or create the log file
+	fp, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	// get the log file size info
+	fi, err := fp.Stat()
+	if err != nil {
+		return nil, err
+	}
+	// return the log, positioned at the end
+	return &writeAheadLog{
+		fp:   fp,
+		size: fi.Size(),
+	}, nil
+}
+
+// openWriteAheadLogReadOnly opens the write-ahead log located at the
+// path provided for reading only. A missing log is treated as empty.
+func openWriteAheadLogReadOnly(path string) (*writeAheadLog, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &writeAheadLog{}, nil
+		}
+		return nil, err
+	}
+	fi, err := fp.Stat()
+	if err != nil {
+		_ = fp.Close()
+		return nil, err
+	}
+	return &writeAheadLog{
+		fp:   fp,
+		size: fi.Size(),
+	}, nil
+}
+
+// walEntryChecksum calculates the checksum for an entry using the encoded
+// entry header (minus the checksum itself) along with the entry data
+func walEntryChecksum(hdr []byte, data []byte) uint32 {
+	crc := crc32.ChecksumIEEE(hdr[0:20])
+	return crc32.Update(crc, crc32.IEEETable, data)
+}
+
+// append encodes and appends an entry to the end of the log, assigning it
+// the next log sequence number. It does not sync the log.
+func (l *writeAheadLog) append(kind uint16, pid uint32, data []byte) (uint64, error) {
+	// assign the next log sequence number
+	l.lsn++
+	// make buffer to encode the entry into
+	buf := make([]byte, walEntryHeaderSize+len(data))
+	// encode lsn
+	binary.LittleEndian.PutUint64(buf[0:8], l.lsn)
+	// encode kind
+	binary.LittleEndian.PutUint16(buf[8:10], kind)
+	// encode reserved
+	binary.LittleEndian.PutUint16(buf[10:12], 0)
+	// encode pageID
+	binary.LittleEndian.PutUint32(buf[12:16], pid)
+	// encode length
+	binary.LittleEndian.PutUint32(buf[16:20], uint32(len(data)))
+	// copy entry data
+	copy(buf[walEntryHeaderSize:], data)
+	// encode checksum
+	binary.LittleEndian.PutUint32(buf[20:24], walEntryChecksum(buf, data))
+	// write the entry at the end of the log
+	n, err := l.fp.WriteAt(buf, l.size)
+	l.size += int64(n)
+	if err != nil {
+		return 0, err
+	}
+	return l.lsn, nil
+}
+
+// truncateOnError cuts the log back to the size (and log sequence number)
+// it had before a batch was logged, if logging the batch failed. Otherwise
+// the entries of a failed batch would be committed by the commit marker of
+// the next batch, or a torn entry would stop replay short of the batches
+// logged after it.
+func (l *writeAheadLog) truncateOnError(size int64, lsn uint64, err *error) {
+	if *err == nil {
+		return
+	}
+	_ = l.fp.Truncate(size)
+	l.size = size
+	l.lsn = lsn
+}
+
+// logPages appends a page image entry for each page provided followed by
+// a single commit entry, and then syncs the log. Once logPages returns
+// successfully the pages are considered durable.
+func (l *writeAheadLog) logPages(ps ...*Page) (err error) {
+	l.Lock()
+	defer l.Unlock()
+	defer l.truncateOnError(l.size, l.lsn, &err)
+	for _, p := range ps {
+		// make sure the header and slots are encoded into the page data
+		encodePage(p)
+		// append page image
+		_, err = l.append(walEntryPage, p.header.pageID, p.data)
+		if err != nil {
+			return err
+		}
+	}
+	// append commit marker
+	_, err = l.append(walEntryCommit, 0, nil)
+	if err != nil {
+		return err
+	}
+	// and sync the log
+	return l.fp.Sync()
+}
+
+// logSuperblock appends a superblock image entry followed by a commit
+// entry, and then syncs the log. Once logSuperblock returns successfully
+// the superblock is considered durable.
+func (l *writeAheadLog) logSuperblock(data []byte) (err error) {
+	l.Lock()
+	defer l.Unlock()
+	defer l.truncateOnError(l.size, l.lsn, &err)
+	// append superblock image
+	_, err = l.append(walEntrySuperblock, 0, data)
+	if err != nil {
+		return err
+	}
+	// append commit marker
+	_, err = l.append(walEntryCommit, 0, nil)
+	if err != nil {
+		return err
+	}
+	// and sync the log
+	return l.fp.Sync()
+}
+
+// readEntry reads and decodes the entry found at the offset provided. It
+// returns io.ErrUnexpectedEOF if the entry is torn or does not check out.
+func (l *writeAheadLog) readEntry(off int64) (*walEntry, int64, error) {
+	// read the entry header
+	hdr := make([]byte, walEntryHeaderSize)
+	_, err := l.fp.ReadAt(hdr, off)
+	if err != nil {
+		return nil, 0, err
+	}
+	// decode entry header
+	e := &walEntry{
+		lsn:    binary.LittleEndian.Uint64(hdr[0:8]),
+		kind:   binary.LittleEndian.Uint16(hdr[8:10]),
+		pageID: binary.LittleEndian.Uint32(hdr[12:16]),
+	}
+	length := binary.LittleEndian.Uint32(hdr[16:20])
+	crc := binary.LittleEndian.Uint32(hdr[20:24])
+	// sanity check the entry before allocating for it
+	if e.kind < walEntryPage || e.kind > walEntrySuperblock || length > MaxPageSize {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	// read the entry data
+	e.data = make([]byte, length)
+	_, err = l.fp.ReadAt(e.data, off+walEntryHeaderSize)
+	if err != nil {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	// verify the checksum
+	if walEntryChecksum(hdr, e.data) != crc {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	// return the entry along with the offset of the next one
+	return e, off + walEntryHeaderSize + int64(length), nil
+}
+
+// replay reads the log from the beginning and redoes every committed page
+// (and superblock) image against the writer provided. Page images are
+// written base bytes into the writer (at a position based on the size of
+// the image), and superblock images are written at the very start.
+// Entries that are not followed by a commit entry (a write that was
+// interrupted) are discarded. It returns the number of images redone.
+func (l *writeAheadLog) replay(w io.WriterAt, base int64) (int, error) {
+	var off int64
+	var redone int
+	var pending []*walEntry
+	for {
+		// read the next entry in the log
+		e, next, err := l.readEntry(off)
+		if err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				// reached the end of the log, or a torn entry
+				break
+			}
+			return redone, err
+		}
+		off = next
+		// keep track of the last lsn we have seen
+		if e.lsn > l.lsn {
+			l.lsn = e.lsn
+		}
+		if e.kind != walEntryCommit {
+			pending = append(pending, e)
+			continue
+		}
+		// found a commit entry, redo all the pending images
+		for _, pe := range pending {
+			off := base + getPagePosition(pe.pageID, len(pe.data))
+			if pe.kind == walEntrySuperblock {
+				// a file with a superblock keeps its pages
+				// just past the superblock, which takes up
+				// the first Page of the file
+				off, base = 0, superblockPageSize(pe.data)
+			}
+			_, err = w.WriteAt(pe.data, off)
+			if err != nil {
+				return redone, err
+			}
+			redone++
+		}
+		pending = pending[:0]
+	}
+	return redone, nil
+}
+
+// reset truncates the log. It must only be called once every committed
+// entry in the log has been written to (and synced in) the data file.
+func (l *writeAheadLog) reset() error {
+	l.Lock()
+	defer l.Unlock()
+	err := l.fp.Truncate(0)
+	if err != nil {
+		return err
+	}
+	l.size = 0
+	return l.fp.Sync()
+}
+
+// full reports if the log has grown large enough that
+// it is time to checkpoint
+func (l *writeAheadLog) full() bool {
+	l.Lock()
+	defer l.Unlock()
+	return l.size >= walCheckpointSize
+}
+
+// close closes the underlying log file
+func (l *writeAheadLog) close() error {
+	if l.fp == nil {
+		return nil
+	}
+	return l.fp.Close()
+}
@@ -0,0 +1,237 @@
+package pager
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// crash closes the underlying files of the PageManager without
+// checkpointing, leaving the write-ahead log as it was
+func crash(t *testing.T, f *PageManager) {
+	if err := f.store.close(); err != nil {
+		t.Fatalf("[file] close store: %s", err)
+	}
+	if err := f.wal.close(); err != nil {
+		t.Fatalf("[wal] close: %s", err)
+	}
+	if err := f.fp.Close(); err != nil {
+		t.Fatalf("[file] close: %s", err)
+	}
+}
+
+func TestWriteAheadLog_Replay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal-replay.db")
+	f, err := OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	// write a page the normal way
+	p := f.AllocatePage()
+	rid, err := p.AddRecord([]byte("this-is-record-000001"))
+	if err != nil {
+		t.Fatalf("[Page] adding record: %s", err)
+	}
+	err = f.WritePage(p)
+	if err != nil {
+		t.Fatalf("[file] write page: %s", err)
+	}
+	// log a second page as committed, but "crash"
+	// before it makes it to the data file
+	p2 := f.AllocatePage()
+	rid2, err := p2.AddRecord([]byte("this-is-record-000002"))
+	if err != nil {
+		t.Fatalf("[Page] adding record: %s", err)
+	}
+	err = f.wal.logPages(p2)
+	if err != nil {
+		t.Fatalf("[wal] log pages: %s", err)
+	}
+	crash(t, f)
+	// reopen, the second page should be redone
+	f, err = OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	defer f.Close()
+	if f.wal.size != 0 {
+		t.Errorf("[wal] expected log to be truncated, got size=%d", f.wal.size)
+	}
+	for _, id := range []*RecordID{rid, rid2} {
+		pg, err := f.ReadPage(id.PageID)
+		if err != nil {
+			t.Fatalf("[file] read page %d: %s", id.PageID, err)
+		}
+		rec, err := pg.GetRecord(id)
+		if err != nil {
+			t.Fatalf("[Page] getting record: %s", err)
+		}
+		if !bytes.HasPrefix(rec, []byte("this-is-record-")) {
+			t.Errorf("[Page] got unexpected record %q", rec)
+		}
+	}
+}
+
+func TestWriteAheadLog_UncommittedEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal-uncommitted.db")
+	f, err := OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	// write a page the normal way
+	p := f.AllocatePage()
+	rid, err := p.AddRecord([]byte("this-is-record-000001"))
+	if err != nil {
+		t.Fatalf("[Page] adding record: %s", err)
+	}
+	err = f.WritePage(p)
+	if err != nil {
+		t.Fatalf("[file] write page: %s", err)
+	}
+	// append a new image of the same page with no
+	// commit entry following it (an interrupted write)
+	err = p.DelRecord(rid)
+	if err != nil {
+		t.Fatalf("[Page] deleting record: %s", err)
+	}
+	encodePage(p)
+	_, err = f.wal.append(walEntryPage, p.PageID(), p.data)
+	if err != nil {
+		t.Fatalf("[wal] append: %s", err)
+	}
+	crash(t, f)
+	// reopen, the uncommitted image should be ignored
+	f, err = OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	defer f.Close()
+	pg, err := f.ReadPage(rid.PageID)
+	if err != nil {
+		t.Fatalf("[file] read page: %s", err)
+	}
+	_, err = pg.GetRecord(rid)
+	if err != nil {
+		t.Errorf("[Page] expected record to survive, got: %s", err)
+	}
+}
+
+func TestWriteAheadLog_FailedBatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal-failed.db")
+	f, err := OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	// write a page the normal way
+	p := f.AllocatePage()
+	rid, err := p.AddRecord([]byte("this-is-record-000001"))
+	if err != nil {
+		t.Fatalf("[Page] adding record: %s", err)
+	}
+	err = f.WritePage(p)
+	if err != nil {
+		t.Fatalf("[file] write page: %s", err)
+	}
+	size, lsn := f.wal.size, f.wal.lsn
+	// a batch that can not be written leaves the log as it was
+	fp := f.wal.fp
+	f.wal.fp, err = os.Open(fp.Name())
+	if err != nil {
+		t.Fatalf("[wal] open read-only: %s", err)
+	}
+	err = f.wal.logPages(p)
+	if err == nil {
+		t.Fatalf("[wal] expected logging to a read-only log to fail")
+	}
+	_ = f.wal.fp.Close()
+	f.wal.fp = fp
+	if f.wal.size != size || f.wal.lsn != lsn {
+		t.Fatalf("[wal] expected size=%d lsn=%d, got size=%d lsn=%d", size, lsn, f.wal.size, f.wal.lsn)
+	}
+	// and a batch that fails partway is cut back off of the log
+	err = p.DelRecord(rid)
+	if err != nil {
+		t.Fatalf("[Page] deleting record: %s", err)
+	}
+	encodePage(p)
+	_, err = f.wal.append(walEntryPage, p.PageID(), p.data)
+	if err != nil {
+		t.Fatalf("[wal] append: %s", err)
+	}
+	err = io.ErrShortWrite
+	f.wal.truncateOnError(size, lsn, &err)
+	// so it is not committed by the next batch
+	p2 := f.AllocatePage()
+	rid2, err := p2.AddRecord([]byte("this-is-record-000002"))
+	if err != nil {
+		t.Fatalf("[Page] adding record: %s", err)
+	}
+	err = f.wal.logPages(p2)
+	if err != nil {
+		t.Fatalf("[wal] log pages: %s", err)
+	}
+	crash(t, f)
+	f, err = OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	defer f.Close()
+	for _, id := range []*RecordID{rid, rid2} {
+		pg, err := f.ReadPage(id.PageID)
+		if err != nil {
+			t.Fatalf("[file] read page %d: %s", id.PageID, err)
+		}
+		_, err = pg.GetRecord(id)
+		if err != nil {
+			t.Errorf("[Page] expected record %v to survive, got: %s", id, err)
+		}
+	}
+}
+
+func TestWriteAheadLog_DeletePage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal-delete.db")
+	f, err := OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	// write a page the normal way
+	p := f.AllocatePage()
+	rid, err := p.AddRecord([]byte("this-is-record-000001"))
+	if err != nil {
+		t.Fatalf("[Page] adding record: %s", err)
+	}
+	err = f.WritePage(p)
+	if err != nil {
+		t.Fatalf("[file] write page: %s", err)
+	}
+	// delete it, and "crash" once it has made it to the data
+	// file, but before the log has been truncated
+	err = f.DeletePage(p.PageID())
+	if err != nil {
+		t.Fatalf("[file] delete page: %s", err)
+	}
+	err = f.store.flush()
+	if err != nil {
+		t.Fatalf("[file] flush: %s", err)
+	}
+	crash(t, f)
+	// reopen, replaying the log should not bring the record back
+	f, err = OpenPageManager(path)
+	if err != nil {
+		t.Fatalf("[file] open: %s", err)
+	}
+	defer f.Close()
+	pg, err := f.ReadPage(rid.PageID)
+	if err != nil {
+		t.Fatalf("[file] read page: %s", err)
+	}
+	if !pg.header.PageIsFree() {
+		t.Errorf("[file] expected page %d to be free", rid.PageID)
+	}
+	rec, err := pg.GetRecord(rid)
+	if err == nil {
+		t.Errorf("[Page] expected deleted record to be gone, got %q", rec)
+	}
+}
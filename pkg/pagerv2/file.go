@@ -5,6 +5,10 @@ import (
 	"path/filepath"
 )
 
+// OpenFile opens (or creates) the file located at the path provided for
+// reading and writing. It takes an exclusive lock on the file (see
+// lockFile), which is held until the file is closed. If the file is
+// already locked it returns ErrFileLocked.
 func OpenFile(path string) (*os.File, error) {
 	// sanitize path
 	path, err := filepath.Abs(path)
@@ -38,5 +42,34 @@ func OpenFile(path string) (*os.File, error) {
 	if err != nil {
 		return nil, err
 	}
+	// lock it, so nobody else can open it
+	err = lockFile(fp, false)
+	if err != nil {
+		_ = fp.Close()
+		return nil, err
+	}
+	return fp, nil
+}
+
+// OpenFileReadOnly opens an existing file located at the path provided
+// for reading only. It takes a shared lock on the file, so it can be open
+// read-only any number of times, but it can not be opened using OpenFile
+// until they have all been closed. If the file is already locked by
+// OpenFile it returns ErrFileLocked.
+func OpenFileReadOnly(path string) (*os.File, error) {
+	// sanitize path
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	err = lockFile(fp, true)
+	if err != nil {
+		_ = fp.Close()
+		return nil, err
+	}
 	return fp, nil
 }
@@ -5,9 +5,16 @@ import (
 )
 
 var (
-	ErrIllegalPageAccess = errors.New("illegal page access")
-	ErrRecordTooLarge    = errors.New("record too large")
-	ErrPageIsFull        = errors.New("page is full")
+	ErrIllegalPageAccess       = errors.New("illegal page access")
+	ErrRecordTooLarge          = errors.New("record too large")
+	ErrPageIsFull              = errors.New("page is full")
+	ErrInvalidRecordID         = errors.New("invalid record id")
+	ErrRecordHasBeenMarkedFree = errors.New("record has been marked free")
+	ErrBadPageSize             = errors.New("page size must be a power of two between 4 KB and 1 MB")
+	ErrPageSizeChanged         = errors.New("page size does not match the page size of the file")
+	ErrBadFileHeader           = errors.New("file header is missing or corrupt")
+	ErrPageChecksumMismatch    = errors.New("page checksum mismatch")
+	ErrFileLocked              = errors.New("file is locked, it is already open elsewhere")
 )
 
 /*
@@ -0,0 +1,44 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package pagerv2
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenFile_Lock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock.db")
+	fp, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("[pager] open: %s", err)
+	}
+	_, err = OpenFile(path)
+	if err != ErrFileLocked {
+		t.Fatalf("[pager] expected %q, got %v", ErrFileLocked, err)
+	}
+	_, err = OpenFileReadOnly(path)
+	if err != ErrFileLocked {
+		t.Fatalf("[pager] expected %q (read-only), got %v", ErrFileLocked, err)
+	}
+	err = fp.Close()
+	if err != nil {
+		t.Fatalf("[pager] close: %s", err)
+	}
+	// once closed, it can be opened read-only more than once
+	r1, err := OpenFileReadOnly(path)
+	if err != nil {
+		t.Fatalf("[pager] open read-only: %s", err)
+	}
+	defer r1.Close()
+	r2, err := OpenFileReadOnly(path)
+	if err != nil {
+		t.Fatalf("[pager] open read-only (again): %s", err)
+	}
+	defer r2.Close()
+	_, err = OpenFile(path)
+	if err != ErrFileLocked {
+		t.Fatalf("[pager] expected %q, got %v", ErrFileLocked, err)
+	}
+}
@@ -0,0 +1,115 @@
+package pagerv2
+
+import "github.com/cagnosolutions/pager/pkg/internal/slotted"
+
+// The slotted page layout used inside of the cached frames is the same
+// one used by pkg/pager, they share the codec in pkg/internal/slotted.
+// The checksum in the page header is kept up to date every time the
+// page is changed, and is checked every time the page is fetched.
+
+// page is a raw page sized []byte that points directly into a
+// frame held by the pager
+type page []byte
+
+// initPage initializes a fresh page in the page space provided and
+// encodes the header with the page id provided
+func initPage(p page, pid uint32) {
+	slotted.Init(p, pid)
+	slotted.SetChecksum(p)
+}
+
+// checksumOK reports whether the checksum in the page header
+// matches the page data
+func (p page) checksumOK() bool {
+	return slotted.ChecksumOK(p)
+}
+
+// isFresh reports whether the page has never had a header encoded
+// in it (a page with a header always has a non-zero upper bound)
+func (p page) isFresh() bool {
+	return slotted.FreeSpaceUpper(p) == 0
+}
+
+// freeSpace returns the free space left in the page
+func (p page) freeSpace() uint32 {
+	return slotted.FreeSpaceUpper(p) - slotted.FreeSpaceLower(p)
+}
+
+// addSlot reserves room for a record of the provided size, adding a
+// new slot to the page. It returns the slot number (which is also the
+// record id) of the newly added slot.
+func (p page) addSlot(recordSize uint32) (uint16, error) {
+	// check record to make sure it will fit
+	if int(recordSize) > slotted.MaxRecordSize(len(p)) {
+		return 0, ErrRecordTooLarge
+	}
+	_, slot := slotted.Layout(p)
+	if recordSize+uint32(slot) > p.freeSpace() {
+		return 0, ErrPageIsFull
+	}
+	// first we increment the slot count
+	slotNum := slotted.SlotCount(p)
+	slotted.SetSlotCount(p, slotNum+1)
+	// next, we raise the free space lower boundary because
+	// we are now adding a new slot
+	slotted.SetFreeSpaceLower(p, slotted.FreeSpaceLower(p)+uint32(slot))
+	// then, we must lower the free space upper bound because
+	// we are reserving room for the record data
+	upper := slotted.FreeSpaceUpper(p) - recordSize
+	slotted.SetFreeSpaceUpper(p, upper)
+	// finally, we write the new slot to the page
+	slotted.SetSlotID(p, int(slotNum), slotNum)
+	slotted.SetSlotStatus(p, int(slotNum), slotted.ItemStatusUsed)
+	slotted.SetSlotOffset(p, int(slotNum), upper)
+	slotted.SetSlotLength(p, int(slotNum), recordSize)
+	slotted.SetChecksum(p)
+	return slotNum, nil
+}
+
+// checkSlot makes sure the record id provided refers to a slot that is
+// currently in use
+func (p page) checkSlot(rid uint16) error {
+	if rid >= slotted.SlotCount(p) {
+		return ErrInvalidRecordID
+	}
+	if slotted.SlotStatus(p, int(rid)) == slotted.ItemStatusFree {
+		return ErrRecordHasBeenMarkedFree
+	}
+	return nil
+}
+
+// readRecord returns a copy of the record data for the provided record id
+func (p page) readRecord(rid uint16) ([]byte, error) {
+	err := p.checkSlot(rid)
+	if err != nil {
+		return nil, err
+	}
+	// get the record offsets for an easier time copying
+	beg := slotted.SlotOffset(p, int(rid))
+	end := beg + slotted.SlotLength(p, int(rid))
+	// create a new buffer to copy the record data into (so
+	// we are not returning a pointer into the frame, which
+	// would be unsafe once the page is evicted)
+	rec := make([]byte, end-beg)
+	copy(rec, p[beg:end])
+	return rec, nil
+}
+
+// writeRecord writes the record data into the slot for the provided record
+// id. The record must fit in the space the slot currently holds. If the
+// record is smaller, the slot is shrunk to the size of the record.
+func (p page) writeRecord(rec []byte, rid uint16) error {
+	err := p.checkSlot(rid)
+	if err != nil {
+		return err
+	}
+	if len(rec) > int(slotted.SlotLength(p, int(rid))) {
+		return ErrRecordTooLarge
+	}
+	// copy the record into the page, and update the slot length
+	beg := slotted.SlotOffset(p, int(rid))
+	copy(p[beg:], rec)
+	slotted.SetSlotLength(p, int(rid), uint32(len(rec)))
+	slotted.SetChecksum(p)
+	return nil
+}
@@ -1,41 +1,179 @@
 package pagerv2
 
 import (
+	"encoding/binary"
 	"io"
 	"os"
+
+	"github.com/cagnosolutions/pager/pkg/internal/slotted"
+	"github.com/cagnosolutions/pager/pkg/replacer"
 )
 
 const (
-	pageSize = 4 << 10
+	// DefaultPageSize is the page size used when one is not specified
+	DefaultPageSize = 4 << 10 // 4 KB
+	// MinPageSize is the smallest page size supported
+	MinPageSize = 4 << 10 // 4 KB
+	// MaxPageSize is the largest page size supported
+	MaxPageSize = 1 << 20 // 1 MB
+
+	// fileMagic identifies a file written by the pager
+	fileMagic = 0x32564750 // "PGV2"
+	// fileVersion is the current file format version
+	fileVersion = 1
+	// fileHeaderSize is the size of the encoded file header. The file
+	// header always takes up the first page sized block of the file.
+	fileHeaderSize = 12
 )
 
+/*
+	magic    uint32
+	version  uint16
+	_        uint16
+	pageSize uint32
+*/
+
+const defaultCacheSize = 8
+
+// frame is a cached page, held at off in the pager data
+type frame struct {
+	off   int64
+	dirty bool
+}
+
 type Pager struct {
 	file          *os.File
-	cache         *lru
+	table         map[uint32]*frame
+	replacer      replacer.Replacer
 	data          []byte
+	frames        []int64
+	numPages      uint32
 	usedNumPages  int
 	dirtyNumPages int
 	maxNumPages   int
+	pageSize      int
+	base          int64
 }
 
 func NewPager(path string, pages int) *Pager {
-	fp, err := OpenFile(path)
+	p, err := NewPagerWithPageSize(path, pages, 0)
 	if err != nil {
 		panic(err)
 	}
+	return p
+}
+
+// NewPagerWithPageSize opens (or creates) the file located at the path
+// provided, and returns a pager for it that caches up to the provided
+// number of pages. The page size is recorded in the file when it is
+// created, a page size of zero uses the page size recorded in an existing
+// file (see filePageSize). Otherwise, an existing file must match the page
+// size it was created with. Pages of 64 KB and larger use a wide page
+// format (with 32-bit item offsets and lengths).
+func NewPagerWithPageSize(path string, pages int, pageSize int) (*Pager, error) {
+	return NewPagerWithPolicy(path, pages, pageSize, replacer.PolicyLRU)
+}
+
+// NewPagerWithPolicy is like NewPagerWithPageSize, but the pages to evict
+// from the cache are picked using the replacement policy provided (see
+// replacer.Policy) instead of using LRU.
+func NewPagerWithPolicy(path string, pages int, pageSize int, policy replacer.Policy) (*Pager, error) {
+	if pageSize != 0 && !validPageSize(pageSize) {
+		return nil, ErrBadPageSize
+	}
+	fp, err := OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if pageSize == 0 {
+		pageSize, err = filePageSize(fp)
+		if err != nil {
+			_ = fp.Close()
+			return nil, err
+		}
+	}
+	if pages < 2 {
+		pages = defaultCacheSize
+	}
 	p := &Pager{
 		file:          fp,
-		cache:         newLRU(pages),
+		table:         make(map[uint32]*frame, pages),
+		replacer:      replacer.New(policy, pages),
 		data:          make([]byte, pageSize*pages),
+		frames:        make([]int64, 0, pages),
 		usedNumPages:  0,
 		dirtyNumPages: 0,
 		maxNumPages:   pages,
+		pageSize:      pageSize,
+		base:          int64(pageSize),
 	}
 	err = p.load()
 	if err != nil {
-		panic(err)
+		_ = fp.Close()
+		return nil, err
 	}
-	return p
+	return p, nil
+}
+
+// validPageSize reports whether the provided page size is supported
+func validPageSize(size int) bool {
+	return size >= MinPageSize && size <= MaxPageSize && size&(size-1) == 0
+}
+
+// filePageSize returns the page size recorded in the header of the file
+// provided. New files (and files written before the file header existed,
+// which start with page 0 instead) use the DefaultPageSize.
+func filePageSize(fp *os.File) (int, error) {
+	hdr := make([]byte, fileHeaderSize)
+	_, err := fp.ReadAt(hdr, 0)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	if binary.LittleEndian.Uint32(hdr[0:4]) != fileMagic {
+		return DefaultPageSize, nil
+	}
+	size := int(binary.LittleEndian.Uint32(hdr[8:12]))
+	if binary.LittleEndian.Uint16(hdr[4:6]) != fileVersion || !validPageSize(size) {
+		return 0, ErrBadFileHeader
+	}
+	return size, nil
+}
+
+// loadHeader reads and checks the file header. Files written before the
+// file header existed are left using the old layout (with no header).
+func (p *Pager) loadHeader() error {
+	hdr := make([]byte, fileHeaderSize)
+	_, err := p.file.ReadAt(hdr, 0)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if binary.LittleEndian.Uint32(hdr[0:4]) != fileMagic {
+		if p.pageSize != DefaultPageSize {
+			return ErrPageSizeChanged
+		}
+		p.base = 0
+		return nil
+	}
+	if binary.LittleEndian.Uint16(hdr[4:6]) != fileVersion {
+		return ErrBadFileHeader
+	}
+	if int(binary.LittleEndian.Uint32(hdr[8:12])) != p.pageSize {
+		return ErrPageSizeChanged
+	}
+	return nil
+}
+
+// writeHeader encodes and writes the file header
+func (p *Pager) writeHeader() error {
+	hdr := make([]byte, p.pageSize)
+	binary.LittleEndian.PutUint32(hdr[0:4], fileMagic)
+	binary.LittleEndian.PutUint16(hdr[4:6], fileVersion)
+	binary.LittleEndian.PutUint32(hdr[8:12], uint32(p.pageSize))
+	_, err := p.file.WriteAt(hdr, 0)
+	if err != nil {
+		return err
+	}
+	return p.file.Sync()
 }
 
 func (p *Pager) load() error {
@@ -44,34 +182,37 @@ func (p *Pager) load() error {
 	if err != nil {
 		return err
 	}
-	// if this is the first run and the file is empty, then we should
-	// initialize the cache for the first p.pages entries and return
-	if fi.Size() < 1 {
-		// add p.pages entries to the cache to utilize
-		for i := 0; i < p.maxNumPages; i++ {
-			p.cache.set(uint32(i), int64(i*pageSize))
-		}
-		// and then return
+	// if this is a new file, write a fresh header, otherwise
+	// check the existing one
+	if fi.Size() == 0 {
+		err = p.writeHeader()
+	} else {
+		err = p.loadHeader()
+	}
+	if err != nil {
+		return err
+	}
+	// calculate how many pages are in the file (a partial page at the
+	// end of the file still counts as a page)
+	size := int64(p.pageSize)
+	if fi.Size() > p.base {
+		p.numPages = uint32((fi.Size() - p.base + size - 1) / size)
+	}
+	// initialize the free frame set, we hand them out in order
+	for i := p.maxNumPages - 1; i >= 0; i-- {
+		p.frames = append(p.frames, int64(i*p.pageSize))
+	}
+	// if this is the first run and the file is empty, then we are done
+	if p.numPages == 0 {
 		return nil
 	}
-	// otherwise, there should be some pages we can load in (we are simply
-	// looking for a page offset count at this point)
-	for i := uint32(0); i < uint32(p.maxNumPages) && err != io.EOF; i++ {
-		// calculate current page offset
-		off := int64(i * pageSize)
-		// read page into pager
-		_, err := p.file.ReadAt(p.data[off:off+pageSize], off)
-		// check for an error
+	// otherwise, there should be some pages we can load in, so we will
+	// warm the cache up with the first maxNumPages pages in the file
+	for pid := uint32(0); pid < p.numPages && p.usedNumPages < p.maxNumPages; pid++ {
+		_, err = p.loadPage(pid)
 		if err != nil {
-			if err == io.EOF || err == io.ErrUnexpectedEOF {
-				break
-			}
 			return err
 		}
-		// add to cache
-		p.cache.set(i, off)
-		// update pager info
-		p.usedNumPages++
 	}
 	// done
 	return nil
@@ -79,93 +220,304 @@ func (p *Pager) load() error {
 
 // INDEXING STRUCTURE: https://go.dev/play/p/8lTKeR4fLYj
 
-func (p *Pager) evict(numPages int) {
-	// evict numPages and add to free set
+// pageOffset returns the offset of the page in the file, skipping
+// over the block holding the file header (if there is one)
+func (p *Pager) pageOffset(pid uint32) int64 {
+	return p.base + int64(pid)*int64(p.pageSize)
 }
 
-func (p *Pager) free() (uint32, int64) {
-	// return a free page
-	return 0, 0
+// PageSize returns the page size used by the file
+func (p *Pager) PageSize() int {
+	return p.pageSize
+}
+
+// evict evicts up to numPages pages from the cache and adds their frames
+// to the free set. The pages are picked by the replacement policy, and
+// any dirty pages are written back to the file before they are evicted.
+// It returns the number of pages evicted.
+func (p *Pager) evict(numPages int) (int, error) {
+	var evicted int
+	for ; evicted < numPages; evicted++ {
+		pid, ok := p.replacer.Victim()
+		if !ok {
+			// nothing left to evict
+			break
+		}
+		f := p.table[pid]
+		if f.dirty {
+			// write the page back before we let it go
+			err := p.flush(pid, f)
+			if err != nil {
+				// keep tracking the page, it is still cached
+				p.replacer.Pin(pid)
+				p.replacer.Unpin(pid)
+				return evicted, err
+			}
+		}
+		delete(p.table, pid)
+		p.frames = append(p.frames, f.off)
+		p.usedNumPages--
+	}
+	return evicted, nil
+}
+
+// free returns the offset of a free frame, evicting a page from the cache
+// to make room if there are no free frames left
+func (p *Pager) free() (int64, error) {
+	if len(p.frames) == 0 {
+		_, err := p.evict(1)
+		if err != nil {
+			return -1, err
+		}
+	}
+	// pop a free frame off the free set
+	off := p.frames[len(p.frames)-1]
+	p.frames = p.frames[:len(p.frames)-1]
+	return off, nil
+}
+
+// flush writes the page held in the frame back to the file and marks
+// the frame as clean. It does not sync the file.
+func (p *Pager) flush(pid uint32, f *frame) error {
+	_, err := p.file.WriteAt(p.data[f.off:f.off+int64(p.pageSize)], p.pageOffset(pid))
+	if err != nil {
+		return err
+	}
+	if f.dirty {
+		f.dirty = false
+		p.dirtyNumPages--
+	}
+	return nil
+}
+
+// cache adds the page held at off to the cache, and records an access
+// to it with the replacement policy
+func (p *Pager) cache(pid uint32, off int64, dirty bool) {
+	p.table[pid] = &frame{off: off, dirty: dirty}
+	p.touch(pid)
+}
+
+// touch records an access to a cached page with the replacement policy.
+// Pages are only in use for the length of a single call, so the page is
+// left up for eviction right away.
+func (p *Pager) touch(pid uint32) {
+	p.replacer.Pin(pid)
+	p.replacer.Unpin(pid)
+}
+
+// loadPage reads the page from the file into a free frame, caches it
+// and returns the frame offset (this counts as a cache miss)
+func (p *Pager) loadPage(pid uint32) (int64, error) {
+	// acquire a free frame
+	off, err := p.free()
+	if err != nil {
+		return -1, err
+	}
+	// read the page off the disk into the frame, a short page at the
+	// end of the file gets filled out with zeros
+	frame := p.data[off : off+int64(p.pageSize)]
+	n, err := p.file.ReadAt(frame, p.pageOffset(pid))
+	if err != nil && err != io.EOF {
+		p.frames = append(p.frames, off)
+		return -1, err
+	}
+	for i := n; i < p.pageSize; i++ {
+		frame[i] = 0
+	}
+	// next, cache the newly read page
+	p.cache(pid, off, false)
+	p.usedNumPages++
+	return off, nil
+}
+
+// fetch returns the frame offset of the page, loading it from the file
+// if it is not already in the cache
+func (p *Pager) fetch(pid uint32) (int64, error) {
+	// make sure the page exists
+	if pid >= p.numPages {
+		return -1, ErrIllegalPageAccess
+	}
+	// look for the page in memory
+	f, found := p.table[pid]
+	if found {
+		// if we find it then return it (this counts as a cache hit)
+		p.touch(pid)
+		return f.off, nil
+	}
+	// since we did not find the page in memory, we need to attempt to
+	// read it off the disk and cache it
+	return p.loadPage(pid)
+}
+
+// markDirty marks the cached page as dirty
+func (p *Pager) markDirty(pid uint32) {
+	f := p.table[pid]
+	if f != nil && !f.dirty {
+		f.dirty = true
+		p.dirtyNumPages++
+	}
 }
 
 func (p *Pager) getPage(off int64) ([]byte, error) {
 	// error check offset
-	if off > int64(len(p.data)) || off < 0 {
+	size := int64(p.pageSize)
+	if off+size > int64(len(p.data)) || off < 0 {
 		// encountered error, return nil and illegal access
 		return nil, ErrIllegalPageAccess
 	}
 	// page align offset (in case its off)
-	off = off&^pageSize - 1
+	off = off &^ (size - 1)
 	// return page, and nil error
-	return p.data[off : off+pageSize], nil
+	return p.data[off : off+size], nil
 }
 
 func (p *Pager) Read(pid uint32) ([]byte, error) {
-	// look for the page in memory
-	off, found := p.cache.get(pid)
-	if found {
-		// if we find it then return it (this counts as a cache hit)
-		return p.getPage(off)
-	}
-	// since we did not find the page cache in memory, we should check to
-	// make sure our page buffer is not full
-	if p.usedNumPages == p.maxNumPages {
-		// page cache is full, check the dirty page count
-		if p.dirtyNumPages == p.maxNumPages {
-			// we are full and all the pages are dirty which means we need
-			// to flush our pages
-			err := p.Sync()
-			if err != nil {
-				return nil, err
-			}
-		}
-		// we may have had to sync, but either way we have to evict
-		p.evict(4)
-	}
-	// acquire a free page
-	pid, off = p.free()
-	// and since we did not find it in the cache, we need to attempt to
-	// read it off the disk and cache it (this counts as a cache miss)
-	_, err := p.file.ReadAt(p.data[off:off+pageSize], off)
+	// get the page, from memory or from the disk
+	off, err := p.fetch(pid)
 	if err != nil {
 		return nil, err
 	}
-	// next, cache the newly read page
-	p.cache.set(pid, off)
 	// and finally, return the page data
 	return p.getPage(off)
 }
 
 func (p *Pager) Write(d []byte, pid uint32) error {
-	// 1) look for page in memory
-	// 2) if we find it proceed, and if not we need to figure out what to do
-	// 3) check to make sure the page has room for the record, and if not
-	//    then return page is full or record too large error
-	// 4) copy the data to the page
+	// check to make sure the data will fit in the page
+	if len(d) > p.pageSize {
+		return ErrRecordTooLarge
+	}
+	// get the page, from memory or from the disk
+	off, err := p.fetch(pid)
+	if err != nil {
+		return err
+	}
+	// copy the data to the page
+	pg := page(p.data[off : off+int64(p.pageSize)])
+	copy(pg, d)
+	// if the data carries a slotted page header, refresh the checksum
+	// so the record calls do not mistake the write for corruption
+	if !pg.isFresh() {
+		slotted.SetChecksum(pg)
+	}
+	// and mark it dirty
+	p.markDirty(pid)
 	return nil
 }
 
 func (p *Pager) GetFreePageID() uint32 {
-	// TODO implement me
-	panic("implement me")
+	// allocate a new page at the end of the file
+	pid := p.numPages
+	p.numPages++
+	// and get a frame to hold it, evicting if we need to
+	off, err := p.free()
+	if err != nil {
+		panic(err)
+	}
+	// zero out the frame, initialize the page header, and cache
+	// it as dirty so it gets written to the file on the next call
+	// to sync
+	end := off + int64(p.pageSize)
+	for i := off; i < end; i++ {
+		p.data[i] = 0
+	}
+	initPage(p.data[off:end], pid)
+	p.cache(pid, off, true)
+	p.usedNumPages++
+	p.dirtyNumPages++
+	return pid
+}
+
+// getSlottedPage returns the page (from memory or from the disk) for use
+// with the record level api. A page that has never been initialized gets
+// a fresh header encoded in it, any other page must pass the checksum.
+func (p *Pager) getSlottedPage(pid uint32) (page, error) {
+	// get the page, from memory or from the disk
+	off, err := p.fetch(pid)
+	if err != nil {
+		return nil, err
+	}
+	pg := page(p.data[off : off+int64(p.pageSize)])
+	// initialize the page if need be
+	if pg.isFresh() {
+		initPage(pg, pid)
+		p.markDirty(pid)
+		return pg, nil
+	}
+	// otherwise make sure it has not been corrupted
+	if !pg.checksumOK() {
+		return nil, ErrPageChecksumMismatch
+	}
+	return pg, nil
 }
 
 func (p *Pager) GetFreeRecordID(pid uint32, rsize int) (uint16, error) {
-	// TODO implement me
-	panic("implement me")
+	// check to make sure the record will fit in a page
+	if rsize < 0 || rsize > slotted.MaxRecordSize(p.pageSize) {
+		return 0, ErrRecordTooLarge
+	}
+	// get the page
+	pg, err := p.getSlottedPage(pid)
+	if err != nil {
+		return 0, err
+	}
+	// reserve room for the record in a new slot
+	rid, err := pg.addSlot(uint32(rsize))
+	if err != nil {
+		return 0, err
+	}
+	// and mark the page dirty
+	p.markDirty(pid)
+	return rid, nil
 }
 
 func (p *Pager) ReadRecord(pid uint32, rid uint16) ([]byte, error) {
-	// TODO implement me
-	panic("implement me")
+	// get the page
+	pg, err := p.getSlottedPage(pid)
+	if err != nil {
+		return nil, err
+	}
+	// and return a copy of the record
+	return pg.readRecord(rid)
 }
 
 func (p *Pager) WriteRecord(r []byte, pid uint32, rid uint16) error {
-	// TODO implement me
-	panic("implement me")
+	// get the page
+	pg, err := p.getSlottedPage(pid)
+	if err != nil {
+		return err
+	}
+	// write the record into the slot
+	err = pg.writeRecord(r, rid)
+	if err != nil {
+		return err
+	}
+	// and mark the page dirty
+	p.markDirty(pid)
+	return nil
 }
 
 func (p *Pager) Sync() error {
-	// TODO implement me
-	panic("implement me")
+	// write every dirty page back to the file
+	if p.dirtyNumPages > 0 {
+		for pid, f := range p.table {
+			if !f.dirty {
+				continue
+			}
+			err := p.flush(pid, f)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	// and make sure it all makes it to stable storage
+	return p.file.Sync()
+}
+
+// Close syncs the pager and closes the underlying file
+func (p *Pager) Close() error {
+	err := p.Sync()
+	if err != nil {
+		return err
+	}
+	return p.file.Close()
 }
@@ -0,0 +1,281 @@
+package pagerv2
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/cagnosolutions/pager/pkg/internal/slotted"
+	"github.com/cagnosolutions/pager/pkg/replacer"
+)
+
+func pageData(pid uint32) []byte {
+	return []byte(fmt.Sprintf("this-is-page-%.6d", pid))
+}
+
+func TestPager_EvictAndWriteBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pager.db")
+	p := NewPager(path, 4)
+	// write more pages than will fit in the cache
+	var pids []uint32
+	for i := 0; i < 16; i++ {
+		pid := p.GetFreePageID()
+		err := p.Write(pageData(pid), pid)
+		if err != nil {
+			t.Fatalf("[pager] write page %d: %s", pid, err)
+		}
+		pids = append(pids, pid)
+		if p.usedNumPages > p.maxNumPages {
+			t.Fatalf("[pager] cache over capacity: %d > %d", p.usedNumPages, p.maxNumPages)
+		}
+	}
+	// evicted pages should have been written back, so
+	// reading them should pull them back off the disk
+	for _, pid := range pids {
+		d, err := p.Read(pid)
+		if err != nil {
+			t.Fatalf("[pager] read page %d: %s", pid, err)
+		}
+		if !bytes.HasPrefix(d, pageData(pid)) {
+			t.Errorf("[pager] page %d: got %q", pid, d[:32])
+		}
+	}
+	// reading past the end of the file is illegal
+	_, err := p.Read(uint32(len(pids)))
+	if err != ErrIllegalPageAccess {
+		t.Errorf("[pager] expected %v, got %v", ErrIllegalPageAccess, err)
+	}
+	err = p.Close()
+	if err != nil {
+		t.Fatalf("[pager] close: %s", err)
+	}
+	// reopen, everything should have been persisted
+	p = NewPager(path, 4)
+	defer p.Close()
+	for _, pid := range pids {
+		d, err := p.Read(pid)
+		if err != nil {
+			t.Fatalf("[pager] read page %d: %s", pid, err)
+		}
+		if !bytes.HasPrefix(d, pageData(pid)) {
+			t.Errorf("[pager] page %d after reopen: got %q", pid, d[:32])
+		}
+	}
+	if p.dirtyNumPages != 0 {
+		t.Errorf("[pager] expected no dirty pages, got %d", p.dirtyNumPages)
+	}
+}
+
+func TestPager_Records(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.db")
+	p := NewPager(path, 2)
+	// fill up a few pages with records, going through more
+	// pages than the cache can hold at once
+	type recordID struct {
+		pid uint32
+		rid uint16
+	}
+	var ids []recordID
+	for i := 0; i < 3; i++ {
+		pid := p.GetFreePageID()
+		for {
+			rec := []byte(fmt.Sprintf("record-%.3d-%.6d", pid, len(ids)))
+			rid, err := p.GetFreeRecordID(pid, len(rec))
+			if err == ErrPageIsFull {
+				break
+			}
+			if err != nil {
+				t.Fatalf("[pager] get free record id: %s", err)
+			}
+			err = p.WriteRecord(rec, pid, rid)
+			if err != nil {
+				t.Fatalf("[pager] write record: %s", err)
+			}
+			ids = append(ids, recordID{pid, rid})
+		}
+	}
+	check := func(p *Pager) {
+		for i, id := range ids {
+			rec, err := p.ReadRecord(id.pid, id.rid)
+			if err != nil {
+				t.Fatalf("[pager] read record (%d, %d): %s", id.pid, id.rid, err)
+			}
+			want := fmt.Sprintf("record-%.3d-%.6d", id.pid, i)
+			if string(rec) != want {
+				t.Errorf("[pager] record (%d, %d): expected %q, got %q", id.pid, id.rid, want, rec)
+			}
+		}
+	}
+	check(p)
+	// records that are too large, or do not exist, are errors
+	_, err := p.GetFreeRecordID(0, p.PageSize())
+	if err != ErrRecordTooLarge {
+		t.Errorf("[pager] expected %v, got %v", ErrRecordTooLarge, err)
+	}
+	err = p.WriteRecord(make([]byte, 64), ids[0].pid, ids[0].rid)
+	if err != ErrRecordTooLarge {
+		t.Errorf("[pager] expected %v, got %v", ErrRecordTooLarge, err)
+	}
+	_, err = p.ReadRecord(0, 0xffff)
+	if err != ErrInvalidRecordID {
+		t.Errorf("[pager] expected %v, got %v", ErrInvalidRecordID, err)
+	}
+	err = p.Close()
+	if err != nil {
+		t.Fatalf("[pager] close: %s", err)
+	}
+	// reopen, the records should have been persisted
+	p = NewPager(path, 2)
+	defer p.Close()
+	check(p)
+}
+
+func TestPager_Policies(t *testing.T) {
+	for _, policy := range replacer.Policies {
+		t.Run(policy.String(), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.db")
+			p, err := NewPagerWithPolicy(path, 3, 0, policy)
+			if err != nil {
+				t.Fatalf("[pager] open: %s", err)
+			}
+			defer p.Close()
+			// write more pages than will fit in the cache, going
+			// back over the earlier ones every so often
+			var pids []uint32
+			for i := 0; i < 32; i++ {
+				pid := p.GetFreePageID()
+				err = p.Write(pageData(pid), pid)
+				if err != nil {
+					t.Fatalf("[pager] write page %d: %s", pid, err)
+				}
+				pids = append(pids, pid)
+				if p.usedNumPages > p.maxNumPages {
+					t.Fatalf("[pager] cache over capacity: %d > %d", p.usedNumPages, p.maxNumPages)
+				}
+				_, err = p.Read(pids[i/2])
+				if err != nil {
+					t.Fatalf("[pager] read page %d: %s", pids[i/2], err)
+				}
+			}
+			// every page should have made it back to the disk
+			for _, pid := range pids {
+				d, err := p.Read(pid)
+				if err != nil {
+					t.Fatalf("[pager] read page %d: %s", pid, err)
+				}
+				if !bytes.HasPrefix(d, pageData(pid)) {
+					t.Errorf("[pager] page %d: got %q", pid, d[:32])
+				}
+			}
+		})
+	}
+}
+
+func TestPager_RecordChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checksum.db")
+	p := NewPager(path, 2)
+	defer p.Close()
+	pid := p.GetFreePageID()
+	rec := []byte("this-is-record-000001")
+	rid, err := p.GetFreeRecordID(pid, len(rec))
+	if err != nil {
+		t.Fatalf("[pager] get free record id: %s", err)
+	}
+	err = p.WriteRecord(rec, pid, rid)
+	if err != nil {
+		t.Fatalf("[pager] write record: %s", err)
+	}
+	// the page should carry a real checksum, the same one pkg/pager uses
+	d, err := p.Read(pid)
+	if err != nil {
+		t.Fatalf("[pager] read page %d: %s", pid, err)
+	}
+	if !slotted.ChecksumOK(d) || slotted.Checksum(d) == 0 {
+		t.Fatalf("[pager] page %d: bad checksum", pid)
+	}
+	// and a corrupt page should be caught
+	d[len(d)-1] ^= 0xff
+	_, err = p.ReadRecord(pid, rid)
+	if err != ErrPageChecksumMismatch {
+		t.Fatalf("[pager] expected %v, got %v", ErrPageChecksumMismatch, err)
+	}
+}
+
+func TestPager_WriteThenReadRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "write.db")
+	p := NewPager(path, 2)
+	defer p.Close()
+	pid := p.GetFreePageID()
+	rec := []byte("this-is-record-000001")
+	rid, err := p.GetFreeRecordID(pid, len(rec))
+	if err != nil {
+		t.Fatalf("[pager] get free record id: %s", err)
+	}
+	err = p.WriteRecord(rec, pid, rid)
+	if err != nil {
+		t.Fatalf("[pager] write record: %s", err)
+	}
+	// change the record in a copy of the page, and write it back raw
+	d, err := p.Read(pid)
+	if err != nil {
+		t.Fatalf("[pager] read page %d: %s", pid, err)
+	}
+	d = append([]byte(nil), d...)
+	off := slotted.SlotOffset(d, int(rid))
+	copy(d[off:], "this-is-record-000002")
+	err = p.Write(d, pid)
+	if err != nil {
+		t.Fatalf("[pager] write page %d: %s", pid, err)
+	}
+	// the record calls should see the new data, not a bad checksum
+	got, err := p.ReadRecord(pid, rid)
+	if err != nil {
+		t.Fatalf("[pager] read record: %s", err)
+	}
+	if string(got) != "this-is-record-000002" {
+		t.Errorf("[pager] read record: got %q", got)
+	}
+}
+
+func TestPager_PageSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pagesize.db")
+	_, err := NewPagerWithPageSize(path, 4, 3000)
+	if err != ErrBadPageSize {
+		t.Fatalf("[pager] expected %v, got %v", ErrBadPageSize, err)
+	}
+	// wide pages can hold records larger than 64 KB
+	p, err := NewPagerWithPageSize(path, 4, 128<<10)
+	if err != nil {
+		t.Fatalf("[pager] open: %s", err)
+	}
+	rec := bytes.Repeat([]byte("large-record"), 80<<10/12)
+	pid := p.GetFreePageID()
+	rid, err := p.GetFreeRecordID(pid, len(rec))
+	if err != nil {
+		t.Fatalf("[pager] get free record id: %s", err)
+	}
+	err = p.WriteRecord(rec, pid, rid)
+	if err != nil {
+		t.Fatalf("[pager] write record: %s", err)
+	}
+	err = p.Close()
+	if err != nil {
+		t.Fatalf("[pager] close: %s", err)
+	}
+	// the page size is recorded in the file
+	_, err = NewPagerWithPageSize(path, 4, 8<<10)
+	if err != ErrPageSizeChanged {
+		t.Fatalf("[pager] expected %v, got %v", ErrPageSizeChanged, err)
+	}
+	// and is used when a page size is not given
+	p = NewPager(path, 4)
+	defer p.Close()
+	if p.pageSize != 128<<10 {
+		t.Fatalf("[pager] expected page size %d, got %d", 128<<10, p.pageSize)
+	}
+	got, err := p.ReadRecord(pid, rid)
+	if err != nil || !bytes.Equal(got, rec) {
+		t.Fatalf("[pager] read record: got len=%d, %v", len(got), err)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !pagerdebug
+// +build !pagerdebug
+
+package pagerv3
+
+// borrowState tracks the views borrowed from a page (see Page.Borrow).
+// Outside of debug builds nothing is tracked, so borrowing is free.
+type borrowState struct{}
+
+// release is called when a page is no longer pinned, it returns the
+// buffer the page should use from now on
+func (b *borrowState) release(data []byte) []byte {
+	return data
+}
+
+// check is called before a page is pinned again (or reset)
+func (b *borrowState) check() {}
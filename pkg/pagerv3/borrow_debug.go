@@ -0,0 +1,41 @@
+//go:build pagerdebug
+// +build pagerdebug
+
+package pagerv3
+
+// poisonByte is used to fill the page buffers that were unpinned
+const poisonByte = 0xdb
+
+// borrowState tracks the views borrowed from a page (see Page.Borrow).
+// In debug builds, when a page is unpinned it moves to a fresh buffer
+// and the old buffer (which any borrowed views still point into) is
+// filled with poison. Reading through a stale view returns poison, and
+// writing through one is caught the next time the page is pinned.
+type borrowState struct {
+	poisoned [][]byte
+}
+
+// release is called when a page is no longer pinned, it returns the
+// buffer the page should use from now on
+func (b *borrowState) release(data []byte) []byte {
+	fresh := make([]byte, len(data))
+	copy(fresh, data)
+	for i := range data {
+		data[i] = poisonByte
+	}
+	b.poisoned = append(b.poisoned, data)
+	return fresh
+}
+
+// check is called before a page is pinned again (or reset), it panics
+// if anything was written to the buffers that were poisoned
+func (b *borrowState) check() {
+	for _, data := range b.poisoned {
+		for i := range data {
+			if data[i] != poisonByte {
+				panic(ErrUseAfterUnpin)
+			}
+		}
+	}
+	b.poisoned = b.poisoned[:0]
+}
@@ -0,0 +1,200 @@
+package pagerv3
+
+import (
+	"github.com/cagnosolutions/pager/pkg/replacer"
+)
+
+// make sure BufferPool satisfies the Manager interface
+var _ Manager = (*BufferPool)(nil)
+
+// BufferPool is a fixed size pool of page frames sitting on top of a
+// DiskManager. Pages are pinned to a frame while they are in use and
+// only unpinned frames are considered for eviction.
+type BufferPool struct {
+	disk      *DiskManager
+	pages     []*Page
+	replacer  replacer.Replacer
+	freeList  []FrameID
+	pageTable map[PageID]FrameID
+}
+
+// NewBufferPool creates and returns a new buffer pool holding size
+// frames, reading and writing pages using the provided DiskManager.
+// It uses the clock replacement policy.
+func NewBufferPool(disk *DiskManager, size int) *BufferPool {
+	return NewBufferPoolWithReplacer(disk, size, replacer.NewClock(size))
+}
+
+// NewBufferPoolWithReplacer creates and returns a new buffer pool
+// holding size frames, reading and writing pages using the provided
+// DiskManager and evicting pages using the provided replacer
+func NewBufferPoolWithReplacer(disk *DiskManager, size int, r replacer.Replacer) *BufferPool {
+	freeList := make([]FrameID, size)
+	for i := range freeList {
+		freeList[i] = FrameID(i)
+	}
+	return &BufferPool{
+		disk:      disk,
+		pages:     make([]*Page, size),
+		replacer:  r,
+		freeList:  freeList,
+		pageTable: make(map[PageID]FrameID),
+	}
+}
+
+// NewPage allocates a new page in the page buffer pool with help from
+// the disk manager and pins it to a frame
+func (bp *BufferPool) NewPage() (*Page, error) {
+	// get a frame to hold the page
+	fid, err := bp.acquireFrame()
+	if err != nil {
+		return nil, err
+	}
+	// allocate a new page
+	pid, err := bp.disk.AllocatePage()
+	if err != nil {
+		bp.freeList = append(bp.freeList, fid)
+		return nil, err
+	}
+	page := newPage(pid, bp.disk.PageSize(), 1, true)
+	// and pin it to the frame
+	bp.pageTable[pid] = fid
+	bp.pages[fid] = page
+	bp.replacer.Pin(uint32(pid))
+	return page, nil
+}
+
+// FetchPage fetches the requested page from the page buffer
+func (bp *BufferPool) FetchPage(pid PageID) (*Page, error) {
+	// if it is in the buffer pool, pin it and return it
+	if fid, ok := bp.pageTable[pid]; ok {
+		page := bp.pages[fid]
+		page.incPinCount()
+		bp.replacer.Pin(uint32(pid))
+		return page, nil
+	}
+	// otherwise, get a frame to hold the page
+	fid, err := bp.acquireFrame()
+	if err != nil {
+		return nil, err
+	}
+	// and read it in from the disk
+	page, err := bp.disk.ReadPage(pid)
+	if err != nil {
+		bp.freeList = append(bp.freeList, fid)
+		return nil, err
+	}
+	page.pinCount = 1
+	bp.pageTable[pid] = fid
+	bp.pages[fid] = page
+	bp.replacer.Pin(uint32(pid))
+	return page, nil
+}
+
+// UnpinPage unpins the target page from the page buffer
+func (bp *BufferPool) UnpinPage(pid PageID, isDirty bool) error {
+	fid, ok := bp.pageTable[pid]
+	if !ok {
+		return ErrPageNotFound
+	}
+	page := bp.pages[fid]
+	if page.pinCount <= 0 {
+		return ErrPageNotPinned
+	}
+	page.decPinCount()
+	if page.pinCount == 0 {
+		bp.replacer.Unpin(uint32(pid))
+	}
+	if isDirty {
+		page.isDirty = true
+	}
+	return nil
+}
+
+// FlushPage flushes the target page to disk
+func (bp *BufferPool) FlushPage(pid PageID) error {
+	fid, ok := bp.pageTable[pid]
+	if !ok {
+		return ErrPageNotFound
+	}
+	page := bp.pages[fid]
+	err := bp.disk.WritePage(page)
+	if err != nil {
+		return err
+	}
+	page.isDirty = false
+	return nil
+}
+
+// FlushAllPages flushes every dirty page in the buffer pool to disk
+// and then syncs the disk
+func (bp *BufferPool) FlushAllPages() error {
+	for pid, fid := range bp.pageTable {
+		if !bp.pages[fid].isDirty {
+			continue
+		}
+		err := bp.FlushPage(pid)
+		if err != nil {
+			return err
+		}
+	}
+	return bp.disk.Sync()
+}
+
+// DeletePage deletes a page from the buffer pool and the disk. A page
+// that is still pinned cannot be deleted.
+func (bp *BufferPool) DeletePage(pid PageID) error {
+	if fid, ok := bp.pageTable[pid]; ok {
+		page := bp.pages[fid]
+		if page.pinCount > 0 {
+			return ErrPageIsPinned
+		}
+		// remove it from the replacer and free up the frame
+		bp.replacer.Remove(uint32(pid))
+		delete(bp.pageTable, pid)
+		page.reset()
+		bp.pages[fid] = nil
+		bp.freeList = append(bp.freeList, fid)
+	}
+	return bp.disk.DeallocatePage(pid)
+}
+
+// GetFrameID returns a frame ID from the free list, or by using the
+// replacement policy if the free list is empty
+func (bp *BufferPool) GetFrameID() (FrameID, bool, error) {
+	if len(bp.freeList) > 0 {
+		fid := bp.freeList[0]
+		bp.freeList = bp.freeList[1:]
+		return fid, true, nil
+	}
+	pid, ok := bp.replacer.Victim()
+	if !ok {
+		return 0, false, ErrAllFramesPinned
+	}
+	return bp.pageTable[PageID(pid)], false, nil
+}
+
+// acquireFrame gets a frame ID (using GetFrameID) and, if the frame
+// was victimized, writes the current page out (if it is dirty) and
+// removes it from the page table so the frame can be reused
+func (bp *BufferPool) acquireFrame() (FrameID, error) {
+	fid, fromFreeList, err := bp.GetFrameID()
+	if err != nil {
+		return 0, err
+	}
+	if !fromFreeList {
+		// remove page from current frame
+		current := bp.pages[fid]
+		if current != nil {
+			if current.isDirty {
+				err = bp.disk.WritePage(current)
+				if err != nil {
+					bp.replacer.Unpin(uint32(current.id))
+					return 0, err
+				}
+			}
+			delete(bp.pageTable, current.id)
+		}
+	}
+	return fid, nil
+}
@@ -0,0 +1,158 @@
+package pagerv3
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/cagnosolutions/pager/pkg/replacer"
+)
+
+func openBufferPool(t *testing.T, path string, size int) (*BufferPool, *DiskManager) {
+	disk, err := NewDiskManager(path, 0)
+	if err != nil {
+		t.Fatalf("opening disk manager: %s", err)
+	}
+	return NewBufferPool(disk, size), disk
+}
+
+func TestBufferPool_AllFramesPinned(t *testing.T) {
+	bp, disk := openBufferPool(t, filepath.Join(t.TempDir(), "pinned.db"), 4)
+	defer disk.Close()
+	var pages []*Page
+	for i := 0; i < 4; i++ {
+		pg, err := bp.NewPage()
+		if err != nil {
+			t.Fatalf("new page: %s", err)
+		}
+		pages = append(pages, pg)
+	}
+	// every frame is pinned, so we should get an error
+	_, err := bp.NewPage()
+	if err != ErrAllFramesPinned {
+		t.Fatalf("expected %v, got %v", ErrAllFramesPinned, err)
+	}
+	// unpin one, and we should be able to allocate again
+	err = bp.UnpinPage(pages[0].ID(), true)
+	if err != nil {
+		t.Fatalf("unpin page: %s", err)
+	}
+	_, err = bp.NewPage()
+	if err != nil {
+		t.Fatalf("new page after unpin: %s", err)
+	}
+}
+
+func TestBufferPool_EvictAndFetch(t *testing.T) {
+	for _, policy := range replacer.Policies {
+		t.Run(policy.String(), func(t *testing.T) {
+			testBufferPoolEvictAndFetch(t, policy)
+		})
+	}
+}
+
+func testBufferPoolEvictAndFetch(t *testing.T, policy replacer.Policy) {
+	path := filepath.Join(t.TempDir(), "evict.db")
+	bp, disk := openBufferPool(t, path, 4)
+	bp.replacer = replacer.New(policy, 4)
+	// write more pages than we have frames
+	var pids []PageID
+	for i := 0; i < 16; i++ {
+		pg, err := bp.NewPage()
+		if err != nil {
+			t.Fatalf("new page: %s", err)
+		}
+		copy(pg.Data(), fmt.Sprintf("page-%.4d", i))
+		pids = append(pids, pg.ID())
+		err = bp.UnpinPage(pg.ID(), true)
+		if err != nil {
+			t.Fatalf("unpin page: %s", err)
+		}
+	}
+	err := bp.FlushAllPages()
+	if err != nil {
+		t.Fatalf("flush all pages: %s", err)
+	}
+	err = disk.Close()
+	if err != nil {
+		t.Fatalf("close: %s", err)
+	}
+	// reopen and read them all back
+	bp, disk = openBufferPool(t, path, 4)
+	bp.replacer = replacer.New(policy, 4)
+	defer disk.Close()
+	for i, pid := range pids {
+		pg, err := bp.FetchPage(pid)
+		if err != nil {
+			t.Fatalf("fetch page %d: %s", pid, err)
+		}
+		want := []byte(fmt.Sprintf("page-%.4d", i))
+		if !bytes.HasPrefix(pg.Data(), want) {
+			t.Errorf("fetch page %d: expected %q, got %q", pid, want, pg.Data()[:len(want)])
+		}
+		err = bp.UnpinPage(pid, false)
+		if err != nil {
+			t.Fatalf("unpin page: %s", err)
+		}
+	}
+}
+
+func TestBufferPool_DeletePinnedPage(t *testing.T) {
+	bp, disk := openBufferPool(t, filepath.Join(t.TempDir(), "delete.db"), 2)
+	defer disk.Close()
+	pg, err := bp.NewPage()
+	if err != nil {
+		t.Fatalf("new page: %s", err)
+	}
+	err = bp.DeletePage(pg.ID())
+	if err != ErrPageIsPinned {
+		t.Fatalf("expected %v, got %v", ErrPageIsPinned, err)
+	}
+	err = bp.UnpinPage(pg.ID(), false)
+	if err != nil {
+		t.Fatalf("unpin page: %s", err)
+	}
+	err = bp.DeletePage(pg.ID())
+	if err != nil {
+		t.Fatalf("delete page: %s", err)
+	}
+}
+
+func TestBufferPool_Borrow(t *testing.T) {
+	bp, disk := openBufferPool(t, filepath.Join(t.TempDir(), "borrow.db"), 2)
+	defer disk.Close()
+	pg, err := bp.NewPage()
+	if err != nil {
+		t.Fatalf("new page: %s", err)
+	}
+	copy(pg.Data(), "borrowed-record")
+	// the view should point straight into the page
+	b, err := pg.Borrow(0, 8)
+	if err != nil {
+		t.Fatalf("borrow: %s", err)
+	}
+	if string(b) != "borrowed" {
+		t.Errorf("borrow: expected %q, got %q", "borrowed", b)
+	}
+	copy(pg.Data(), "BORROWED")
+	if string(b) != "BORROWED" {
+		t.Errorf("borrow: expected the view to see the change, got %q", b)
+	}
+	if cap(b) != 8 {
+		t.Errorf("borrow: expected the view to be capped at 8, got %d", cap(b))
+	}
+	_, err = pg.Borrow(len(pg.Data())-4, 8)
+	if err != ErrOutOfBounds {
+		t.Errorf("expected %v, got %v", ErrOutOfBounds, err)
+	}
+	// once unpinned, nothing can be borrowed
+	err = bp.UnpinPage(pg.ID(), true)
+	if err != nil {
+		t.Fatalf("unpin page: %s", err)
+	}
+	_, err = pg.Borrow(0, 8)
+	if err != ErrPageNotPinned {
+		t.Errorf("expected %v, got %v", ErrPageNotPinned, err)
+	}
+}
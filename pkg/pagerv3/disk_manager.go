@@ -1,53 +1,274 @@
 package pagerv3
 
 import (
-	"errors"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
 )
 
-// DiskMaxNumPages sets the disk capacity
-const DiskMaxNumPages = 15
+const (
+	// fileMagic identifies a file written by the DiskManager
+	fileMagic = 0x50475633 // "PGV3"
+	// fileVersion is the current file format version
+	fileVersion = 1
+	// fileHeaderSize is the size of the encoded file header. The file
+	// header always takes up the first page sized block of the file.
+	fileHeaderSize = 20
+	// nilPageID marks the end of the free list
+	nilPageID = ^PageID(0)
+)
+
+/*
+	magic    uint32
+	version  uint16
+	_        uint16
+	pageSize uint32
+	count    uint32
+	freeHead uint32
+*/
 
-// DiskManager is responsible for interacting with disk
+// DiskManager is responsible for interacting with disk. It maps each
+// PageID to an offset in the underlying file, grows the file as pages
+// are allocated and keeps a persistent free list of deallocated pages
+// (chained through the first four bytes of each free page) so they
+// can be reused.
 type DiskManager struct {
-	pages map[*PageID]Page
-	// tracks the number of pages. -1 indicates that there
-	// is no page, and the next to be allocates is 0
-	count int
+	fp       *os.File
+	pageSize int
+	// tracks the number of pages allocated in the file, the
+	// next page to be allocated (if the free list is empty)
+	// is count
+	count    int
+	freeHead PageID
+	free     map[PageID]bool
+}
+
+// NewDiskManager opens (or creates) the file located at the path
+// provided, and returns a disk manager for it. The page size is
+// recorded in the file when it is created, a page size of zero uses
+// the page size recorded in an existing file (or the DefaultPageSize
+// for a new file). Otherwise, an existing file must match the page
+// size it was created with.
+func NewDiskManager(path string, pageSize int) (d *DiskManager, err error) {
+	if pageSize != 0 && !validPageSize(pageSize) {
+		return nil, ErrBadPageSize
+	}
+	// sanitize path
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	// create dirs
+	err = os.MkdirAll(filepath.Dir(path), os.ModeDir|0755)
+	if err != nil {
+		return nil, err
+	}
+	// open or create file
+	fp, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	// make sure the file is closed if anything below fails
+	defer func() {
+		if err != nil {
+			_ = fp.Close()
+		}
+	}()
+	d = &DiskManager{
+		fp:       fp,
+		pageSize: pageSize,
+		freeHead: nilPageID,
+		free:     make(map[PageID]bool),
+	}
+	// get the file size info
+	fi, err := fp.Stat()
+	if err != nil {
+		return nil, err
+	}
+	// if this is a new file, write a fresh header
+	if fi.Size() == 0 {
+		if d.pageSize == 0 {
+			d.pageSize = DefaultPageSize
+		}
+		err = d.fp.Truncate(int64(d.pageSize))
+		if err != nil {
+			return nil, err
+		}
+		err = d.writeHeader()
+		if err != nil {
+			return nil, err
+		}
+		return d, nil
+	}
+	// otherwise, load the existing header and free list
+	err = d.load()
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// validPageSize reports whether the provided page size is supported
+func validPageSize(size int) bool {
+	return size >= MinPageSize && size <= MaxPageSize && size&(size-1) == 0
 }
 
-// NewDiskManager returns a in-memory mock of disk manager
-func NewDiskManager() *DiskManager {
-	return &DiskManager{
-		pages: make(map[*PageID]Page),
-		count: -1,
+// load reads the file header and walks the free list
+func (d *DiskManager) load() error {
+	hdr := make([]byte, fileHeaderSize)
+	_, err := d.fp.ReadAt(hdr, 0)
+	if err != nil {
+		return ErrBadFileHeader
+	}
+	if binary.LittleEndian.Uint32(hdr[0:4]) != fileMagic ||
+		binary.LittleEndian.Uint16(hdr[4:6]) != fileVersion {
+		return ErrBadFileHeader
+	}
+	// use the page size recorded in the file, unless
+	// we were asked for a page size
+	size := int(binary.LittleEndian.Uint32(hdr[8:12]))
+	if d.pageSize == 0 {
+		if !validPageSize(size) {
+			return ErrBadFileHeader
+		}
+		d.pageSize = size
+	}
+	if size != d.pageSize {
+		return ErrPageSizeChanged
 	}
+	d.count = int(binary.LittleEndian.Uint32(hdr[12:16]))
+	d.freeHead = PageID(binary.LittleEndian.Uint32(hdr[16:20]))
+	// walk the free list
+	for pid := d.freeHead; pid != nilPageID; {
+		if int(pid) >= d.count || d.free[pid] {
+			return ErrBadFileHeader
+		}
+		d.free[pid] = true
+		next, err := d.readNextFree(pid)
+		if err != nil {
+			return err
+		}
+		pid = next
+	}
+	return nil
 }
 
-// ReadPage reads a page from pages
-func (d *DiskManager) ReadPage(pageID *PageID) (*Page, error) {
-	if page, ok := d.pages[pageID]; ok {
-		return &page, nil
+// writeHeader encodes and writes the file header
+func (d *DiskManager) writeHeader() error {
+	hdr := make([]byte, fileHeaderSize)
+	binary.LittleEndian.PutUint32(hdr[0:4], fileMagic)
+	binary.LittleEndian.PutUint16(hdr[4:6], fileVersion)
+	binary.LittleEndian.PutUint32(hdr[8:12], uint32(d.pageSize))
+	binary.LittleEndian.PutUint32(hdr[12:16], uint32(d.count))
+	binary.LittleEndian.PutUint32(hdr[16:20], uint32(d.freeHead))
+	_, err := d.fp.WriteAt(hdr, 0)
+	return err
+}
+
+// readNextFree reads the next free page pointer out of a free page
+func (d *DiskManager) readNextFree(pid PageID) (PageID, error) {
+	b := make([]byte, 4)
+	_, err := d.fp.ReadAt(b, d.pageOffset(pid))
+	if err != nil {
+		return nilPageID, err
 	}
-	return nil, errors.New("Page not found")
+	return PageID(binary.LittleEndian.Uint32(b)), nil
 }
 
-// WritePage writes a page in memory to pages
+// pageOffset returns the offset of the page in the file, skipping
+// over the block holding the file header
+func (d *DiskManager) pageOffset(pid PageID) int64 {
+	return (int64(pid) + 1) * int64(d.pageSize)
+}
+
+// isAllocated reports whether the page is currently allocated
+func (d *DiskManager) isAllocated(pid PageID) bool {
+	return int(pid) < d.count && !d.free[pid]
+}
+
+// PageSize returns the page size used by the file
+func (d *DiskManager) PageSize() int {
+	return d.pageSize
+}
+
+// ReadPage reads a page from disk
+func (d *DiskManager) ReadPage(pid PageID) (*Page, error) {
+	if !d.isAllocated(pid) {
+		return nil, ErrPageNotFound
+	}
+	p := newPage(pid, d.pageSize, 0, false)
+	_, err := d.fp.ReadAt(p.data, d.pageOffset(pid))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return p, nil
+}
+
+// WritePage writes a page in memory to disk
 func (d *DiskManager) WritePage(p *Page) error {
-	d.pages[&p.id] = *p
-	return nil
+	if !d.isAllocated(p.id) {
+		return ErrPageNotFound
+	}
+	if len(p.data) != d.pageSize {
+		return ErrBadPageSize
+	}
+	_, err := d.fp.WriteAt(p.data, d.pageOffset(p.id))
+	return err
 }
 
-// AllocatePage allocates one more page
-func (d *DiskManager) AllocatePage() *PageID {
-	if d.count == DiskMaxNumPages-1 {
-		return nil
+// AllocatePage allocates one more page. It reuses a page from the free
+// list if there is one, otherwise it grows the file by one page.
+func (d *DiskManager) AllocatePage() (PageID, error) {
+	// check the free list first
+	if d.freeHead != nilPageID {
+		pid := d.freeHead
+		next, err := d.readNextFree(pid)
+		if err != nil {
+			return nilPageID, err
+		}
+		// zero out the free list pointer
+		_, err = d.fp.WriteAt(make([]byte, 4), d.pageOffset(pid))
+		if err != nil {
+			return nilPageID, err
+		}
+		d.freeHead = next
+		delete(d.free, pid)
+		return pid, d.writeHeader()
 	}
-	d.count++
+	// otherwise, grow the file
 	pid := PageID(d.count)
-	return &pid
+	err := d.fp.Truncate(d.pageOffset(pid) + int64(d.pageSize))
+	if err != nil {
+		return nilPageID, err
+	}
+	d.count++
+	return pid, d.writeHeader()
+}
+
+// DeallocatePage removes page from disk by zeroing it out and pushing
+// it onto the free list
+func (d *DiskManager) DeallocatePage(pid PageID) error {
+	if !d.isAllocated(pid) {
+		return ErrPageNotFound
+	}
+	b := make([]byte, d.pageSize)
+	binary.LittleEndian.PutUint32(b[0:4], uint32(d.freeHead))
+	_, err := d.fp.WriteAt(b, d.pageOffset(pid))
+	if err != nil {
+		return err
+	}
+	d.freeHead = pid
+	d.free[pid] = true
+	return d.writeHeader()
+}
+
+// Sync commits the current contents of the file to stable storage
+func (d *DiskManager) Sync() error {
+	return d.fp.Sync()
 }
 
-// DeallocatePage removes page from disk
-func (d *DiskManager) DeallocatePage(pid *PageID) {
-	delete(d.pages, pid)
+// Close closes the underlying file
+func (d *DiskManager) Close() error {
+	return d.fp.Close()
 }
@@ -0,0 +1,71 @@
+package pagerv3
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDiskManager_FreeList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "freelist.db")
+	d, err := NewDiskManager(path, 1<<10)
+	if err != nil {
+		t.Fatalf("opening disk manager: %s", err)
+	}
+	for i := 0; i < 8; i++ {
+		pid, err := d.AllocatePage()
+		if err != nil {
+			t.Fatalf("allocate page: %s", err)
+		}
+		if pid != PageID(i) {
+			t.Fatalf("allocate page: expected %d, got %d", i, pid)
+		}
+	}
+	// free a couple of pages
+	for _, pid := range []PageID{2, 5} {
+		err = d.DeallocatePage(pid)
+		if err != nil {
+			t.Fatalf("deallocate page: %s", err)
+		}
+	}
+	_, err = d.ReadPage(2)
+	if err != ErrPageNotFound {
+		t.Errorf("read free page: expected %v, got %v", ErrPageNotFound, err)
+	}
+	err = d.Close()
+	if err != nil {
+		t.Fatalf("close: %s", err)
+	}
+	// reopening with a different page size should fail
+	_, err = NewDiskManager(path, 4<<10)
+	if err != ErrPageSizeChanged {
+		t.Fatalf("expected %v, got %v", ErrPageSizeChanged, err)
+	}
+	// reopen (using the page size recorded in the file), the free
+	// pages should be reused (most recently freed first)
+	d, err = NewDiskManager(path, 0)
+	if err != nil {
+		t.Fatalf("opening disk manager: %s", err)
+	}
+	defer d.Close()
+	if d.PageSize() != 1<<10 {
+		t.Fatalf("expected page size %d, got %d", 1<<10, d.PageSize())
+	}
+	for _, want := range []PageID{5, 2, 8} {
+		pid, err := d.AllocatePage()
+		if err != nil {
+			t.Fatalf("allocate page: %s", err)
+		}
+		if pid != want {
+			t.Errorf("allocate page: expected %d, got %d", want, pid)
+		}
+	}
+}
+
+func TestNewDiskManager_BadPageSize(t *testing.T) {
+	for _, size := range []int{100, 3000, MaxPageSize << 1} {
+		_, err := NewDiskManager(filepath.Join(t.TempDir(), "bad.db"), size)
+		if err != ErrBadPageSize {
+			t.Errorf("page size %d: expected %v, got %v", size, ErrBadPageSize, err)
+		}
+	}
+}
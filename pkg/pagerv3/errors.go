@@ -0,0 +1,17 @@
+package pagerv3
+
+import (
+	"errors"
+)
+
+var (
+	ErrPageNotFound    = errors.New("pagerv3: page could not be found")
+	ErrPageIsPinned    = errors.New("pagerv3: page is pinned")
+	ErrPageNotPinned   = errors.New("pagerv3: page is not pinned")
+	ErrAllFramesPinned = errors.New("pagerv3: every frame in the buffer pool is pinned")
+	ErrBadPageSize     = errors.New("pagerv3: page size must be a power of two between 512 B and 1 MB")
+	ErrPageSizeChanged = errors.New("pagerv3: page size does not match the page size of the file")
+	ErrBadFileHeader   = errors.New("pagerv3: file header is missing or corrupt")
+	ErrOutOfBounds     = errors.New("pagerv3: range is outside of the page")
+	ErrUseAfterUnpin   = errors.New("pagerv3: borrowed page data was written after the page was unpinned")
+)
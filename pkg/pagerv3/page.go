@@ -1,10 +1,98 @@
 package pagerv3
 
-const pageSize = 16
+const (
+	// DefaultPageSize is the page size used when one is not specified
+	DefaultPageSize = 4 << 10 // 4 KB
+	// MinPageSize is the smallest page size supported
+	MinPageSize = 512
+	// MaxPageSize is the largest page size supported
+	MaxPageSize = 1 << 20 // 1 MB
+)
 
+// Page is a single page of data held in a frame of the buffer pool
 type Page struct {
 	id       PageID
 	pinCount int
 	isDirty  bool
-	data     [pageSize]byte
+	data     []byte
+	borrows  borrowState
+}
+
+// newPage creates and returns a new page using the provided page
+// ID, pin count and dirty flag, with room for size bytes of data
+func newPage(pid PageID, size int, pinCount int, isDirty bool) *Page {
+	return &Page{
+		id:       pid,
+		pinCount: pinCount,
+		isDirty:  isDirty,
+		data:     make([]byte, size),
+	}
+}
+
+// ID returns the PageID of the page
+func (p *Page) ID() PageID {
+	return p.id
+}
+
+// Data returns the page data. The returned slice points directly
+// into the page, so any changes made to it are made to the page.
+// Callers modifying the data should unpin the page as dirty.
+func (p *Page) Data() []byte {
+	return p.data
+}
+
+// Borrow returns a zero-copy view of n bytes of the page data starting
+// at off. The view points directly into the page buffer, so no data is
+// copied, but it is only valid while the page is pinned. Once the page
+// is unpinned its frame can be reused for another page at any time, so
+// the view must not be used after calling UnpinPage. Building with the
+// pagerdebug tag detects views being used after they were unpinned.
+func (p *Page) Borrow(off, n int) ([]byte, error) {
+	if p.pinCount <= 0 {
+		return nil, ErrPageNotPinned
+	}
+	if off < 0 || n < 0 || off+n > len(p.data) {
+		return nil, ErrOutOfBounds
+	}
+	return p.data[off : off+n : off+n], nil
+}
+
+// PinCount returns the number of callers currently holding the page
+func (p *Page) PinCount() int {
+	return p.pinCount
+}
+
+// IsDirty reports whether the page has been modified since it was
+// last written to disk
+func (p *Page) IsDirty() bool {
+	return p.isDirty
+}
+
+// incPinCount increments the pin count of the page
+func (p *Page) incPinCount() {
+	p.borrows.check()
+	p.pinCount++
+}
+
+// decPinCount decrements the pin count of the page
+func (p *Page) decPinCount() {
+	if p.pinCount > 0 {
+		p.pinCount--
+		// nobody holds the page anymore, so any
+		// borrowed views of it are no longer valid
+		if p.pinCount == 0 {
+			p.data = p.borrows.release(p.data)
+		}
+	}
+}
+
+// reset zeros out the page data and header information
+func (p *Page) reset() {
+	p.borrows.check()
+	p.id = 0
+	p.pinCount = 0
+	p.isDirty = false
+	for i := range p.data {
+		p.data[i] = 0
+	}
 }
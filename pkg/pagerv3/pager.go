@@ -53,24 +53,25 @@ type Manager interface {
 
 	// NewPage allocates a new page and pins it to a frame. If we did not
 	// find an open frame we will proceed by attempting to victimize the
-	// current frame.
-	NewPage() *Page
+	// current frame. If every frame is pinned an error is returned.
+	NewPage() (*Page, error)
 
 	// FetchPage fetches the requested page from the buffer pool. If the
 	// page is in cache, it is returned immediately. If not, it will be
-	// found on disk, loaded into the cache and returned.
-	FetchPage(pid PageID) *Page
+	// found on disk, loaded into the cache and returned. The returned
+	// page is pinned, and must be unpinned when the caller is done.
+	FetchPage(pid PageID) (*Page, error)
 
 	// UnpinPage unpins the target page from the buffer pool. It indicates
 	// that the page is not used any more for the current requesting thread.
 	// If no more threads are using this page, the page is considered for
-	// eviction (victim).
-	UnpinPage(pid PageID) error
+	// eviction (victim). The isDirty flag should be set if the page has
+	// been modified.
+	UnpinPage(pid PageID, isDirty bool) error
 
 	// FlushPage flushes the target page that is in the cache onto the
-	// underlying medium. It also decrements the pin count and unpins it
-	// from the holding frame and unsets the dirty bit.
-	FlushPage(pid PageID) bool
+	// underlying medium and unsets the dirty bit.
+	FlushPage(pid PageID) error
 
 	// DeletePage deletes a page from the buffer pool. Once removed, it
 	// marks the holding fame as free to use.
@@ -79,6 +80,7 @@ type Manager interface {
 	// GetFrameID returns a frame ID from the free list, or by using the
 	// replacement policy if the free list is full along with a boolean
 	// indicating true if the frame ID was returned using the free list
-	// and false if it was returned by using the replacement policy.
-	GetFrameID() (FrameID, bool)
+	// and false if it was returned by using the replacement policy. If
+	// every frame is pinned an error is returned.
+	GetFrameID() (FrameID, bool, error)
 }
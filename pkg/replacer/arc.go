@@ -0,0 +1,195 @@
+package replacer
+
+import (
+	"container/list"
+)
+
+// arcEntry is a page in one of the ARC lists
+type arcEntry struct {
+	pid    uint32
+	pinned bool
+	list   *list.List
+}
+
+// ARC implements the adaptive replacement cache policy. Resident pages
+// are split between T1 (seen once recently) and T2 (seen at least twice
+// recently), and the pages most recently evicted from each are remembered
+// in the ghost lists B1 and B2. A hit in a ghost list adapts the target
+// size of T1 (p) towards recency or frequency, whichever is working.
+type ARC struct {
+	c      int // c is the capacity of the cache
+	p      int // p is the target size of T1
+	t1, t2 *list.List
+	b1, b2 *list.List
+	pages  map[uint32]*list.Element // pages holds every page in any list
+	count  int                      // count is the number of unpinned pages
+}
+
+// NewARC returns a new ARC replacer
+func NewARC(capacity int) *ARC {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &ARC{
+		c:     capacity,
+		t1:    list.New(),
+		t2:    list.New(),
+		b1:    list.New(),
+		b2:    list.New(),
+		pages: make(map[uint32]*list.Element, 2*capacity),
+	}
+}
+
+// lruUnpinned returns the least recently used unpinned entry in the list
+func lruUnpinned(l *list.List) *list.Element {
+	for e := l.Back(); e != nil; e = e.Prev() {
+		if !e.Value.(*arcEntry).pinned {
+			return e
+		}
+	}
+	return nil
+}
+
+// move moves the entry into the front (MRU position) of the list provided
+func (a *ARC) move(e *list.Element, to *list.List) *list.Element {
+	ent := e.Value.(*arcEntry)
+	ent.list.Remove(e)
+	ent.list = to
+	ne := to.PushFront(ent)
+	a.pages[ent.pid] = ne
+	return ne
+}
+
+// drop removes the least recently used entry from a ghost list
+func (a *ARC) drop(l *list.List) {
+	if e := l.Back(); e != nil {
+		delete(a.pages, l.Remove(e).(*arcEntry).pid)
+	}
+}
+
+// Victim evicts from T1 if it is larger than its target size, and
+// otherwise from T2. The evicted page is remembered in B1 or B2.
+func (a *ARC) Victim() (uint32, bool) {
+	if a.count == 0 {
+		return 0, false
+	}
+	var e *list.Element
+	if a.t1.Len() > 0 && (a.t1.Len() > a.p || a.t2.Len() == 0) {
+		e = lruUnpinned(a.t1)
+	}
+	if e == nil {
+		e = lruUnpinned(a.t2)
+	}
+	if e == nil {
+		e = lruUnpinned(a.t1)
+	}
+	ent := e.Value.(*arcEntry)
+	if ent.list == a.t1 {
+		a.move(e, a.b1)
+	} else {
+		a.move(e, a.b2)
+	}
+	a.count--
+	// keep the ghost lists in check
+	for a.b1.Len() > a.c {
+		a.drop(a.b1)
+	}
+	for a.b2.Len() > a.c {
+		a.drop(a.b2)
+	}
+	return ent.pid, true
+}
+
+// access records an access to the page, and returns its entry
+func (a *ARC) access(pid uint32) *arcEntry {
+	e, ok := a.pages[pid]
+	if !ok {
+		// a complete miss, goes into T1
+		ent := &arcEntry{pid: pid, pinned: true, list: a.t1}
+		a.pages[pid] = a.t1.PushFront(ent)
+		// make sure the history does not grow unbounded
+		if a.t1.Len()+a.b1.Len() > a.c && a.b1.Len() > 0 {
+			a.drop(a.b1)
+		}
+		if a.t1.Len()+a.t2.Len()+a.b1.Len()+a.b2.Len() > 2*a.c && a.b2.Len() > 0 {
+			a.drop(a.b2)
+		}
+		return ent
+	}
+	ent := e.Value.(*arcEntry)
+	switch ent.list {
+	case a.b1:
+		// ghost hit in B1, favor recency
+		a.p = min(a.c, a.p+max(a.b2.Len()/a.b1.Len(), 1))
+		ent.pinned = true
+	case a.b2:
+		// ghost hit in B2, favor frequency
+		a.p = max(0, a.p-max(a.b1.Len()/a.b2.Len(), 1))
+		ent.pinned = true
+	}
+	// any hit moves the page into T2
+	a.move(e, a.t2)
+	return ent
+}
+
+// Pin records an access to the page and marks it as in use
+func (a *ARC) Pin(pid uint32) {
+	if e, ok := a.pages[pid]; ok {
+		ent := e.Value.(*arcEntry)
+		if (ent.list == a.t1 || ent.list == a.t2) && !ent.pinned {
+			a.count--
+		}
+	}
+	a.access(pid).pinned = true
+}
+
+// Unpin marks the page as up for eviction
+func (a *ARC) Unpin(pid uint32) {
+	e, ok := a.pages[pid]
+	var ent *arcEntry
+	if ok {
+		ent = e.Value.(*arcEntry)
+	}
+	if ent == nil || ent.list == a.b1 || ent.list == a.b2 {
+		// not resident, load it
+		ent = a.access(pid)
+		ent.pinned = true
+	}
+	if ent.pinned {
+		ent.pinned = false
+		a.count++
+	}
+}
+
+// Remove stops tracking the page
+func (a *ARC) Remove(pid uint32) {
+	e, ok := a.pages[pid]
+	if !ok {
+		return
+	}
+	ent := e.Value.(*arcEntry)
+	if (ent.list == a.t1 || ent.list == a.t2) && !ent.pinned {
+		a.count--
+	}
+	ent.list.Remove(e)
+	delete(a.pages, pid)
+}
+
+// Size returns the number of unpinned pages
+func (a *ARC) Size() int {
+	return a.count
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,79 @@
+package replacer
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// benchCapacities are the buffer pool sizes each trace is replayed with
+var benchCapacities = []int{16, 64, 256}
+
+// loadTraces loads every recorded trace in testdata, along with a few
+// synthetic ones
+func loadTraces(b *testing.B) map[string]Trace {
+	traces := map[string]Trace{
+		"zipf":     zipfTrace(1000, 10000),
+		"loop":     loopTrace(100, 10000),
+		"hot+scan": scanTrace(),
+	}
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.trace"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, path := range paths {
+		fp, err := os.Open(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		t, err := ReadTrace(fp)
+		fp.Close()
+		if err != nil {
+			b.Fatalf("%s: %s", path, err)
+		}
+		traces[filepath.Base(path)] = t
+	}
+	return traces
+}
+
+// zipfTrace returns a trace of n accesses over pages pages, with the
+// page popularity following a zipf distribution
+func zipfTrace(pages, n int) Trace {
+	z := rand.NewZipf(rand.New(rand.NewSource(1)), 1.1, 1, uint64(pages-1))
+	t := make(Trace, n)
+	for i := range t {
+		t[i] = uint32(z.Uint64())
+	}
+	return t
+}
+
+// loopTrace returns a trace of n accesses looping over pages pages
+func loopTrace(pages, n int) Trace {
+	t := make(Trace, n)
+	for i := range t {
+		t[i] = uint32(i % pages)
+	}
+	return t
+}
+
+// BenchmarkReplacer_HitRate replays every trace against every policy
+// and reports the hit rate, run with:
+//
+//	go test -run=^$ -bench=HitRate ./pkg/replacer
+func BenchmarkReplacer_HitRate(b *testing.B) {
+	for name, trace := range loadTraces(b) {
+		for _, capacity := range benchCapacities {
+			for _, policy := range Policies {
+				b.Run(fmt.Sprintf("%s/%d/%s", name, capacity, policy), func(b *testing.B) {
+					var s Stats
+					for i := 0; i < b.N; i++ {
+						s = Simulate(New(policy, capacity), capacity, trace)
+					}
+					b.ReportMetric(100*s.HitRate(), "hit%")
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package replacer
+
+// clockSlot is a single slot on the face of the clock
+type clockSlot struct {
+	pid    uint32
+	used   bool // used reports if the slot holds a page
+	ref    bool // ref is the reference bit
+	pinned bool
+}
+
+// Clock sweeps a clock hand over the pages, giving each recently
+// referenced page a second chance before victimizing it
+type Clock struct {
+	slots []clockSlot
+	pages map[uint32]int // pages maps a page to its slot
+	free  []int          // free is a list of unused slots
+	hand  int            // hand is the current position of the clock hand
+	count int            // count is the number of unpinned pages
+}
+
+// NewClock returns a new Clock replacer
+func NewClock(capacity int) *Clock {
+	if capacity < 1 {
+		capacity = 1
+	}
+	c := &Clock{
+		slots: make([]clockSlot, capacity),
+		pages: make(map[uint32]int, capacity),
+		free:  make([]int, capacity),
+	}
+	for i := range c.free {
+		c.free[i] = capacity - 1 - i
+	}
+	return c
+}
+
+// Victim evicts the first unpinned page the clock hand finds that
+// has not been referenced since the last sweep
+func (c *Clock) Victim() (uint32, bool) {
+	if c.count == 0 {
+		return 0, false
+	}
+	for {
+		s := &c.slots[c.hand]
+		i := c.hand
+		c.hand = (c.hand + 1) % len(c.slots)
+		if !s.used || s.pinned {
+			continue
+		}
+		if s.ref {
+			// give the page a second chance
+			s.ref = false
+			continue
+		}
+		// found our victim
+		pid := s.pid
+		c.release(i)
+		return pid, true
+	}
+}
+
+// slot returns the slot for the page, adding the page if it is not
+// already being tracked (and growing the clock if it is full)
+func (c *Clock) slot(pid uint32) *clockSlot {
+	if i, ok := c.pages[pid]; ok {
+		return &c.slots[i]
+	}
+	if len(c.free) == 0 {
+		c.slots = append(c.slots, clockSlot{})
+		c.free = append(c.free, len(c.slots)-1)
+	}
+	i := c.free[len(c.free)-1]
+	c.free = c.free[:len(c.free)-1]
+	c.pages[pid] = i
+	c.slots[i] = clockSlot{pid: pid, used: true, pinned: true}
+	return &c.slots[i]
+}
+
+// release frees up the slot at index i
+func (c *Clock) release(i int) {
+	s := &c.slots[i]
+	if !s.pinned {
+		c.count--
+	}
+	delete(c.pages, s.pid)
+	*s = clockSlot{}
+	c.free = append(c.free, i)
+}
+
+// Pin marks the page as referenced and in use
+func (c *Clock) Pin(pid uint32) {
+	s := c.slot(pid)
+	if !s.pinned {
+		s.pinned = true
+		c.count--
+	}
+	s.ref = true
+}
+
+// Unpin marks the page as up for eviction
+func (c *Clock) Unpin(pid uint32) {
+	s := c.slot(pid)
+	if s.pinned {
+		s.pinned = false
+		c.count++
+	}
+}
+
+// Remove stops tracking the page
+func (c *Clock) Remove(pid uint32) {
+	if i, ok := c.pages[pid]; ok {
+		c.release(i)
+	}
+}
+
+// Size returns the number of unpinned pages
+func (c *Clock) Size() int {
+	return c.count
+}
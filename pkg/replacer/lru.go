@@ -0,0 +1,64 @@
+package replacer
+
+import (
+	"container/list"
+)
+
+// LRU evicts the least recently used unpinned page
+type LRU struct {
+	list   *list.List               // list of unpinned pages, most recent at the front
+	pages  map[uint32]*list.Element // pages holds every unpinned page
+	pinned map[uint32]bool          // pinned holds every pinned page
+}
+
+// NewLRU returns a new LRU replacer
+func NewLRU(capacity int) *LRU {
+	return &LRU{
+		list:   list.New(),
+		pages:  make(map[uint32]*list.Element, capacity),
+		pinned: make(map[uint32]bool, capacity),
+	}
+}
+
+// Victim evicts the least recently used unpinned page
+func (l *LRU) Victim() (uint32, bool) {
+	e := l.list.Back()
+	if e == nil {
+		return 0, false
+	}
+	pid := l.list.Remove(e).(uint32)
+	delete(l.pages, pid)
+	return pid, true
+}
+
+// Pin marks the page as in use
+func (l *LRU) Pin(pid uint32) {
+	if e, ok := l.pages[pid]; ok {
+		l.list.Remove(e)
+		delete(l.pages, pid)
+	}
+	l.pinned[pid] = true
+}
+
+// Unpin marks the page as most recently used, and up for eviction
+func (l *LRU) Unpin(pid uint32) {
+	if _, ok := l.pages[pid]; ok {
+		return
+	}
+	delete(l.pinned, pid)
+	l.pages[pid] = l.list.PushFront(pid)
+}
+
+// Remove stops tracking the page
+func (l *LRU) Remove(pid uint32) {
+	if e, ok := l.pages[pid]; ok {
+		l.list.Remove(e)
+		delete(l.pages, pid)
+	}
+	delete(l.pinned, pid)
+}
+
+// Size returns the number of unpinned pages
+func (l *LRU) Size() int {
+	return l.list.Len()
+}
@@ -0,0 +1,115 @@
+package replacer
+
+// defaultK is the number of accesses tracked by New for LRU-K
+const defaultK = 2
+
+// lrukEntry holds the access history of a single page
+type lrukEntry struct {
+	history []uint64 // history holds the last k access times, oldest first
+	pinned  bool
+}
+
+// LRUK evicts the unpinned page whose k-th most recent access is the
+// furthest in the past (the largest backward k-distance). Pages that
+// have been accessed fewer than k times have an infinite backward
+// k-distance, and are evicted first (in LRU order).
+type LRUK struct {
+	k     int
+	now   uint64 // now is a logical clock, incremented on every access
+	pages map[uint32]*lrukEntry
+	count int // count is the number of unpinned pages
+}
+
+// NewLRUK returns a new LRU-K replacer
+func NewLRUK(capacity int, k int) *LRUK {
+	if k < 1 {
+		k = defaultK
+	}
+	return &LRUK{
+		k:     k,
+		pages: make(map[uint32]*lrukEntry, capacity),
+	}
+}
+
+// Victim evicts the unpinned page with the largest backward k-distance
+func (l *LRUK) Victim() (uint32, bool) {
+	var victim uint32
+	var best *lrukEntry
+	for pid, e := range l.pages {
+		if e.pinned {
+			continue
+		}
+		if best == nil || l.before(e, best) || (!l.before(best, e) && pid < victim) {
+			victim, best = pid, e
+		}
+	}
+	if best == nil {
+		return 0, false
+	}
+	delete(l.pages, victim)
+	l.count--
+	return victim, true
+}
+
+// before reports whether page a should be evicted before page b
+func (l *LRUK) before(a, b *lrukEntry) bool {
+	af, bf := len(a.history) < l.k, len(b.history) < l.k
+	if af != bf {
+		// only one has an infinite backward k-distance
+		return af
+	}
+	if af {
+		// both have an infinite backward k-distance, fall back to LRU
+		return a.history[len(a.history)-1] < b.history[len(b.history)-1]
+	}
+	// compare the k-th most recent access
+	return a.history[0] < b.history[0]
+}
+
+// Pin records an access to the page and marks it as in use
+func (l *LRUK) Pin(pid uint32) {
+	e, ok := l.pages[pid]
+	if !ok {
+		e = &lrukEntry{pinned: true}
+		l.pages[pid] = e
+	}
+	if !e.pinned {
+		e.pinned = true
+		l.count--
+	}
+	l.now++
+	if len(e.history) == l.k {
+		copy(e.history, e.history[1:])
+		e.history = e.history[:l.k-1]
+	}
+	e.history = append(e.history, l.now)
+}
+
+// Unpin marks the page as up for eviction
+func (l *LRUK) Unpin(pid uint32) {
+	e, ok := l.pages[pid]
+	if !ok {
+		l.now++
+		e = &lrukEntry{pinned: true, history: []uint64{l.now}}
+		l.pages[pid] = e
+	}
+	if e.pinned {
+		e.pinned = false
+		l.count++
+	}
+}
+
+// Remove stops tracking the page
+func (l *LRUK) Remove(pid uint32) {
+	if e, ok := l.pages[pid]; ok {
+		if !e.pinned {
+			l.count--
+		}
+		delete(l.pages, pid)
+	}
+}
+
+// Size returns the number of unpinned pages
+func (l *LRUK) Size() int {
+	return l.count
+}
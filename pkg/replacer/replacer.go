@@ -0,0 +1,79 @@
+// Package replacer provides interchangeable page replacement policies
+// that can be plugged into any of the buffer pools. Every policy keeps
+// track of the pages currently held by a buffer pool, identified by
+// their page ID, and decides which unpinned page should be evicted
+// when the buffer pool runs out of frames.
+package replacer
+
+// Replacer is a page replacement policy
+type Replacer interface {
+
+	// Victim selects a page to evict as defined by the replacement
+	// policy, stops tracking it, and returns its page ID along with a
+	// boolean indicating true if a victim was found. It returns false
+	// if every page being tracked is currently pinned.
+	Victim() (uint32, bool)
+
+	// Pin records an access to the page and marks it as in use, so it
+	// will not be victimized until it is unpinned. Pinning a page that
+	// is not being tracked starts tracking it (the page was loaded).
+	Pin(pid uint32)
+
+	// Unpin marks the page as no longer in use, indicating that it can
+	// now be victimized.
+	Unpin(pid uint32)
+
+	// Remove stops tracking the page without it being victimized (the
+	// page was deleted from the buffer pool).
+	Remove(pid uint32)
+
+	// Size returns the number of pages that can currently be victimized
+	Size() int
+}
+
+// Policy names a replacement policy
+type Policy int
+
+const (
+	PolicyLRU Policy = iota
+	PolicyClock
+	PolicyLRUK
+	Policy2Q
+	PolicyARC
+)
+
+// String is a Policy stringer method
+func (p Policy) String() string {
+	switch p {
+	case PolicyLRU:
+		return "LRU"
+	case PolicyClock:
+		return "Clock"
+	case PolicyLRUK:
+		return "LRU-K"
+	case Policy2Q:
+		return "2Q"
+	case PolicyARC:
+		return "ARC"
+	}
+	return "unknown"
+}
+
+// Policies is a list of every available replacement policy
+var Policies = []Policy{PolicyLRU, PolicyClock, PolicyLRUK, Policy2Q, PolicyARC}
+
+// New returns a new replacer using the policy provided, sized for a
+// buffer pool holding capacity pages
+func New(policy Policy, capacity int) Replacer {
+	switch policy {
+	case PolicyClock:
+		return NewClock(capacity)
+	case PolicyLRUK:
+		return NewLRUK(capacity, defaultK)
+	case Policy2Q:
+		return New2Q(capacity)
+	case PolicyARC:
+		return NewARC(capacity)
+	}
+	return NewLRU(capacity)
+}
@@ -0,0 +1,116 @@
+package replacer
+
+import (
+	"testing"
+)
+
+func TestReplacer_Pinning(t *testing.T) {
+	for _, policy := range Policies {
+		t.Run(policy.String(), func(t *testing.T) {
+			r := New(policy, 4)
+			for pid := uint32(1); pid <= 4; pid++ {
+				r.Pin(pid)
+			}
+			if r.Size() != 0 {
+				t.Fatalf("expected size 0, got %d", r.Size())
+			}
+			if _, ok := r.Victim(); ok {
+				t.Fatalf("expected no victim with every page pinned")
+			}
+			r.Unpin(3)
+			if r.Size() != 1 {
+				t.Fatalf("expected size 1, got %d", r.Size())
+			}
+			pid, ok := r.Victim()
+			if !ok || pid != 3 {
+				t.Fatalf("expected victim 3, got %d (%v)", pid, ok)
+			}
+			if r.Size() != 0 {
+				t.Fatalf("expected size 0, got %d", r.Size())
+			}
+			// removed pages are never victimized
+			r.Unpin(1)
+			r.Unpin(2)
+			r.Remove(1)
+			pid, ok = r.Victim()
+			if !ok || pid != 2 {
+				t.Fatalf("expected victim 2, got %d (%v)", pid, ok)
+			}
+			if _, ok = r.Victim(); ok {
+				t.Fatalf("expected no victim")
+			}
+		})
+	}
+}
+
+func TestLRU_Victim(t *testing.T) {
+	r := NewLRU(4)
+	for pid := uint32(1); pid <= 4; pid++ {
+		r.Pin(pid)
+		r.Unpin(pid)
+	}
+	// touch 1, so 2 becomes the least recently used
+	r.Pin(1)
+	r.Unpin(1)
+	for _, want := range []uint32{2, 3, 4, 1} {
+		pid, ok := r.Victim()
+		if !ok || pid != want {
+			t.Fatalf("expected victim %d, got %d (%v)", want, pid, ok)
+		}
+	}
+}
+
+func TestLRUK_Victim(t *testing.T) {
+	r := NewLRUK(4, 2)
+	// 1 and 2 are accessed twice, 3 once
+	for _, pid := range []uint32{1, 2, 1, 2, 3} {
+		r.Pin(pid)
+		r.Unpin(pid)
+	}
+	// 3 has an infinite backward k-distance, so it goes first even
+	// though it was accessed most recently
+	for _, want := range []uint32{3, 1, 2} {
+		pid, ok := r.Victim()
+		if !ok || pid != want {
+			t.Fatalf("expected victim %d, got %d (%v)", want, pid, ok)
+		}
+	}
+}
+
+// scanTrace returns a trace of a small hot set of pages that is accessed
+// over and over, interleaved with long scans of pages that are never
+// accessed again
+func scanTrace() Trace {
+	var trace Trace
+	scan := uint32(1000)
+	for round := 0; round < 20; round++ {
+		for i := 0; i < 30; i++ {
+			trace = append(trace, uint32(i%6))
+			if i%3 == 0 {
+				trace = append(trace, scan)
+				scan++
+			}
+		}
+		for i := 0; i < 40; i++ {
+			trace = append(trace, scan)
+			scan++
+		}
+	}
+	return trace
+}
+
+func TestTwoQ_ScanResistance(t *testing.T) {
+	lru := Simulate(NewLRU(8), 8, scanTrace())
+	twoq := Simulate(New2Q(8), 8, scanTrace())
+	if twoq.HitRate() < lru.HitRate() {
+		t.Errorf("expected 2Q (%.2f) to beat LRU (%.2f) on a scan", twoq.HitRate(), lru.HitRate())
+	}
+}
+
+func TestARC_ScanResistance(t *testing.T) {
+	lru := Simulate(NewLRU(8), 8, scanTrace())
+	arc := Simulate(NewARC(8), 8, scanTrace())
+	if arc.HitRate() < lru.HitRate() {
+		t.Errorf("expected ARC (%.2f) to beat LRU (%.2f) on a scan", arc.HitRate(), lru.HitRate())
+	}
+}
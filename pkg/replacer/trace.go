@@ -0,0 +1,86 @@
+package replacer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Trace is a recorded sequence of page accesses
+type Trace []uint32
+
+// ReadTrace reads a trace of page accesses, one page ID per line. Blank
+// lines and lines starting with '#' are ignored.
+func ReadTrace(r io.Reader) (Trace, error) {
+	var t Trace
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pid, err := strconv.ParseUint(line, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("replacer: trace line %d: %w", n, err)
+		}
+		t = append(t, uint32(pid))
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// WriteTrace writes a trace of page accesses, one page ID per line
+func WriteTrace(w io.Writer, t Trace) error {
+	bw := bufio.NewWriter(w)
+	for _, pid := range t {
+		_, err := bw.WriteString(strconv.FormatUint(uint64(pid), 10) + "\n")
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Stats holds the results of a simulation
+type Stats struct {
+	Hits   int
+	Misses int
+}
+
+// HitRate returns the ratio of hits to accesses
+func (s Stats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// Simulate replays the trace against a buffer pool holding capacity
+// pages that uses the replacer provided, and returns the hit and miss
+// counts. Every access pins and then unpins the page.
+func Simulate(r Replacer, capacity int, t Trace) Stats {
+	var s Stats
+	resident := make(map[uint32]bool, capacity)
+	for _, pid := range t {
+		if resident[pid] {
+			s.Hits++
+		} else {
+			s.Misses++
+			if len(resident) >= capacity {
+				victim, ok := r.Victim()
+				if !ok {
+					panic("replacer: no victim found in simulation")
+				}
+				delete(resident, victim)
+			}
+			resident[pid] = true
+		}
+		r.Pin(pid)
+		r.Unpin(pid)
+	}
+	return s
+}
@@ -0,0 +1,158 @@
+package replacer
+
+import (
+	"container/list"
+)
+
+// twoQEntry is a resident page in one of the 2Q queues
+type twoQEntry struct {
+	pid    uint32
+	pinned bool
+	queue  *list.List
+}
+
+// TwoQ implements the full version of the 2Q replacement policy. Pages
+// that are accessed for the first time go into a FIFO queue (A1in). If
+// they are evicted from there, they are remembered in a ghost queue
+// (A1out) and a later access promotes them into the main LRU queue (Am).
+// This keeps one-off scans from flushing out frequently used pages.
+type TwoQ struct {
+	kin   int // kin is the target size of A1in
+	kout  int // kout is the maximum size of A1out
+	a1in  *list.List
+	a1out *list.List
+	am    *list.List
+	pages map[uint32]*list.Element // resident pages
+	ghost map[uint32]*list.Element // pages in A1out
+	count int                      // count is the number of unpinned pages
+}
+
+// New2Q returns a new 2Q replacer
+func New2Q(capacity int) *TwoQ {
+	kin := capacity / 4
+	if kin < 1 {
+		kin = 1
+	}
+	kout := capacity / 2
+	if kout < 1 {
+		kout = 1
+	}
+	return &TwoQ{
+		kin:   kin,
+		kout:  kout,
+		a1in:  list.New(),
+		a1out: list.New(),
+		am:    list.New(),
+		pages: make(map[uint32]*list.Element, capacity),
+		ghost: make(map[uint32]*list.Element, kout),
+	}
+}
+
+// oldestUnpinned returns the oldest unpinned entry in the queue
+func oldestUnpinned(q *list.List) *list.Element {
+	for e := q.Back(); e != nil; e = e.Prev() {
+		if !e.Value.(*twoQEntry).pinned {
+			return e
+		}
+	}
+	return nil
+}
+
+// Victim evicts from A1in if it is over its target size, and otherwise
+// evicts the least recently used page in Am
+func (q *TwoQ) Victim() (uint32, bool) {
+	if q.count == 0 {
+		return 0, false
+	}
+	var e *list.Element
+	if q.a1in.Len() > q.kin {
+		e = oldestUnpinned(q.a1in)
+	}
+	if e == nil {
+		e = oldestUnpinned(q.am)
+	}
+	if e == nil {
+		e = oldestUnpinned(q.a1in)
+	}
+	ent := e.Value.(*twoQEntry)
+	ent.queue.Remove(e)
+	delete(q.pages, ent.pid)
+	q.count--
+	// remember pages evicted from A1in
+	if ent.queue == q.a1in {
+		q.ghost[ent.pid] = q.a1out.PushFront(ent.pid)
+		if q.a1out.Len() > q.kout {
+			delete(q.ghost, q.a1out.Remove(q.a1out.Back()).(uint32))
+		}
+	}
+	return ent.pid, true
+}
+
+// Pin records an access to the page and marks it as in use
+func (q *TwoQ) Pin(pid uint32) {
+	if e, ok := q.pages[pid]; ok {
+		ent := e.Value.(*twoQEntry)
+		if !ent.pinned {
+			ent.pinned = true
+			q.count--
+		}
+		// accesses in A1in are considered correlated, and do not move
+		// the page, accesses in Am move the page to the front
+		if ent.queue == q.am {
+			q.am.MoveToFront(e)
+		}
+		return
+	}
+	q.load(pid, true)
+}
+
+// load starts tracking a page that is not resident
+func (q *TwoQ) load(pid uint32, pinned bool) *twoQEntry {
+	ent := &twoQEntry{pid: pid, pinned: pinned, queue: q.a1in}
+	if g, ok := q.ghost[pid]; ok {
+		// seen recently, promote it into Am
+		q.a1out.Remove(g)
+		delete(q.ghost, pid)
+		ent.queue = q.am
+	}
+	q.pages[pid] = ent.queue.PushFront(ent)
+	if !pinned {
+		q.count++
+	}
+	return ent
+}
+
+// Unpin marks the page as up for eviction
+func (q *TwoQ) Unpin(pid uint32) {
+	e, ok := q.pages[pid]
+	if !ok {
+		q.load(pid, false)
+		return
+	}
+	ent := e.Value.(*twoQEntry)
+	if ent.pinned {
+		ent.pinned = false
+		q.count++
+	}
+}
+
+// Remove stops tracking the page
+func (q *TwoQ) Remove(pid uint32) {
+	if e, ok := q.pages[pid]; ok {
+		ent := e.Value.(*twoQEntry)
+		ent.queue.Remove(e)
+		delete(q.pages, pid)
+		if !ent.pinned {
+			q.count--
+		}
+	}
+	if g, ok := q.ghost[pid]; ok {
+		q.a1out.Remove(g)
+		delete(q.ghost, pid)
+	}
+}
+
+// Size returns the number of unpinned pages
+func (q *TwoQ) Size() int {
+	return q.count
+}